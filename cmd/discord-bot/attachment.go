@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultMaxAttachmentSize is the default maximum number of bytes read of a text attachment.
+const defaultMaxAttachmentSize = 100 << 10
+
+// textLanguages are the languages of the fenced blocks by the extensions of the text attachments.
+var textLanguages = map[string]string{
+	".txt": "", ".log": "", ".csv": "csv", ".tsv": "", ".md": "markdown", ".rst": "rst",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".ini": "ini", ".xml": "xml",
+	".html": "html", ".css": "css", ".sql": "sql", ".diff": "diff", ".patch": "diff",
+	".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript", ".jsx": "jsx", ".tsx": "tsx",
+	".java": "java", ".kt": "kotlin", ".c": "c", ".h": "c", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp",
+	".rs": "rust", ".rb": "ruby", ".php": "php", ".swift": "swift", ".lua": "lua", ".r": "r",
+	".sh": "bash", ".bash": "bash", ".ps1": "powershell", ".bat": "batch",
+	".dockerfile": "dockerfile", ".proto": "protobuf", ".graphql": "graphql", ".vue": "vue",
+}
+
+// downloadAttachment downloads at most maxSize bytes of the attachment,
+// and reports whether the attachment is larger.
+func downloadAttachment(
+	ctx context.Context, attachment *discordgo.MessageAttachment, maxSize int,
+) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, attachment.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to download %s: %s", attachment.Filename, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(data) > maxSize {
+		return data[:maxSize], true, nil
+	}
+
+	return data, false, nil
+}
+
+// isTextAttachment reports whether the attachment is a text or code file, and returns the language of its block.
+func isTextAttachment(attachment *discordgo.MessageAttachment) (string, bool) {
+	if language, ok := textLanguages[strings.ToLower(path.Ext(attachment.Filename))]; ok {
+		return language, true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+
+	return "", strings.HasPrefix(mediaType, "text/") || mediaType == "application/json"
+}
+
+// fencedBlock wraps the content in a fenced block titled with the file name,
+// with a fence longer than the backtick runs of the content.
+func fencedBlock(filename, language, content string) string {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+
+	return fmt.Sprintf("%s\n%s%s\n%s\n%s", filename, fence, language, strings.TrimRight(content, "\n"), fence)
+}
+
+// attachmentBlocks downloads the text attachments as fenced blocks, each of at most maxSize bytes,
+// and reports whether any of them is truncated. Binary files are skipped.
+func attachmentBlocks(
+	ctx context.Context, attachments []*discordgo.MessageAttachment, maxSize int,
+) ([]string, bool, error) {
+	var blocks []string
+	var truncated bool
+
+	for _, attachment := range attachments {
+		language, ok := isTextAttachment(attachment)
+		if !ok {
+			continue
+		}
+
+		data, isTruncated, err := downloadAttachment(ctx, attachment, maxSize)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if isTruncated {
+			// The cut may split the last character.
+			for i := 0; i < utf8.UTFMax-1 && !utf8.Valid(data); i++ {
+				data = data[:len(data)-1]
+			}
+
+			truncated = true
+		}
+
+		if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+
+		blocks = append(blocks, fencedBlock(attachment.Filename, language, string(data)))
+	}
+
+	return blocks, truncated, nil
+}
+
+// withBlocks returns the content followed by the blocks.
+func withBlocks(content string, blocks []string) string {
+	if len(blocks) == 0 {
+		return content
+	}
+
+	return strings.TrimSpace(strings.Join(append([]string{content}, blocks...), "\n\n"))
+}
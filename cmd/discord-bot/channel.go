@@ -5,193 +5,629 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
+	tiktoken "github.com/pkoukk/tiktoken-go"
 	openai "github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
 
+	"chatbot-gpt/internal/cost"
 	"chatbot-gpt/internal/locale"
+	"chatbot-gpt/internal/moderation"
+	"chatbot-gpt/internal/provider"
+	"chatbot-gpt/internal/tool"
 )
 
+// errUnknownProvider is returned when a model refers to a provider that is not configured.
+var errUnknownProvider = errors.New("unknown provider")
+
+// errOutputBlocked is returned when the reply of the model is blocked by moderation.
+var errOutputBlocked = errors.New("output blocked by moderation")
+
+// errStreamInterrupted is returned when the stream of an answer fails before its end.
+var errStreamInterrupted = errors.New("stream interrupted")
+
+// interruptedMarker marks the answers interrupted by a stream error in the history, so that the model knows they are incomplete.
+const interruptedMarker = "\n\n[truncated: the connection was interrupted]"
+
+// providerForModel returns the provider serving the given model.
+func providerForModel(modelID string) (provider.Provider, error) {
+	capability, _ := ModelRegistry.Lookup(modelID)
+
+	if p, ok := Providers[capability.Provider]; ok {
+		return p, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errUnknownProvider, capability.Provider)
+}
+
+// createChatStreamWithFallback creates a chat stream with the first model of the chain that is available.
+// The next model is tried only if the previous one failed with a transient error.
+// It returns the stream and the model that answered.
+func createChatStreamWithFallback(
+	ctx context.Context, modelIDs []string, request openai.ChatCompletionRequest,
+) (provider.ChatStream, string, error) {
+	var lastErr error
+	maxTokens := request.MaxTokens
+	messages := request.Messages
+	tools := request.Tools
+	responseFormat := request.ResponseFormat
+
+	for _, modelID := range modelIDs {
+		chatProvider, providerErr := providerForModel(modelID)
+		if providerErr != nil {
+			return nil, "", providerErr
+		}
+
+		capability, _ := ModelRegistry.Lookup(modelID)
+		request.Model = modelID
+		request.MaxTokens = min(maxTokens, capability.MaxOutputTokens)
+		request.Stream = capability.SupportsStreaming
+		request.Messages = adaptImages(adaptToolMessages(adaptSystemRole(messages, capability), capability), capability)
+		request.Tools = nil
+
+		if capability.SupportsTools {
+			request.Tools = tools
+		}
+
+		// The JSON mode is left out for the models without it, which would reject the request.
+		request.ResponseFormat = responseFormat
+		if !capability.SupportsJSONMode && responseFormat != nil &&
+			responseFormat.Type == openai.ChatCompletionResponseFormatTypeJSONObject {
+			request.ResponseFormat = nil
+		}
+
+		stream, err := chatProvider.CreateChatStream(ctx, request)
+		if err == nil {
+			return stream, modelID, nil
+		}
+
+		lastErr = err
+		if !provider.IsTransient(err) || ctx.Err() != nil {
+			break
+		}
+
+		Logger.Warn("model is unavailable, falling back", zap.String("modelID", modelID), zap.Error(err))
+	}
+
+	return nil, "", lastErr
+}
+
+// tokenizers caches the tokenizers of models.
+var tokenizers = struct {
+	sync.Mutex
+	m map[string]*tiktoken.Tiktoken
+}{m: make(map[string]*tiktoken.Tiktoken)}
+
+// tokenizerForModel returns the tokenizer of the given model.
+// Models unknown to tiktoken use the token prediction model.
+func tokenizerForModel(modelID string) *tiktoken.Tiktoken {
+	tokenizers.Lock()
+	defer tokenizers.Unlock()
+
+	if tkm, ok := tokenizers.m[modelID]; ok {
+		return tkm
+	}
+
+	tkm, err := tiktoken.EncodingForModel(modelID)
+	if err != nil {
+		tkm = TokenPredictionModel
+	}
+
+	tokenizers.m[modelID] = tkm
+
+	return tkm
+}
+
 // predictTokens predicts the number of tokens usage for the given message.
-func predictTokens(messages []openai.ChatCompletionMessage, includeAssistantSignal bool) int {
+func predictTokens(modelID string, messages []openai.ChatCompletionMessage, includeAssistantSignal bool) int {
 	numTokens := 0
 
 	if includeAssistantSignal {
 		numTokens += 3
 	}
 
-	tokensPerMessage := 0
-	tokensPerName := 0
+	tokensPerMessage := 3
+	tokensPerName := 1
 
-	switch Model.ID {
-	case "gpt-3.5-turbo":
-	case "gpt-3.5-turbo-0301":
+	if modelID == "gpt-3.5-turbo-0301" {
 		tokensPerMessage = 4
 		tokensPerName = -1
-	case "gpt-4":
-	case "gpt-4-0314":
-		tokensPerMessage = 3
-		tokensPerName = 1
-	case "gpt-4-32k":
-	case "gpt-4-32k-0314":
-		tokensPerMessage = 3
-		tokensPerName = 1
 	}
 
+	tokenizer := tokenizerForModel(modelID)
+
 	for _, message := range messages {
 		numTokens += tokensPerMessage
 		if message.Name != "" {
 			numTokens += tokensPerName
 		}
 
-		numTokens += len(TokenPredictionModel.Encode(message.Role, nil, nil))
-		numTokens += len(TokenPredictionModel.Encode(message.Content, nil, nil))
+		numTokens += len(tokenizer.Encode(message.Role, nil, nil))
+		numTokens += len(tokenizer.Encode(message.Content, nil, nil))
+
+		for _, part := range message.MultiContent {
+			switch part.Type {
+			case openai.ChatMessagePartTypeText:
+				numTokens += len(tokenizer.Encode(part.Text, nil, nil))
+			case openai.ChatMessagePartTypeImageURL:
+				numTokens += imageTokens(part.ImageURL)
+			}
+		}
+
+		for _, toolCall := range message.ToolCalls {
+			numTokens += len(tokenizer.Encode(toolCall.Function.Name, nil, nil))
+			numTokens += len(tokenizer.Encode(toolCall.Function.Arguments, nil, nil))
+		}
 	}
 
 	return numTokens
 }
 
 // getTokenCostPriceString returns the cost price of the given number of tokens.
-func getTokenCostPriceString(numPromptTokens int, numSampledTokens int) string {
-	numDollars := CostCalculator.GetPromptCost(
+func getTokenCostPriceString(modelID string, numPromptTokens int, numSampledTokens int) string {
+	costCalculator := cost.NewCalculator(modelID)
+	numDollars := costCalculator.GetPromptCost(
 		numPromptTokens,
-	) + CostCalculator.GetSampledCost(
+	) + costCalculator.GetSampledCost(
 		numSampledTokens,
 	)
-	numYen := numDollars * 138.31
-	numYuan := numDollars * 7.05
 
-	return fmt.Sprintf(
-		"💠 (%d, %d)  →  🇺🇸 $%.3f / 🇯🇵 ￥%.3f / 🇨🇳 ￥%.3f",
-		numPromptTokens, numSampledTokens, numDollars, numYen, numYuan,
-	)
+	return fmt.Sprintf("💠 (%d, %d)  →  %s", numPromptTokens, numSampledTokens, formatPrices(numDollars))
 }
 
-// storeInteraction stores the interaction between the user and the assistant.
-func storeInteraction(
-	userID string, userMessage *openai.ChatCompletionMessage, numUserMessageToken int,
-	assistantMessage *openai.ChatCompletionMessage, numAssistantMessageToken int,
-) error {
-	if err := MessageDatabase.Store(userID, userMessage, numUserMessageToken); err != nil {
-		Logger.Debug("failed to store response message", zap.Error(err))
-		return err
+// formatPrices formats the cost in US dollars and in the configured currencies.
+func formatPrices(numDollars float64) string {
+	prices := []string{fmt.Sprintf("🇺🇸 $%.3f", numDollars)}
+	for _, currency := range Currencies {
+		symbol := currency.Symbol
+		if symbol == "" {
+			symbol = currency.Code + " "
+		}
+
+		prices = append(prices, fmt.Sprintf("%s%.3f", symbol, numDollars*currency.Rate))
+	}
+
+	return strings.Join(prices, " / ")
+}
+
+// formatFootnotes formats the sources cited in the content as footnote links, keeping their numbers.
+func formatFootnotes(content string, sources []tool.Source) string {
+	var footnotes string
+
+	for i, source := range sources {
+		if !strings.Contains(content, fmt.Sprintf("[%d]", i+1)) {
+			continue
+		}
+
+		title := source.Title
+		if title == "" {
+			title = source.URL
+		}
+
+		// The angle brackets suppress the link previews.
+		title = strings.NewReplacer("[", "(", "]", ")").Replace(title)
+		footnotes += fmt.Sprintf("\n`[%d]` [%s](<%s>)", i+1, title, source.URL)
+	}
+
+	if footnotes == "" {
+		return ""
+	}
+
+	return "\n" + footnotes
+}
+
+// storeInteraction stores the messages of the interaction between the user and the assistant.
+func storeInteraction(userID, modelID string, messages ...openai.ChatCompletionMessage) error {
+	for _, message := range messages {
+		numToken := predictTokens(modelID, []openai.ChatCompletionMessage{message}, false)
+		if err := MessageDatabase.Store(userID, &message, numToken); err != nil {
+			Logger.Debug("failed to store response message", zap.Error(err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discordResponse is a response sent to Discord, edited as its content is received.
+type discordResponse struct {
+	writer       responseWriter
+	interval     time.Duration
+	current      *discordgo.Message
+	content      string
+	lastSentTime time.Time
+
+	// previous are the IDs of the messages before the current one, filled up with the response.
+	previous []string
+
+	// components are the components of the current message, such as the stop button while it is generated.
+	components []discordgo.MessageComponent
+
+	// sent reports whether the placeholder message has been edited into the response.
+	sent bool
+
+	// buffered reports whether the content is held back until the response is finished,
+	// so that the reply can be moderated before it is shown.
+	buffered bool
+}
+
+// update edits the current message with its content, continuing in new messages if it is too long.
+func (r *discordResponse) update() error {
+	for len(r.content) > 2000 {
+		split := splitIndex(r.content, 1950)
+		if err := r.writer.EditComplex(r.current.ID, r.content[:split], nil, nil); err != nil {
+			return err
+		}
+
+		// The line break or the space the content is split at is dropped.
+		r.content = r.content[split:]
+		if strings.HasPrefix(r.content, "\n") || strings.HasPrefix(r.content, " ") {
+			r.content = r.content[1:]
+		}
+
+		newMessage, newMessageErr := r.writer.Send(r.content[:splitIndex(r.content, 2000)])
+		if newMessageErr != nil {
+			return newMessageErr
+		}
+
+		r.previous = append(r.previous, r.current.ID)
+		r.current = newMessage
 	}
 
-	if err := MessageDatabase.Store(userID, assistantMessage, numAssistantMessageToken); err != nil {
-		Logger.Debug("failed to store response message", zap.Error(err))
+	if err := r.writer.EditComplex(r.current.ID, r.content, r.components, nil); err != nil {
 		return err
 	}
 
+	r.lastSentTime = time.Now()
+	r.sent = true
+
 	return nil
 }
 
-// sendDiscordResponseWithStream sends the response to the user via Discord.
-func sendDiscordResponseWithStream(
-	stream *openai.ChatCompletionStream, interval time.Duration,
-	s *discordgo.Session, guildID, channelID, messageID string,
-	lang locale.Language, numPromptTokens int,
-) (*openai.ChatCompletionMessage, int, error) {
-	var currentResponse *discordgo.Message
-	var currentResponseString string
-	var allResponseString string
-
-	resp, respErr := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-		Content: Localizer.Fetch("wait_for_response", lang),
-		Reference: &discordgo.MessageReference{
-			MessageID: messageID,
-			GuildID:   guildID,
-		},
-	})
+// splitIndex returns where to split the content so that its first part is within the limit,
+// preferring the last line break, then the last space, and never splitting a character.
+func splitIndex(content string, limit int) int {
+	if len(content) <= limit {
+		return len(content)
+	}
 
-	if respErr != nil {
-		return nil, 0, respErr
+	if i := strings.LastIndexByte(content[:limit], '\n'); i > 0 {
+		return i
 	}
 
-	currentResponse = resp
+	if i := strings.LastIndexByte(content[:limit], ' '); i > 0 {
+		return i
+	}
 
-	lastSentTime := time.Now()
+	i := limit
+	for i > 0 && !utf8.RuneStart(content[i]) {
+		i--
+	}
 
-	tryUpdateResponse := func() error {
-		if len(currentResponseString) > 2000 {
-			firstPart := ""
+	return i
+}
 
-			for i := 1950; i > 0; i-- {
-				if currentResponseString[i] == '\n' {
-					firstPart = currentResponseString[:i]
-					currentResponseString = currentResponseString[i+1:]
-					break
-				}
-			}
+// write appends the content to the response, and updates the message once the interval has passed.
+func (r *discordResponse) write(content string) error {
+	r.content += content
 
-			if _, err := s.ChannelMessageEdit(currentResponse.ChannelID, currentResponse.ID, firstPart); err != nil {
-				return err
-			}
+	if time.Since(r.lastSentTime) > r.interval && len(r.content) > 0 {
+		return r.flush()
+	}
 
-			newMessage, newMessageErr := s.ChannelMessageSendComplex(
-				channelID,
-				&discordgo.MessageSend{
-					Content: currentResponseString,
-					Reference: &discordgo.MessageReference{
-						MessageID: messageID,
-						GuildID:   guildID,
-					},
-				},
-			)
-
-			if newMessageErr != nil {
-				return newMessageErr
-			}
+	return nil
+}
 
-			currentResponse = newMessage
-		} else {
-			if _, err := s.ChannelMessageEdit(channelID, currentResponse.ID, currentResponseString); err != nil {
-				return err
-			}
+// flush updates the message with the content received so far, unless the response is buffered.
+func (r *discordResponse) flush() error {
+	if r.buffered {
+		return nil
+	}
+
+	return r.update()
+}
+
+// retract replaces the response with the content, deleting the messages before the current one.
+func (r *discordResponse) retract(content string) error {
+	for _, messageID := range r.previous {
+		if err := r.writer.Delete(messageID); err != nil {
+			return err
 		}
+	}
 
-		lastSentTime = time.Now()
+	r.previous = nil
+	r.content = content
+	r.components = nil
 
-		return nil
+	return r.update()
+}
+
+// finish edits the last message of the response with the components and the files.
+func (r *discordResponse) finish(components []discordgo.MessageComponent, files []*discordgo.File) error {
+	if len(r.content) > 2000 {
+		if err := r.update(); err != nil {
+			return err
+		}
 	}
 
+	return r.writer.EditComplex(r.current.ID, r.content, components, files)
+}
+
+// newDiscordResponse creates a response starting from the placeholder message.
+func newDiscordResponse(writer responseWriter, placeholder *discordgo.Message, interval time.Duration) *discordResponse {
+	return &discordResponse{
+		writer:       writer,
+		interval:     interval,
+		current:      placeholder,
+		lastSentTime: time.Now(),
+	}
+}
+
+// streamAnswer is an answer received from a stream.
+type streamAnswer struct {
+	content      string
+	toolCalls    []openai.ToolCall
+	finishReason openai.FinishReason
+}
+
+// receiveStream receives the stream until its end, writing the content of each delta.
+// The chunks without choices, such as the usage chunks, are skipped.
+// If the context is canceled, the content received so far is returned with errGenerationStopped,
+// and if the stream fails, it is returned with errStreamInterrupted, without the incomplete tool calls.
+func receiveStream(ctx context.Context, stream provider.ChatStream, write func(content string) error) (streamAnswer, error) {
+	var answer streamAnswer
+
 	for {
 		resp, streamErr := stream.Recv()
-		if errors.Is(streamErr, io.EOF) || resp.Choices[0].FinishReason == "stop" {
-			break
+		if streamErr != nil && ctx.Err() != nil {
+			return streamAnswer{content: answer.content}, errGenerationStopped
 		}
 
-		if len(resp.Choices) > 0 {
-			content := resp.Choices[0].Delta.Content
-			if len(content) > 0 {
-				currentResponseString += content
-				allResponseString += content
-			}
+		if errors.Is(streamErr, io.EOF) {
+			return answer, nil
+		}
+
+		if streamErr != nil {
+			return streamAnswer{content: answer.content}, fmt.Errorf("%w: %w", errStreamInterrupted, streamErr)
+		}
+
+		if len(resp.Choices) == 0 {
+			continue
 		}
 
-		if time.Since(lastSentTime) > interval {
-			if len(currentResponseString) > 0 {
-				if err := tryUpdateResponse(); err != nil {
-					return nil, 0, err
+		choice := resp.Choices[0]
+		answer.content += choice.Delta.Content
+		answer.toolCalls = mergeToolCallDeltas(answer.toolCalls, choice.Delta.ToolCalls)
+
+		if choice.FinishReason != "" {
+			answer.finishReason = choice.FinishReason
+		}
+
+		if err := write(choice.Delta.Content); err != nil {
+			return answer, err
+		}
+	}
+}
+
+// finishNotices are the notices of the finish reasons of answers cut off by the model.
+var finishNotices = map[openai.FinishReason]string{
+	openai.FinishReasonLength:        "finish_length",
+	openai.FinishReasonContentFilter: "finish_content_filter",
+}
+
+// answerText returns the content of an answer without the marker of an incomplete answer.
+func answerText(content string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(content, stoppedMarker), interruptedMarker)
+}
+
+// answer is the answer of a model to a request.
+type answer struct {
+	// messages are the messages of the answer, including the tool calls and their results.
+	messages []openai.ChatCompletionMessage
+
+	// modelID is the model that answered.
+	modelID string
+
+	// finishReason is the reason the model stopped answering.
+	finishReason openai.FinishReason
+}
+
+// sendDiscordResponse answers the request via Discord, calling the tools requested by the model
+// until it answers or the tool iterations are used up.
+// An answer cut off by the completion token limit is continued by the next requests, up to the max continuations
+// of the channel, into the same response and the same message of the history.
+func sendDiscordResponse(
+	ctx context.Context, modelChain []string, request openai.ChatCompletionRequest, channelConfig ChannelConfig,
+	scope tool.Scope, response *discordResponse, lang locale.Language,
+	numPromptTokens, promptTokenLimit int, extraUsage openai.Usage, notices, footers []string,
+	checkOutput func(content string) moderation.Action,
+) (answer, error) {
+	var messages []openai.ChatCompletionMessage
+	usage := extraUsage
+	sources := &tool.Sources{}
+	modelID := modelChain[0]
+
+	// continued is the content of the answer before its continuation.
+	var continued string
+	continuations := 0
+
+	// finish completes the response with the footnotes, the notices and the footer of the answer.
+	finish := func(content string, finishReason openai.FinishReason) (answer, error) {
+		if checkOutput != nil {
+			switch checkOutput(content) {
+			case moderation.Block:
+				if err := response.retract(Localizer.Fetch("moderation_output_blocked", lang)); err != nil {
+					return answer{}, err
 				}
+
+				return answer{}, errOutputBlocked
+			case moderation.Warn:
+				notices = append(notices, "moderation_output_warning")
 			}
 		}
-	}
 
-	message := &openai.ChatCompletionMessage{
-		Content: allResponseString,
-		Role:    openai.ChatMessageRoleAssistant,
-	}
+		response.content += formatFootnotes(content, sources.List())
 
-	numSampledTokens := predictTokens([]openai.ChatCompletionMessage{*message}, false)
-	currentResponseString += "\n\n" + getTokenCostPriceString(numPromptTokens, numSampledTokens)
+		for _, notice := range notices {
+			response.content += "\n\n⚠️ " + Localizer.Fetch(notice, lang)
+		}
+
+		response.content += "\n\n🤖 " + modelID + "  "
+		if continuations > 0 {
+			response.content += fmt.Sprintf("⏩ %d  ", continuations)
+		}
+
+		response.content += getTokenCostPriceString(modelID, usage.PromptTokens, usage.CompletionTokens)
+
+		for _, footer := range footers {
+			response.content += "\n" + footer
+		}
+
+		response.components = nil
+		if err := response.update(); err != nil {
+			return answer{}, err
+		}
 
-	if err := tryUpdateResponse(); err != nil {
-		return nil, 0, err
+		return answer{messages: messages, modelID: modelID, finishReason: finishReason}, nil
 	}
 
-	return message, numSampledTokens, nil
+	for iteration := 0; ; iteration++ {
+		if iteration >= channelConfig.MaxToolIterations {
+			request.Tools = nil
+		}
+
+		stream, answeringModelID, chatErr := createChatStreamWithFallback(ctx, modelChain, request)
+		if chatErr != nil {
+			// The answer is kept if it fails to be continued.
+			if ctx.Err() == nil && continued == "" {
+				return answer{}, chatErr
+			}
+
+			marker := interruptedMarker
+			if ctx.Err() != nil {
+				// The reply was stopped before the model started answering.
+				marker = stoppedMarker
+				notices = append(notices, "generation_stopped")
+			} else {
+				Logger.Debug("failed to continue the answer", zap.String("modelID", modelID), zap.Error(chatErr))
+				notices = append(notices, "stream_interrupted")
+			}
+
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: strings.TrimSpace(continued + marker),
+			})
+
+			return finish(continued, "")
+		}
+
+		modelID = answeringModelID
+
+		received, streamErr := receiveStream(ctx, stream, response.write)
+		streamUsage := stream.Usage()
+		stream.Close()
+
+		// The partial answer of an interrupted stream is kept unless nothing was received.
+		stopped := errors.Is(streamErr, errGenerationStopped)
+		interrupted := errors.Is(streamErr, errStreamInterrupted) && continued+received.content != ""
+		if streamErr != nil && !stopped && !interrupted {
+			return answer{}, streamErr
+		}
+
+		content, toolCalls := received.content, received.toolCalls
+		assistantMessage := openai.ChatCompletionMessage{
+			Role:      openai.ChatMessageRoleAssistant,
+			Content:   content,
+			ToolCalls: toolCalls,
+		}
+
+		// Only the tokens generated before the reply was stopped are predicted and priced.
+		numSampledTokens := predictTokens(modelID, []openai.ChatCompletionMessage{assistantMessage}, false)
+
+		// Prefer the usage reported by the provider over the prediction for pricing.
+		if streamUsage != nil {
+			usage.PromptTokens += streamUsage.PromptTokens
+			usage.CompletionTokens += streamUsage.CompletionTokens
+		} else {
+			usage.PromptTokens += numPromptTokens
+			usage.CompletionTokens += numSampledTokens
+		}
+
+		switch {
+		case stopped:
+			notices = append(notices, "generation_stopped")
+			assistantMessage.Content += stoppedMarker
+		case interrupted:
+			Logger.Debug("stream interrupted", zap.String("modelID", modelID), zap.Error(streamErr))
+			notices = append(notices, "stream_interrupted")
+			assistantMessage.Content += interruptedMarker
+		}
+
+		if len(toolCalls) == 0 && received.finishReason == openai.FinishReasonLength && !stopped &&
+			continuations < channelConfig.MaxContinuations {
+			continuation := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: continuePrompt}
+			numContinuationTokens := numSampledTokens + predictTokens(modelID, []openai.ChatCompletionMessage{continuation}, false)
+
+			// The answer is continued only if the next request still fits the prompt token limit of the channel,
+			// which leaves room for the completion in the context window of the model.
+			if numPromptTokens+numContinuationTokens <= promptTokenLimit {
+				continuations++
+				continued += content
+				numPromptTokens += numContinuationTokens
+				request.Messages = append(request.Messages, assistantMessage, continuation)
+				request.Tools = nil
+
+				continue
+			}
+		}
+
+		// The rounds of a continued answer are stored as a single message.
+		assistantMessage.Content = continued + assistantMessage.Content
+		messages = append(messages, assistantMessage)
+
+		if len(toolCalls) == 0 {
+			if notice, ok := finishNotices[received.finishReason]; ok {
+				notices = append(notices, notice)
+			}
+
+			return finish(continued+content, received.finishReason)
+		}
+
+		if response.content != "" && !strings.HasSuffix(response.content, "\n") {
+			response.content += "\n"
+		}
+
+		for _, toolCall := range toolCalls {
+			response.content += "*🔧 " + fmt.Sprintf(Localizer.Fetch("tool_calling", lang), toolCall.Function.Name) + "*\n"
+		}
+
+		if err := response.flush(); err != nil {
+			return answer{}, err
+		}
+
+		// The model may ask for any tool, so only the tools of the channel are called.
+		results := ToolRegistry.CallAll(
+			tool.WithSources(tool.WithScope(ctx, scope), sources), toolCalls, channelConfig.Tools,
+			time.Duration(channelConfig.ToolTimeout)*time.Millisecond,
+		)
+
+		numPromptTokens += numSampledTokens
+		if fitToolResults(modelID, results, promptTokenLimit-numPromptTokens) {
+			// No room is left for more tool results, so the model has to answer with what it has.
+			request.Tools = nil
+		}
+
+		numPromptTokens += predictTokens(modelID, results, false)
+		request.Messages = append(request.Messages, assistantMessage)
+		request.Messages = append(request.Messages, results...)
+		messages = append(messages, results...)
+	}
 }
 
 // sendErrorMessage sends an error message.
@@ -217,8 +653,14 @@ func sendErrorMessage(
 
 // chatChanel handles the chat channel.
 func chatChanel(s *discordgo.Session, data *discordgo.MessageCreate) bool {
+	return answerMessage(s, data, false)
+}
+
+// answerMessage answers the message in the chat channel.
+// The message of a continuation is a transient instruction, which is sent to the model but not stored in the history.
+func answerMessage(s *discordgo.Session, data *discordgo.MessageCreate, continuation bool) bool {
 	// Only respond to messages that start with the prefix
-	if data.Author.ID == s.State.User.ID {
+	if data.Author.ID == s.State.User.ID || isBotWebhook(data.ChannelID, data.WebhookID) {
 		return false
 	}
 
@@ -227,7 +669,7 @@ func chatChanel(s *discordgo.Session, data *discordgo.MessageCreate) bool {
 		return false
 	}
 
-	channelConfig, cConfigOk := serverConfig.ChatChannels[data.ChannelID]
+	channelConfig, cConfigOk := chatChannelConfig(serverConfig, data.ChannelID)
 	if !cConfigOk {
 		return false
 	}
@@ -237,30 +679,285 @@ func chatChanel(s *discordgo.Session, data *discordgo.MessageCreate) bool {
 		return false
 	}
 
+	persona := selectedPersona(data.ChannelID, channelConfig)
+	writer := newResponseWriter(s, data, persona)
+
+	placeholder, placeholderErr := writer.Send(Localizer.Fetch("wait_for_response", serverConfig.Language))
+	if placeholderErr != nil {
+		Logger.Debug("failed to send placeholder message", zap.Error(placeholderErr))
+		return true
+	}
+
+	response := newDiscordResponse(
+		writer, placeholder, time.Duration(channelConfig.MessageEditInterval)*time.Millisecond,
+	)
+
+	// The requests of the response are canceled by the stop button.
+	ctx, stop := startGeneration(placeholder.ID, data.Author.ID)
+	defer stop()
+
+	// The stop button is shown from the start, so that the request can be stopped while queued or prepared.
+	response.components = stopComponents(placeholder.ID, serverConfig.Language)
+	if err := writer.EditComplex(placeholder.ID, placeholder.Content, response.components, nil); err != nil {
+		Logger.Debug("failed to add the stop button", zap.Error(err))
+	}
+
+	// The placeholder is removed if the response fails before it is edited into the response.
+	defer func() {
+		if !response.sent {
+			if err := writer.Delete(placeholder.ID); err != nil {
+				Logger.Debug("failed to delete placeholder message", zap.Error(err))
+			}
+		}
+	}()
+
+	queued := false
+	release, acquireErr := RequestScheduler.Acquire(
+		ctx, data.GuildID, data.Author.ID,
+		func(position int) {
+			queued = true
+			content := fmt.Sprintf(Localizer.Fetch("queue_position", serverConfig.Language), position)
+			if err := writer.Edit(placeholder.ID, content); err != nil {
+				Logger.Debug("failed to update queue position", zap.Error(err))
+			}
+		},
+	)
+	if ctx.Err() != nil {
+		return true
+	}
+
+	if acquireErr != nil {
+		sendErrorMessage(s, data, serverConfig.Language, "error_response")
+		Logger.Debug("failed to wait for the request scheduler", zap.Error(acquireErr))
+		return true
+	}
+
+	defer release()
+
+	if queued {
+		if err := writer.Edit(placeholder.ID, Localizer.Fetch("wait_for_response", serverConfig.Language)); err != nil {
+			Logger.Debug("failed to update placeholder message", zap.Error(err))
+		}
+	}
+
+	modelID := selectedModel(data.ChannelID, data.Author.ID, channelConfig)
+	capability, _ := ModelRegistry.Lookup(modelID)
+	maxTokens := min(channelConfig.CompletionTokenLimit, capability.MaxOutputTokens)
+	promptTokenLimit := min(channelConfig.PromptTokenLimit, capability.ContextWindow-maxTokens)
+
+	var notices []string
+	var usage openai.Usage
+
+	// The text and document attachments are part of the prompt, subject to the over limit strategy.
+	blocks, attachmentTruncated, attachmentErr := attachmentBlocks(
+		ctx, data.Attachments, channelConfig.MaxAttachmentSize,
+	)
+	if ctx.Err() != nil {
+		return true
+	}
+
+	if attachmentErr != nil {
+		sendErrorMessage(s, data, serverConfig.Language, "error_response")
+		Logger.Debug("failed to download text attachments", zap.Error(attachmentErr))
+		return true
+	}
+
+	if attachmentTruncated {
+		notices = append(notices, "attachment_truncated")
+	}
+
+	documents, documentUnreadable := documentBlocks(
+		ctx, data.Attachments, selectedPageRange(data.ChannelID, data.Author.ID),
+	)
+	if ctx.Err() != nil {
+		return true
+	}
+
+	blocks = append(blocks, documents...)
+
+	if documentUnreadable {
+		notices = append(notices, "document_unreadable")
+	}
+
+	// The transcripts of the audio attachments are quoted in the response and used as the prompt.
+	content := data.Content
+	var footers []string
+
+	if attachments := audioAttachments(data.Attachments); len(attachments) > 0 && TranscriptionConfig.ModelID != "" {
+		transcripts, transcriptionErr := transcribeAll(ctx, attachments)
+		if ctx.Err() != nil {
+			return true
+		}
+
+		if transcriptionErr != nil {
+			Logger.Debug("failed to transcribe audio attachments", zap.Error(transcriptionErr))
+
+			if strings.TrimSpace(content) == "" && len(blocks) == 0 {
+				sendErrorMessage(s, data, serverConfig.Language, "transcription_failed")
+				return true
+			}
+
+			notices = append(notices, "transcription_failed")
+		} else {
+			content = withTranscripts(content, transcripts)
+			response.content = quoteTranscripts(transcripts)
+			footers = append(footers, getTranscriptionCostPriceString(transcripts))
+		}
+	}
+
 	newPrompt := openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
-		Content: data.Content,
+		Content: withBlocks(content, blocks),
+	}
+
+	event := moderationEvent{
+		guildID:   data.GuildID,
+		channelID: data.ChannelID,
+		userID:    data.Author.ID,
+		messageID: data.ID,
+		direction: moderationInput,
+		content:   newPrompt.Content,
 	}
 
-	numNewPromptToken := predictTokens([]openai.ChatCompletionMessage{newPrompt}, false)
-	remainingTokens := channelConfig.PromptTokenLimit - 3 - numNewPromptToken
+	var checkOutput func(content string) moderation.Action
 
-	if remainingTokens < 0 {
+	if serverConfig.Moderation.Enable {
+		// The instruction of a continuation is not written by the user, so only its answer is checked.
+		action := moderation.Allow
+		if !continuation {
+			action = moderate(ctx, s, serverConfig, event)
+		}
+
+		if ctx.Err() != nil {
+			return true
+		}
+
+		switch action {
+		case moderation.Block:
+			sendErrorMessage(s, data, serverConfig.Language, "moderation_input_blocked")
+			return true
+		case moderation.Warn:
+			notices = append(notices, "moderation_input_warning")
+		}
+
+		if serverConfig.Moderation.CheckOutput {
+			checkOutput = func(content string) moderation.Action {
+				outputEvent := event
+				outputEvent.direction = moderationOutput
+				outputEvent.content = content
+
+				action := moderate(ctx, s, serverConfig, outputEvent)
+
+				// A held back reply stopped while it is checked is withheld rather than shown unchecked.
+				if ctx.Err() != nil && response.buffered {
+					return moderation.Block
+				}
+
+				return action
+			}
+
+			// A reply which may be blocked is not streamed, so that nobody reads it before it is checked.
+			response.buffered = serverConfig.Moderation.Policy.Blocks()
+		}
+	}
+
+	// The system prompt of the persona is always sent, so it is counted before the history.
+	systemPrompts, systemPromptErr := systemMessages(s, data, persona, serverConfig.Location)
+	if systemPromptErr != nil {
+		sendErrorMessage(s, data, serverConfig.Language, "error_response")
+		Logger.Debug("failed to render system prompt", zap.String("persona", persona.Name), zap.Error(systemPromptErr))
+		return true
+	}
+
+	// The tool definitions are sent along with the prompt, so they are counted as well.
+	tools := ToolRegistry.Definitions(channelConfig.Tools)
+	numSystemPromptToken := predictTokens(modelID, systemPrompts, false) + predictToolTokens(modelID, tools)
+	availableTokens := promptTokenLimit - 3 - numSystemPromptToken
+
+	if availableTokens <= 0 {
 		sendErrorMessage(s, data, serverConfig.Language, "token_limit_reached")
+		Logger.Debug("system prompt exceeds the prompt token limit", zap.String("persona", persona.Name))
 		return true
 	}
 
-	var prompts []openai.ChatCompletionMessage
-	previousMessages, tokens, fetchErr := MessageDatabase.Fetch(data.Author.ID, remainingTokens)
+	// The images are sent along with the prompt to models with vision, so only the text is fitted.
+	var images []openai.ChatMessagePart
+	numImageTokens := 0
+
+	if attachments := imageAttachments(data.Attachments); len(attachments) > 0 {
+		if capability.SupportsVision {
+			parts, imageErr := imageParts(ctx, attachments, channelConfig.ImageDetail)
+			if ctx.Err() != nil {
+				return true
+			}
+
+			if imageErr != nil {
+				sendErrorMessage(s, data, serverConfig.Language, "error_response")
+				Logger.Debug("failed to download image attachments", zap.Error(imageErr))
+				return true
+			}
+
+			images = parts
+			for _, image := range images {
+				numImageTokens += imageTokens(image.ImageURL)
+			}
+
+			if numImageTokens >= availableTokens {
+				sendErrorMessage(s, data, serverConfig.Language, "token_limit_reached")
+				return true
+			}
+		} else {
+			notices = append(notices, "images_unsupported")
+		}
+	}
+
+	numNewPromptToken := predictTokens(modelID, []openai.ChatCompletionMessage{newPrompt}, false)
+	remainingTokens := availableTokens - numImageTokens - numNewPromptToken
+
+	if remainingTokens < 0 {
+		fittedPrompt, fitUsage, notice, fitErr := fitPrompt(
+			ctx, modelID, channelConfig.OverLimitStrategy, newPrompt,
+			availableTokens-numImageTokens, maxTokens, data.Author.ID,
+		)
+		if ctx.Err() != nil {
+			return true
+		}
+
+		if fitErr != nil {
+			sendErrorMessage(s, data, serverConfig.Language, "error_response")
+			Logger.Debug("failed to fit prompt", zap.Error(fitErr))
+			return true
+		}
+
+		if fittedPrompt == nil {
+			sendErrorMessage(s, data, serverConfig.Language, "token_limit_reached")
+			return true
+		}
+
+		newPrompt = *fittedPrompt
+		notices = append(notices, notice)
+		usage = fitUsage
+	}
+
+	newPrompt = withImages(newPrompt, images)
+	numNewPromptToken = predictTokens(modelID, []openai.ChatCompletionMessage{newPrompt}, false)
+	remainingTokens = availableTokens - numNewPromptToken
+
+	prompts := systemPrompts
+	key := historyKey(data.ChannelID, data.Author.ID)
+	previousMessages, tokens, fetchErr := MessageDatabase.Fetch(key, remainingTokens)
 	if fetchErr != nil {
 		Logger.Debug("failed to fetch previous messages", zap.Error(fetchErr))
 		return true
 	}
 
+	var history []openai.ChatCompletionMessage
 	for i := len(previousMessages) - 1; i >= 0; i-- {
-		prompts = append(prompts, *previousMessages[i])
+		history = append(history, *previousMessages[i])
 	}
 
+	prompts = append(prompts, trimToolMessages(history)...)
+
 	prompts = append(prompts, newPrompt)
 
 	Logger.Debug(
@@ -269,42 +966,83 @@ func chatChanel(s *discordgo.Session, data *discordgo.MessageCreate) bool {
 		zap.Int("numNewPromptToken", numNewPromptToken),
 	)
 
-	stream, openAIChatErr := OpenAIClient.CreateChatCompletionStream(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			MaxTokens: channelConfig.CompletionTokenLimit,
-			Model:     Model.ID,
-			Messages:  prompts,
-			User:      data.Author.ID,
-		},
-	)
+	modelChain := []string{modelID}
+	for _, fallbackModel := range channelConfig.FallbackModels {
+		if !slices.Contains(modelChain, fallbackModel) {
+			modelChain = append(modelChain, fallbackModel)
+		}
+	}
 
-	if openAIChatErr != nil {
-		sendErrorMessage(s, data, serverConfig.Language, "error_response")
-		Logger.Debug("failed to chat with OpenAI", zap.Error(openAIChatErr))
+	request := openai.ChatCompletionRequest{
+		MaxTokens: maxTokens,
+		Messages:  prompts,
+		Tools:     tools,
+		User:      data.Author.ID,
+	}
+	applySampling(&request, channelSampling(channelConfig, persona))
+
+	ans, responseErr := sendDiscordResponse(
+		ctx, modelChain, request, channelConfig,
+		tool.Scope{GuildID: data.GuildID, ChannelID: data.ChannelID, UserID: data.Author.ID},
+		response, serverConfig.Language,
+		tokens+numSystemPromptToken+numNewPromptToken+3, promptTokenLimit, usage, notices, footers, checkOutput,
+	)
+	if errors.Is(responseErr, errOutputBlocked) {
 		return true
 	}
 
-	defer stream.Close()
+	if responseErr != nil {
+		// The stop button of the partial response is removed.
+		if response.sent {
+			if err := response.finish(nil, nil); err != nil {
+				Logger.Debug("failed to remove the stop button", zap.Error(err))
+			}
+		}
 
-	responseMessage, numResponseMessage, discordResponseErr := sendDiscordResponseWithStream(
-		stream, time.Duration(channelConfig.MessageEditInterval)*time.Millisecond, s,
-		data.GuildID, data.ChannelID, data.ID, serverConfig.Language, tokens+numNewPromptToken+3)
-	if discordResponseErr != nil {
 		sendErrorMessage(s, data, serverConfig.Language, "error_response")
-		Logger.Debug("failed to send Discord response", zap.Error(discordResponseErr))
+		Logger.Debug("failed to send Discord response", zap.Error(responseErr))
 		return true
 	}
 
+	storedPrompt := newPrompt
+	if channelConfig.ImageHistory == imageHistoryDrop {
+		storedPrompt = withoutImages(newPrompt)
+	}
+
 	// Store the bot response in the database
-	if err := storeInteraction(
-		data.Author.ID,
-		&newPrompt, numNewPromptToken,
-		responseMessage, numResponseMessage,
-	); err != nil {
+	interaction := append([]openai.ChatCompletionMessage{storedPrompt}, ans.messages...)
+	if continuation {
+		interaction = ans.messages
+	}
+
+	if err := storeInteraction(key, ans.modelID, interaction...); err != nil {
 		Logger.Debug("failed to store interaction", zap.Error(err))
 	}
 
+	r := reply{
+		message:      data.Message,
+		text:         answerText(ans.messages[len(ans.messages)-1].Content),
+		historyKey:   key,
+		numMessages:  len(interaction),
+		depth:        MessageDatabase.Count(key),
+		finishReason: ans.finishReason,
+		continuation: continuation,
+	}
+
+	var files []*discordgo.File
+	if SpeechConfig.ModelID != "" {
+		if file := speechAttachment(ctx, response, r.text, data.ChannelID, data.Author.ID, channelConfig); file != nil {
+			files = append(files, file)
+		}
+	}
+
+	withSpeech := SpeechConfig.ModelID != "" && len(files) == 0
+	if err := response.finish(replyComponents(r, data.ChannelID, withSpeech, serverConfig.Language), files); err != nil {
+		Logger.Debug("failed to add the buttons of the reply", zap.Error(err))
+	}
+
+	rememberReply(response.current.ID, r)
+
 	return true
 }
 
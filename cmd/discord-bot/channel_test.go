@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+// fakeStream is a chat stream replaying scripted responses, then returning its error.
+type fakeStream struct {
+	responses []openai.ChatCompletionStreamResponse
+	err       error
+}
+
+func (f *fakeStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if len(f.responses) == 0 {
+		return openai.ChatCompletionStreamResponse{}, f.err
+	}
+
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+
+	return resp, nil
+}
+
+func (f *fakeStream) Close() {}
+
+func (f *fakeStream) Usage() *openai.Usage {
+	return nil
+}
+
+// delta returns a stream response of the content and the finish reason.
+func delta(content string, finishReason openai.FinishReason) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{
+			{Delta: openai.ChatCompletionStreamChoiceDelta{Content: content}, FinishReason: finishReason},
+		},
+	}
+}
+
+// toolCallDelta returns a stream response of a part of the tool call at the index.
+func toolCallDelta(index int, id, name, arguments string) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta: openai.ChatCompletionStreamChoiceDelta{
+					ToolCalls: []openai.ToolCall{
+						{Index: &index, ID: id, Function: openai.FunctionCall{Name: name, Arguments: arguments}},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestReceiveStream(t *testing.T) {
+	errNetwork := errors.New("connection reset")
+
+	tests := []struct {
+		name         string
+		stream       *fakeStream
+		content      string
+		finishReason openai.FinishReason
+		toolCalls    string
+		err          error
+	}{
+		{
+			name:         "end of stream",
+			stream:       &fakeStream{responses: []openai.ChatCompletionStreamResponse{delta("Hel", ""), delta("lo", "stop")}, err: io.EOF},
+			content:      "Hello",
+			finishReason: openai.FinishReasonStop,
+		},
+		{
+			name: "empty chunks",
+			stream: &fakeStream{
+				responses: []openai.ChatCompletionStreamResponse{{}, delta("Hi", ""), {}, delta("", "stop")},
+				err:       io.EOF,
+			},
+			content:      "Hi",
+			finishReason: openai.FinishReasonStop,
+		},
+		{
+			name: "interrupted",
+			stream: &fakeStream{
+				responses: []openai.ChatCompletionStreamResponse{delta("Partial", ""), toolCallDelta(0, "call_1", "get_time", "{")},
+				err:       errNetwork,
+			},
+			content: "Partial",
+			err:     errStreamInterrupted,
+		},
+		{
+			name: "unexpected end of stream",
+			stream: &fakeStream{
+				responses: []openai.ChatCompletionStreamResponse{delta("Partial", "")},
+				err:       io.ErrUnexpectedEOF,
+			},
+			content: "Partial",
+			err:     errStreamInterrupted,
+		},
+		{
+			name:         "length",
+			stream:       &fakeStream{responses: []openai.ChatCompletionStreamResponse{delta("Cut", openai.FinishReasonLength)}, err: io.EOF},
+			content:      "Cut",
+			finishReason: openai.FinishReasonLength,
+		},
+		{
+			name: "content filter",
+			stream: &fakeStream{
+				responses: []openai.ChatCompletionStreamResponse{delta("Filt", ""), delta("", openai.FinishReasonContentFilter)},
+				err:       io.EOF,
+			},
+			content:      "Filt",
+			finishReason: openai.FinishReasonContentFilter,
+		},
+		{
+			name: "tool calls",
+			stream: &fakeStream{
+				responses: []openai.ChatCompletionStreamResponse{
+					toolCallDelta(0, "call_1", "get_time", `{"time`),
+					toolCallDelta(1, "call_2", "calculate", `{}`),
+					toolCallDelta(0, "", "", `zone":"UTC"}`),
+					delta("", openai.FinishReasonToolCalls),
+				},
+				err: io.EOF,
+			},
+			finishReason: openai.FinishReasonToolCalls,
+			toolCalls:    `call_1 get_time {"timezone":"UTC"}|call_2 calculate {}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written strings.Builder
+
+			answer, err := receiveStream(context.Background(), tt.stream, func(content string) error {
+				written.WriteString(content)
+				return nil
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("receiveStream() error = %v, want %v", err, tt.err)
+			}
+
+			if answer.content != tt.content || answer.finishReason != tt.finishReason {
+				t.Errorf("receiveStream() = %q, %q, want %q, %q", answer.content, answer.finishReason, tt.content, tt.finishReason)
+			}
+
+			if written.String() != tt.content {
+				t.Errorf("written %q, want %q", written.String(), tt.content)
+			}
+
+			var toolCalls []string
+			for _, call := range answer.toolCalls {
+				toolCalls = append(toolCalls, call.ID+" "+call.Function.Name+" "+call.Function.Arguments)
+			}
+
+			if got := strings.Join(toolCalls, "|"); got != tt.toolCalls {
+				t.Errorf("tool calls = %q, want %q", got, tt.toolCalls)
+			}
+		})
+	}
+}
+
+func TestReceiveStreamStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeStream{responses: []openai.ChatCompletionStreamResponse{delta("Partial", "")}, err: context.Canceled}
+
+	answer, err := receiveStream(ctx, stream, func(string) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, errGenerationStopped) || answer.content != "Partial" {
+		t.Errorf("receiveStream() = %q, %v, want %q, %v", answer.content, err, "Partial", errGenerationStopped)
+	}
+}
+
+func TestReceiveStreamWriteError(t *testing.T) {
+	errWrite := errors.New("message deleted")
+	stream := &fakeStream{responses: []openai.ChatCompletionStreamResponse{delta("A", ""), delta("B", "")}, err: io.EOF}
+
+	answer, err := receiveStream(context.Background(), stream, func(string) error {
+		return errWrite
+	})
+	if !errors.Is(err, errWrite) || answer.content != "A" {
+		t.Errorf("receiveStream() = %q, %v, want %q, %v", answer.content, err, "A", errWrite)
+	}
+}
+
+// recordingWriter records the contents of the messages edited by a response.
+type recordingWriter struct {
+	edits []string
+}
+
+func (w *recordingWriter) Send(content string) (*discordgo.Message, error) {
+	return &discordgo.Message{ID: "next", Content: content}, nil
+}
+
+func (w *recordingWriter) Edit(messageID, content string) error {
+	w.edits = append(w.edits, content)
+	return nil
+}
+
+func (w *recordingWriter) EditComplex(messageID, content string, _ []discordgo.MessageComponent, _ []*discordgo.File) error {
+	w.edits = append(w.edits, content)
+	return nil
+}
+
+func (w *recordingWriter) Delete(string) error {
+	return nil
+}
+
+func TestDiscordResponseBuffered(t *testing.T) {
+	for _, buffered := range []bool{false, true} {
+		writer := &recordingWriter{}
+		response := newDiscordResponse(writer, &discordgo.Message{ID: "placeholder"}, 0)
+		response.buffered = buffered
+
+		for _, content := range []string{"Hello", ", world"} {
+			if err := response.write(content); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+		}
+
+		if err := response.flush(); err != nil {
+			t.Fatalf("flush() error = %v", err)
+		}
+
+		if buffered && (len(writer.edits) > 0 || response.sent) {
+			t.Errorf("buffered response edited the message: %q", writer.edits)
+		}
+
+		if !buffered && (len(writer.edits) != 3 || writer.edits[0] != "Hello") {
+			t.Errorf("streamed response edits = %q, want the content as it is written", writer.edits)
+		}
+
+		if err := response.retract("Blocked"); err != nil {
+			t.Fatalf("retract() error = %v", err)
+		}
+
+		if got := writer.edits[len(writer.edits)-1]; got != "Blocked" {
+			t.Errorf("last edit = %q, want %q", got, "Blocked")
+		}
+
+		// The buffered content is never shown when the reply is retracted.
+		if buffered && len(writer.edits) != 1 {
+			t.Errorf("buffered response edits = %q, want only the retraction", writer.edits)
+		}
+	}
+}
+
+func TestCreateChatStreamJSONMode(t *testing.T) {
+	var received openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = openai.ChatCompletionRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+
+	enabled, disabled := true, false
+	initModelRegistry([]config.Model{
+		{ID: "json-model", ContextWindow: 4096, MaxOutputTokens: 1024, SupportsStreaming: &enabled, SupportsJSONMode: &enabled},
+		{ID: "plain-model", ContextWindow: 4096, MaxOutputTokens: 1024, SupportsStreaming: &enabled, SupportsJSONMode: &disabled},
+	})
+	initProviders(config.Provider{
+		Name:    "test",
+		Type:    "openai-compatible",
+		BaseURL: server.URL + "/v1",
+		Retry:   config.Retry{MaxAttempts: 1},
+	}, nil)
+
+	for _, tt := range []struct {
+		modelID  string
+		jsonMode bool
+	}{
+		{modelID: "json-model", jsonMode: true},
+		{modelID: "plain-model", jsonMode: false},
+	} {
+		stream, _, err := createChatStreamWithFallback(context.Background(), []string{tt.modelID}, openai.ChatCompletionRequest{
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}},
+			ResponseFormat: &openai.ChatCompletionResponseFormat{
+				Type: openai.ChatCompletionResponseFormatTypeJSONObject,
+			},
+		})
+		if err != nil {
+			t.Fatalf("createChatStreamWithFallback() error = %v", err)
+		}
+
+		stream.Close()
+
+		if jsonMode := received.ResponseFormat != nil; jsonMode != tt.jsonMode {
+			t.Errorf("JSON mode requested from %s = %v, want %v", tt.modelID, jsonMode, tt.jsonMode)
+		}
+	}
+}
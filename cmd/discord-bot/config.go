@@ -3,6 +3,11 @@ package main
 import (
 	"context"
 	"flag"
+	"slices"
+	"time"
+
+	// Embed the time zone database for the time zones of servers on systems without it.
+	_ "time/tzdata"
 
 	"github.com/bwmarrin/discordgo"
 	tiktoken "github.com/pkoukk/tiktoken-go"
@@ -10,28 +15,63 @@ import (
 	"go.uber.org/zap"
 
 	"chatbot-gpt/internal/config"
-	"chatbot-gpt/internal/cost"
 	"chatbot-gpt/internal/database"
 	"chatbot-gpt/internal/locale"
+	"chatbot-gpt/internal/model"
+	"chatbot-gpt/internal/moderation"
+	"chatbot-gpt/internal/prompt"
+	"chatbot-gpt/internal/provider"
+	"chatbot-gpt/internal/scheduler"
+	"chatbot-gpt/internal/tool"
 )
 
 // ChannelConfig is the configuration for a channel.
 type ChannelConfig struct {
+	ModelID              string
+	AllowedModels        []string
+	FallbackModels       []string
 	MessageEditInterval  int
 	PromptTokenLimit     int
 	CompletionTokenLimit int
+	OverLimitStrategy    string
+	Persona              string
+	AllowedPersonas      []string
+	Sampling             config.Sampling
+	Tools                []string
+	MaxToolIterations    int
+	ToolTimeout          int
+	MaxContinuations     int
+	ImageDetail          string
+	ImageHistory         string
+	MaxAttachmentSize    int
+	Speech               bool
+	Voice                string
 }
 
 // ServerConfig is the configuration for a server.
 type ServerConfig struct {
 	Language     locale.Language
+	Location     *time.Location
 	ChatChannels map[string]ChannelConfig
-	Commands     struct {
-		ClearContext struct {
-			Enable  bool
-			Aliases []string
-		}
-	}
+	Commands     config.Commands
+	Moderation   ModerationConfig
+}
+
+// ModerationConfig is the configuration for moderating the messages of a server.
+type ModerationConfig struct {
+	Enable       bool
+	CheckOutput  bool
+	LogChannelID string
+	Policy       moderation.Policy
+}
+
+// Persona is a persona of the bot with its system prompt template.
+type Persona struct {
+	Name         string
+	DisplayName  string
+	AvatarURL    string
+	SystemPrompt *prompt.Template
+	Sampling     config.Sampling
 }
 
 const (
@@ -40,19 +80,20 @@ const (
 )
 
 var (
-	// Logger is the logger used by the bot.
-	Logger *zap.Logger
+	// Logger is the logger used by the bot, which discards the logs until the configuration is loaded.
+	Logger = zap.NewNop()
 
-	// OpenAIClient is the OpenAI client used by the bot.
-	OpenAIClient *openai.Client
+	// Providers is the map of chat providers by name.
+	// The empty name refers to the provider of the openai section.
+	Providers map[string]provider.Provider
 
 	// DiscordClient is the Discord client used by the bot.
 	DiscordClient *discordgo.Session
 
-	// Model is the OpenAI model used by the bot.
-	Model *openai.Model
+	// ModelRegistry is the registry of model capabilities.
+	ModelRegistry *model.Registry
 
-	// TokenPredictionModel is the model id that used by the bot for token prediction.
+	// TokenPredictionModel is the fallback tokenizer for models unknown to tiktoken.
 	TokenPredictionModel *tiktoken.Tiktoken
 
 	// ServerConfigMap is the map of server configurations.
@@ -64,8 +105,30 @@ var (
 	// MessageDatabase is the database used to store messages.
 	MessageDatabase database.ChatDatabase
 
-	// CostCalculator is the calculator used to calculate the cost of a message.
-	CostCalculator *cost.Calculator
+	// Personas is the map of personas by name.
+	// The empty name refers to the persona without a system prompt.
+	Personas map[string]Persona
+
+	// Currencies are the currencies in which costs are shown besides US dollars.
+	Currencies []config.Currency
+
+	// TranscriptionConfig is the configuration for transcribing voice messages and audio attachments.
+	TranscriptionConfig config.Transcription
+
+	// SpeechConfig is the configuration for reading the replies aloud.
+	SpeechConfig config.Speech
+
+	// ImageConfig is the configuration for generating images.
+	ImageConfig config.Image
+
+	// Moderator is the moderator of the messages of the servers which enable moderation.
+	Moderator *moderation.Moderator
+
+	// ToolRegistry is the registry of the tools available to chat channels.
+	ToolRegistry *tool.Registry
+
+	// RequestScheduler is the scheduler limiting the concurrent chat requests.
+	RequestScheduler *scheduler.Scheduler
 )
 
 // initLogger initializes the logger.
@@ -85,31 +148,287 @@ func initLogger(isProduction bool) {
 	}
 }
 
-// initOpenAIClient initializes the OpenAI client.
-func initOpenAIClient(cfg config.OpenAI) {
+// initModelRegistry initializes the model capability registry.
+func initModelRegistry(cfgs []config.Model) {
+	ModelRegistry = model.NewRegistry()
+
+	for _, cfg := range cfgs {
+		ModelRegistry.Update(cfg)
+	}
+}
+
+// initProviders initializes the chat providers.
+// The provider of the openai section is registered as the default provider.
+func initProviders(defaultCfg config.Provider, cfgs []config.Provider) {
+	Providers = make(map[string]provider.Provider)
+
+	for _, cfg := range append([]config.Provider{defaultCfg}, cfgs...) {
+		if cfg.Name == "" {
+			Logger.Panic("provider name is required", zap.String("type", cfg.Type))
+		}
+
+		if _, ok := Providers[cfg.Name]; ok {
+			Logger.Panic("duplicate provider name", zap.String("name", cfg.Name))
+		}
+
+		if p, err := provider.New(cfg); err != nil {
+			Logger.Panic(
+				"failed to create chat provider",
+				zap.String("name", cfg.Name),
+				zap.String("type", cfg.Type),
+				zap.Error(err),
+			)
+		} else {
+			Providers[cfg.Name] = p
+		}
+	}
+
+	Providers[""] = Providers[defaultCfg.Name]
+}
+
+// initTokenPredictionModel initializes the fallback token prediction model.
+func initTokenPredictionModel(cfg config.OpenAI) {
 	if tkm, err := tiktoken.EncodingForModel(cfg.TokenPredictionModelID); err != nil {
 		Logger.Panic("failed to initialize token prediction model", zap.Error(err))
 	} else {
 		TokenPredictionModel = tkm
 	}
+}
 
-	OpenAIClient = openai.NewClient(cfg.Token)
+// defaultCurrencies are the currencies used when none is configured.
+var defaultCurrencies = []config.Currency{
+	{Code: "JPY", Rate: 138.31, Symbol: "🇯🇵 ￥"},
+	{Code: "CNY", Rate: 7.05, Symbol: "🇨🇳 ￥"},
+}
 
-	if result, err := OpenAIClient.ListModels(context.Background()); err != nil {
-		Logger.Panic("failed to initialize OpenAI client", zap.Error(err))
-	} else {
-		for _, model := range result.Models {
-			thisModel := model
-			if model.ID == cfg.ModelID {
-				Model = &thisModel
-				break
+// initCurrencies initializes the currencies.
+func initCurrencies(cfgs []config.Currency) {
+	if len(cfgs) == 0 {
+		cfgs = defaultCurrencies
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.Code == "" || cfg.Rate <= 0 {
+			Logger.Panic("invalid currency", zap.String("code", cfg.Code), zap.Float64("rate", cfg.Rate))
+		}
+	}
+
+	Currencies = cfgs
+}
+
+// initTranscription initializes the transcription of audio attachments.
+// The transcription model must be served by a provider with a speech-to-text endpoint.
+func initTranscription(cfg config.Transcription) {
+	if cfg.ModelID != "" {
+		if _, err := providerForModel(cfg.ModelID); err != nil {
+			Logger.Panic("invalid transcription model", zap.String("model", cfg.ModelID), zap.Error(err))
+		}
+	}
+
+	TranscriptionConfig = cfg
+}
+
+// speechFormats are the audio formats of the synthesized speech.
+var speechFormats = []openai.SpeechResponseFormat{
+	openai.SpeechResponseFormatMp3,
+	openai.SpeechResponseFormatOpus,
+	openai.SpeechResponseFormatAac,
+	openai.SpeechResponseFormatFlac,
+}
+
+// initSpeech initializes the speech of the replies.
+// The speech model must be served by a provider with a text-to-speech endpoint.
+func initSpeech(cfg config.Speech) {
+	if cfg.ModelID != "" {
+		if _, err := providerForModel(cfg.ModelID); err != nil {
+			Logger.Panic("invalid speech model", zap.String("model", cfg.ModelID), zap.Error(err))
+		}
+	}
+
+	if cfg.Format == "" {
+		cfg.Format = string(openai.SpeechResponseFormatMp3)
+	}
+
+	if !slices.Contains(speechFormats, openai.SpeechResponseFormat(cfg.Format)) {
+		Logger.Panic("invalid speech format", zap.String("format", cfg.Format))
+	}
+
+	if cfg.Speed == 0 {
+		cfg.Speed = 1
+	}
+
+	if cfg.Speed < 0.25 || cfg.Speed > 4 {
+		Logger.Panic("invalid speech speed", zap.Float64("speed", cfg.Speed))
+	}
+
+	SpeechConfig = cfg
+}
+
+// initImages initializes the generation of images.
+func initImages(cfg config.Image) {
+	if cfg.ModelID != "" {
+		if _, err := providerForModel(cfg.ModelID); err != nil {
+			Logger.Panic("invalid image model", zap.String("model", cfg.ModelID), zap.Error(err))
+		}
+	}
+
+	if cfg.MaxCount <= 0 {
+		cfg.MaxCount = 1
+	}
+
+	if cfg.DailyUserQuota < 0 || cfg.DailyServerQuota < 0 {
+		Logger.Panic(
+			"invalid image quota",
+			zap.Float64("user", cfg.DailyUserQuota),
+			zap.Float64("server", cfg.DailyServerQuota),
+		)
+	}
+
+	ImageConfig = cfg
+}
+
+// initModerator initializes the moderator.
+func initModerator(cfg config.Moderation) {
+	var moderationProvider provider.Provider
+
+	if cfg.ModelID != "" {
+		p, err := providerForModel(cfg.ModelID)
+		if err != nil {
+			Logger.Panic("invalid moderation model", zap.String("model", cfg.ModelID), zap.Error(err))
+		}
+
+		moderationProvider = p
+	}
+
+	m, err := moderation.New(cfg, moderationProvider)
+	if err != nil {
+		Logger.Panic("failed to create moderator", zap.Error(err))
+	}
+
+	Moderator = m
+}
+
+// defaultModerationThresholds are the thresholds used when a server sets none.
+var defaultModerationThresholds = []config.ModerationThreshold{
+	{Category: moderation.AnyCategory, Warn: 0.5, Block: 0.8},
+}
+
+// initToolRegistry initializes the tool registry with the built-in tools.
+// The search tool is registered only when its endpoint is configured.
+func initToolRegistry(searchCfg config.Search) {
+	ToolRegistry = tool.NewRegistry()
+
+	rates := make(map[string]float64)
+	for _, currency := range Currencies {
+		rates[currency.Code] = currency.Rate
+	}
+
+	serverLocation := func(guildID string) *time.Location {
+		return ServerConfigMap[guildID].Location
+	}
+
+	builtins := append([]tool.Tool{
+		tool.NewClock(serverLocation),
+		tool.NewCalculator(),
+		tool.NewConverter(rates),
+	}, tool.NewDiscordTools(DiscordClient)...)
+
+	if searchCfg.Endpoint != "" {
+		builtins = append(builtins, tool.NewSearch(searchCfg))
+	}
+
+	for _, t := range builtins {
+		if err := ToolRegistry.Register(t); err != nil {
+			Logger.Panic("failed to register tool", zap.String("name", t.Name), zap.Error(err))
+		}
+	}
+}
+
+// initPersonas initializes the persona library.
+func initPersonas(cfgs []config.Persona) {
+	Personas = map[string]Persona{"": {}}
+
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			Logger.Panic("persona name is required")
+		}
+
+		if _, ok := Personas[cfg.Name]; ok {
+			Logger.Panic("duplicate persona name", zap.String("name", cfg.Name))
+		}
+
+		persona := Persona{
+			Name:        cfg.Name,
+			DisplayName: cfg.DisplayName,
+			AvatarURL:   cfg.AvatarURL,
+			Sampling:    cfg.Sampling,
+		}
+
+		if cfg.SystemPrompt != "" {
+			if tmpl, err := prompt.Parse(cfg.Name, cfg.SystemPrompt); err != nil {
+				Logger.Panic("invalid system prompt template", zap.String("persona", cfg.Name), zap.Error(err))
+			} else {
+				persona.SystemPrompt = tmpl
 			}
 		}
 
-		if Model == nil {
-			Logger.Panic("invalid model ID or you have not access to it.", zap.String("modelID", cfg.ModelID))
+		Personas[cfg.Name] = persona
+	}
+}
+
+// checkPersona checks whether the persona is defined in the persona library.
+func checkPersona(name string) {
+	if _, ok := Personas[name]; !ok {
+		Logger.Panic("unknown persona", zap.String("persona", name))
+	}
+}
+
+// checkSampling checks whether the sampling parameters are accepted by the provider of the model.
+func checkSampling(modelID string, sampling config.Sampling) {
+	chatProvider, providerErr := providerForModel(modelID)
+	if providerErr != nil {
+		Logger.Panic("failed to find the provider of the model", zap.String("modelID", modelID), zap.Error(providerErr))
+	}
+
+	if err := chatProvider.CheckSampling(sampling); err != nil {
+		Logger.Panic("invalid sampling parameters for the model", zap.String("modelID", modelID), zap.Error(err))
+	}
+}
+
+// checkModel checks whether the model is available and fits the token limits.
+// availableModels caches the models listed by each provider.
+func checkModel(modelID string, promptTokenLimit, completionTokenLimit int, availableModels map[string][]openai.Model) {
+	capability, ok := ModelRegistry.Lookup(modelID)
+	if !ok {
+		Logger.Panic("unknown model capabilities, please add it to the models config", zap.String("modelID", modelID))
+	}
+
+	if err := capability.CheckLimits(promptTokenLimit, completionTokenLimit); err != nil {
+		Logger.Panic("invalid token limits for the model", zap.String("modelID", modelID), zap.Error(err))
+	}
+
+	chatProvider, providerErr := providerForModel(modelID)
+	if providerErr != nil {
+		Logger.Panic("failed to find the provider of the model", zap.String("modelID", modelID), zap.Error(providerErr))
+	}
+
+	models, listed := availableModels[capability.Provider]
+	if !listed {
+		if result, err := chatProvider.ListModels(context.Background()); err != nil {
+			Logger.Panic("failed to initialize chat provider", zap.Error(err))
+		} else {
+			models = result
+			availableModels[capability.Provider] = models
+		}
+	}
+
+	for _, model := range models {
+		if model.ID == modelID {
+			return
 		}
 	}
+
+	Logger.Panic("invalid model ID or you have not access to it.", zap.String("modelID", modelID))
 }
 
 // initDiscordClient initializes the Discord client.
@@ -136,17 +455,146 @@ func initLocalizer(cfg config.Discord) {
 }
 
 // initServerConfigMap initializes the server configuration map.
-func initServerConfigMap(cfg config.Discord) {
+func initServerConfigMap(cfg config.Discord, defaultModelID string) {
 	ServerConfigMap = make(map[string]ServerConfig)
+	availableModels := make(map[string][]openai.Model)
 
 	for _, serverConfig := range cfg.Servers {
 		chatChannels := make(map[string]ChannelConfig)
 
 		for _, channelConfig := range serverConfig.ChatChannels {
+			modelID := channelConfig.ModelID
+			if modelID == "" {
+				modelID = defaultModelID
+			}
+
+			allowedModels := []string{modelID}
+			for _, allowedModel := range channelConfig.AllowedModels {
+				if !slices.Contains(allowedModels, allowedModel) {
+					allowedModels = append(allowedModels, allowedModel)
+				}
+			}
+
+			for _, checkedModel := range append(slices.Clone(allowedModels), channelConfig.FallbackModels...) {
+				checkModel(
+					checkedModel, channelConfig.PromptTokenLimit, channelConfig.CompletionTokenLimit, availableModels,
+				)
+			}
+
+			overLimitStrategy := channelConfig.OverLimitStrategy
+			switch overLimitStrategy {
+			case "":
+				overLimitStrategy = overLimitReject
+			case overLimitReject, overLimitTruncate, overLimitSummarize:
+			default:
+				Logger.Panic(
+					"invalid over limit strategy",
+					zap.String("channelID", channelConfig.ID),
+					zap.String("strategy", overLimitStrategy),
+				)
+			}
+
+			persona := channelConfig.Persona
+			if persona == "" {
+				persona = serverConfig.Persona
+			}
+
+			allowedPersonas := []string{persona}
+			for _, allowedPersona := range channelConfig.AllowedPersonas {
+				if !slices.Contains(allowedPersonas, allowedPersona) {
+					allowedPersonas = append(allowedPersonas, allowedPersona)
+				}
+			}
+
+			for _, checkedPersona := range allowedPersonas {
+				checkPersona(checkedPersona)
+
+				sampling := channelConfig.Sampling.Merge(Personas[checkedPersona].Sampling)
+				for _, checkedModel := range append(slices.Clone(allowedModels), channelConfig.FallbackModels...) {
+					checkSampling(checkedModel, sampling)
+				}
+			}
+
+			for _, toolName := range channelConfig.Tools {
+				if _, ok := ToolRegistry.Lookup(toolName); !ok {
+					Logger.Panic("unknown tool", zap.String("channelID", channelConfig.ID), zap.String("tool", toolName))
+				}
+			}
+
+			maxToolIterations := channelConfig.MaxToolIterations
+			if maxToolIterations <= 0 {
+				maxToolIterations = defaultMaxToolIterations
+			}
+
+			toolTimeout := channelConfig.ToolTimeout
+			if toolTimeout <= 0 {
+				toolTimeout = defaultToolTimeout
+			}
+
+			if channelConfig.MaxContinuations < 0 {
+				Logger.Panic(
+					"invalid max continuations",
+					zap.String("channelID", channelConfig.ID),
+					zap.Int("maxContinuations", channelConfig.MaxContinuations),
+				)
+			}
+
+			imageDetail := openai.ImageURLDetail(channelConfig.ImageDetail)
+			switch imageDetail {
+			case "":
+				imageDetail = openai.ImageURLDetailAuto
+			case openai.ImageURLDetailAuto, openai.ImageURLDetailLow, openai.ImageURLDetailHigh:
+			default:
+				Logger.Panic(
+					"invalid image detail",
+					zap.String("channelID", channelConfig.ID),
+					zap.String("detail", channelConfig.ImageDetail),
+				)
+			}
+
+			imageHistory := channelConfig.ImageHistory
+			switch imageHistory {
+			case "":
+				imageHistory = imageHistoryDrop
+			case imageHistoryKeep, imageHistoryDrop:
+			default:
+				Logger.Panic(
+					"invalid image history policy",
+					zap.String("channelID", channelConfig.ID),
+					zap.String("policy", imageHistory),
+				)
+			}
+
+			maxAttachmentSize := channelConfig.MaxAttachmentSize
+			if maxAttachmentSize <= 0 {
+				maxAttachmentSize = defaultMaxAttachmentSize
+			}
+
+			voice := channelConfig.Voice
+			if voice == "" {
+				voice = SpeechConfig.Voice
+			}
+
 			chatChannels[channelConfig.ID] = ChannelConfig{
+				ModelID:              modelID,
+				AllowedModels:        allowedModels,
+				FallbackModels:       channelConfig.FallbackModels,
 				MessageEditInterval:  channelConfig.MessageEditInterval,
 				PromptTokenLimit:     channelConfig.PromptTokenLimit,
 				CompletionTokenLimit: channelConfig.CompletionTokenLimit,
+				OverLimitStrategy:    overLimitStrategy,
+				Persona:              persona,
+				AllowedPersonas:      allowedPersonas,
+				Sampling:             channelConfig.Sampling,
+				Tools:                channelConfig.Tools,
+				MaxToolIterations:    maxToolIterations,
+				ToolTimeout:          toolTimeout,
+				MaxContinuations:     channelConfig.MaxContinuations,
+				ImageDetail:          string(imageDetail),
+				ImageHistory:         imageHistory,
+				MaxAttachmentSize:    maxAttachmentSize,
+				Speech:               channelConfig.Speech,
+				Voice:                voice,
 			}
 		}
 
@@ -156,15 +604,37 @@ func initServerConfigMap(cfg config.Discord) {
 			Logger.Panic("invalid language code", zap.String("code", serverConfig.Language))
 		}
 
+		timezone := serverConfig.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+
+		location, locationErr := time.LoadLocation(timezone)
+		if locationErr != nil {
+			Logger.Panic("invalid timezone", zap.String("timezone", timezone), zap.Error(locationErr))
+		}
+
+		thresholds := serverConfig.Moderation.Thresholds
+		if len(thresholds) == 0 {
+			thresholds = defaultModerationThresholds
+		}
+
+		moderationPolicy, policyErr := moderation.NewPolicy(thresholds)
+		if policyErr != nil {
+			Logger.Panic("invalid moderation thresholds", zap.String("serverID", serverConfig.ID), zap.Error(policyErr))
+		}
+
 		ServerConfigMap[serverConfig.ID] = ServerConfig{
 			Language:     language,
+			Location:     location,
 			ChatChannels: chatChannels,
-			Commands: struct {
-				ClearContext struct {
-					Enable  bool
-					Aliases []string
-				}
-			}(serverConfig.Commands),
+			Commands:     serverConfig.Commands,
+			Moderation: ModerationConfig{
+				Enable:       serverConfig.Moderation.Enable,
+				CheckOutput:  serverConfig.Moderation.CheckOutput,
+				LogChannelID: serverConfig.Moderation.LogChannel,
+				Policy:       moderationPolicy,
+			},
 		}
 	}
 }
@@ -174,18 +644,21 @@ func initMessageDatabase() {
 	MessageDatabase = database.NewMemoryChatDatabase()
 }
 
-// initCostCalculator initializes the cost calculator.
-func initCostCalculator() {
-	CostCalculator = cost.NewCalculator(Model)
+// initRequestScheduler initializes the request scheduler.
+func initRequestScheduler(cfg config.Scheduler) {
+	RequestScheduler = scheduler.New(cfg)
 }
 
-func init() {
+// initConfig loads the configuration and initializes the bot with it.
+func initConfig() {
 	path := flag.String("config", "config.json", "Path to the cfg file")
 	flag.Parse()
 
 	userConfig, err := config.Init(&struct {
-		Discord config.Discord
-		OpenAI  config.OpenAI
+		Discord   config.Discord
+		OpenAI    config.OpenAI
+		Models    []config.Model
+		Providers []config.Provider
 	}{}, configPrefix, *path)
 	if err != nil {
 		panic(err)
@@ -193,9 +666,18 @@ func init() {
 
 	initLogger(userConfig.Discord.Production)
 	initMessageDatabase()
-	initOpenAIClient(userConfig.OpenAI)
-	initCostCalculator()
+	initRequestScheduler(userConfig.Discord.Scheduler)
+	initModelRegistry(userConfig.Models)
+	initProviders(userConfig.OpenAI.Provider, userConfig.Providers)
+	initTokenPredictionModel(userConfig.OpenAI)
 	initDiscordClient(userConfig.Discord)
 	initLocalizer(userConfig.Discord)
-	initServerConfigMap(userConfig.Discord)
+	initPersonas(userConfig.Discord.Personas)
+	initCurrencies(userConfig.Discord.Currencies)
+	initToolRegistry(userConfig.Discord.Search)
+	initTranscription(userConfig.Discord.Transcription)
+	initSpeech(userConfig.Discord.Speech)
+	initImages(userConfig.Discord.Images)
+	initModerator(userConfig.Discord.Moderation)
+	initServerConfigMap(userConfig.Discord, userConfig.OpenAI.ModelID)
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/document"
+)
+
+const (
+	// maxDocumentSize is the maximum size in bytes of a document attachment.
+	maxDocumentSize = 20 << 20
+
+	// documentCacheSize is the number of documents whose extracted text is cached.
+	documentCacheSize = 64
+)
+
+// documentCache caches the extracted pages of the document attachments by attachment ID.
+var documentCache = document.NewCache(documentCacheSize)
+
+// pageSelections is the map of the page ranges of documents selected by users, keyed by channel and user.
+var pageSelections = struct {
+	sync.RWMutex
+	m map[string]document.PageRange
+}{m: make(map[string]document.PageRange)}
+
+// selectedPageRange returns the page range selected by the user in the channel, all pages by default.
+func selectedPageRange(channelID, userID string) document.PageRange {
+	pageSelections.RLock()
+	defer pageSelections.RUnlock()
+
+	return pageSelections.m[modelSelectionKey(channelID, userID)]
+}
+
+// selectPageRange selects the page range for the user in the channel.
+func selectPageRange(channelID, userID string, pageRange document.PageRange) {
+	pageSelections.Lock()
+	defer pageSelections.Unlock()
+
+	pageSelections.m[modelSelectionKey(channelID, userID)] = pageRange
+}
+
+// documentPages returns the extracted pages of the document attachment, from the cache if possible.
+func documentPages(ctx context.Context, attachment *discordgo.MessageAttachment, kind document.Kind) ([]string, error) {
+	if pages, ok := documentCache.Get(attachment.ID); ok {
+		return pages, nil
+	}
+
+	data, truncated, err := downloadAttachment(ctx, attachment, maxDocumentSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if truncated {
+		return nil, fmt.Errorf("%s is larger than %d bytes", attachment.Filename, maxDocumentSize)
+	}
+
+	pages, err := document.Extract(kind, data)
+	if err != nil {
+		return nil, err
+	}
+
+	documentCache.Put(attachment.ID, pages)
+
+	return pages, nil
+}
+
+// documentBlocks extracts the selected pages of the document attachments as fenced blocks,
+// and reports whether any document has no readable text.
+func documentBlocks(
+	ctx context.Context, attachments []*discordgo.MessageAttachment, pageRange document.PageRange,
+) ([]string, bool) {
+	var blocks []string
+	var unreadable bool
+
+	for _, attachment := range attachments {
+		kind, ok := document.KindOf(attachment.Filename, attachment.ContentType)
+		if !ok {
+			continue
+		}
+
+		pages, err := documentPages(ctx, attachment, kind)
+		if err != nil {
+			Logger.Debug("failed to extract document", zap.String("filename", attachment.Filename), zap.Error(err))
+			unreadable = true
+
+			continue
+		}
+
+		var texts []string
+		for i, text := range pages {
+			if text == "" || (kind == document.KindPDF && !pageRange.Contains(i+1)) {
+				continue
+			}
+
+			if kind == document.KindPDF {
+				text = fmt.Sprintf("[page %d]\n%s", i+1, text)
+			}
+
+			texts = append(texts, text)
+		}
+
+		if len(texts) == 0 {
+			unreadable = true
+			continue
+		}
+
+		title := attachment.Filename
+		if kind == document.KindPDF && len(pageRange) > 0 {
+			title += " (pages " + pageRange.String() + ")"
+		}
+
+		blocks = append(blocks, fencedBlock(title, "", strings.Join(texts, "\n\n")))
+	}
+
+	return blocks, unreadable
+}
+
+// handlePagesCommand handles the pages command, which selects the pages of the PDFs read for the user.
+func handlePagesCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	_, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	options := i.ApplicationCommandData().Options
+	input := ""
+	if len(options) > 0 {
+		input = options[0].StringValue()
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+		zap.String("pages", input),
+	)
+
+	embed := &discordgo.MessageEmbed{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Color:     0x379C6F,
+	}
+
+	pageRange, parseErr := document.ParsePageRange(input)
+
+	switch {
+	case !isChatChannel:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("not_chat_channel", serverConfig.Language)
+		embed.Color = 0xCC0000
+	case parseErr != nil:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("invalid_page_range", serverConfig.Language)
+		embed.Color = 0xCC0000
+	default:
+		selectPageRange(i.ChannelID, i.Member.User.ID, pageRange)
+
+		embed.Title = "✅ " + Localizer.Fetch("pages_selected", serverConfig.Language)
+		embed.Description = pageRange.String()
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
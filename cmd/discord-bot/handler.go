@@ -1,12 +1,19 @@
 package main
 
 import (
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/config"
 )
 
+// componentPrefix prefixes the custom IDs of the message components in the interaction handlers,
+// which cannot be confused with the commands since their names have no colon.
+const componentPrefix = "component:"
+
 var (
 	// messageHandlers is a list of message handlers.
 	messageHandlers []func(*discordgo.Session, *discordgo.MessageCreate) bool
@@ -19,6 +26,12 @@ var (
 	// slashCommands is a list of slash commands.
 	slashCommands = struct {
 		ClearContext func(alias string) *discordgo.ApplicationCommand
+		Model        func(alias string) *discordgo.ApplicationCommand
+		Persona      func(alias string) *discordgo.ApplicationCommand
+		Params       func(alias string) *discordgo.ApplicationCommand
+		Pages        func(alias string) *discordgo.ApplicationCommand
+		Speech       func(alias string) *discordgo.ApplicationCommand
+		Imagine      func(alias string) *discordgo.ApplicationCommand
 	}{
 		ClearContext: func(alias string) *discordgo.ApplicationCommand {
 			return &discordgo.ApplicationCommand{
@@ -27,6 +40,113 @@ var (
 				Type:        discordgo.ChatApplicationCommand,
 			}
 		},
+		Model: func(alias string) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name:        alias,
+				Description: "Select the model to chat with in this channel",
+				Type:        discordgo.ChatApplicationCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionString,
+						Name:         "model",
+						Description:  "Model ID",
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			}
+		},
+		Persona: func(alias string) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name:                     alias,
+				Description:              "Switch the persona of the bot in this channel",
+				Type:                     discordgo.ChatApplicationCommand,
+				DefaultMemberPermissions: &personaPermissions,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:         discordgo.ApplicationCommandOptionString,
+						Name:         "persona",
+						Description:  "Persona name",
+						Required:     true,
+						Autocomplete: true,
+					},
+				},
+			}
+		},
+		Params: func(alias string) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name:                     alias,
+				Description:              "Show the sampling parameters of this channel",
+				Type:                     discordgo.ChatApplicationCommand,
+				DefaultMemberPermissions: &adminPermissions,
+			}
+		},
+		Pages: func(alias string) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name:        alias,
+				Description: "Select the pages of the PDFs you attach in this channel",
+				Type:        discordgo.ChatApplicationCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "pages",
+						Description: "Page range, e.g. 1-3,5 or all",
+						Required:    true,
+					},
+				},
+			}
+		},
+		Speech: func(alias string) *discordgo.ApplicationCommand {
+			return &discordgo.ApplicationCommand{
+				Name:        alias,
+				Description: "Choose whether the replies to you in this channel are read aloud",
+				Type:        discordgo.ChatApplicationCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionBoolean,
+						Name:        "enable",
+						Description: "Attach the audio of the replies",
+						Required:    true,
+					},
+				},
+			}
+		},
+		Imagine: func(alias string) *discordgo.ApplicationCommand {
+			minCount := 1.0
+
+			return &discordgo.ApplicationCommand{
+				Name:        alias,
+				Description: "Generate images of a prompt",
+				Type:        discordgo.ChatApplicationCommand,
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "prompt",
+						Description: "Description of the images",
+						Required:    true,
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "size",
+						Description: "Size of the images, 1024x1024 by default",
+						Choices:     commandChoices(imageSizes),
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionString,
+						Name:        "quality",
+						Description: "Quality of the images",
+						Choices:     commandChoices(imageQualities),
+					},
+					{
+						Type:        discordgo.ApplicationCommandOptionInteger,
+						Name:        "count",
+						Description: "Number of images",
+						MinValue:    &minCount,
+						MaxValue:    float64(ImageConfig.MaxCount),
+					},
+				},
+			}
+		},
 	}
 )
 
@@ -41,13 +161,22 @@ func addHandlers() {
 		}
 	})
 
-	// Slash commands
+	// Slash commands and message components
 	DiscordClient.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		if _, ok := interactionHandlers[i.GuildID]; !ok {
 			return
 		}
 
-		if handler, ok := interactionHandlers[i.GuildID][i.ApplicationCommandData().Name]; ok {
+		// The components are handled by the prefix of their custom ID before ":".
+		var name string
+		if i.Type == discordgo.InteractionMessageComponent {
+			prefix, _, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+			name = componentPrefix + prefix
+		} else {
+			name = i.ApplicationCommandData().Name
+		}
+
+		if handler, ok := interactionHandlers[i.GuildID][name]; ok {
 			handler(s, i)
 		}
 	})
@@ -72,44 +201,125 @@ func initSlashCommands() {
 			}
 		}
 
-		if serverConfig.Commands.ClearContext.Enable {
-			interactionHandlers[serverID] = make(
-				map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate),
-			)
-
-			for _, alias := range serverConfig.Commands.ClearContext.Aliases {
-				interactionHandlers[serverID][alias] = func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-					Logger.Debug(
-						"received interaction",
-						zap.String("command", alias),
-						zap.String("user", i.Member.User.Username),
-					)
-
-					MessageDatabase.Clear(i.Member.User.ID)
-
-					if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-						Type: discordgo.InteractionResponseChannelMessageWithSource,
-						Data: &discordgo.InteractionResponseData{
-							Embeds: []*discordgo.MessageEmbed{
-								{
-									Title:       "✅ " + Localizer.Fetch("cleared", serverConfig.Language),
-									Description: Localizer.Fetch("clear_context", serverConfig.Language),
-									Timestamp:   time.Now().Format(time.RFC3339),
-									Color:       0x379C6F,
-								},
+		interactionHandlers[serverID] = make(
+			map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate),
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.ClearContext, slashCommands.ClearContext,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				Logger.Debug(
+					"received interaction",
+					zap.String("command", i.ApplicationCommandData().Name),
+					zap.String("user", i.Member.User.Username),
+				)
+
+				MessageDatabase.Clear(historyKey(i.ChannelID, i.Member.User.ID))
+
+				if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseChannelMessageWithSource,
+					Data: &discordgo.InteractionResponseData{
+						Embeds: []*discordgo.MessageEmbed{
+							{
+								Title:       "✅ " + Localizer.Fetch("cleared", serverConfig.Language),
+								Description: Localizer.Fetch("clear_context", serverConfig.Language),
+								Timestamp:   time.Now().Format(time.RFC3339),
+								Color:       0x379C6F,
 							},
 						},
-					}); err != nil {
-						Logger.Error("failed to respond to interaction", zap.Error(err))
-						return
-					}
+					},
+				}); err != nil {
+					Logger.Error("failed to respond to interaction", zap.Error(err))
+					return
 				}
+			},
+		)
 
-				if _, err := DiscordClient.ApplicationCommandCreate(DiscordClient.State.User.ID, serverID, slashCommands.ClearContext(alias)); err != nil {
-					Logger.Error("failed to create slash command", zap.Error(err))
-					continue
-				}
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Model, slashCommands.Model,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handleModelCommand(s, i, serverConfig)
+			},
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Persona, slashCommands.Persona,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handlePersonaCommand(s, i, serverConfig)
+			},
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Params, slashCommands.Params,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handleParamsCommand(s, i, serverConfig)
+			},
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Pages, slashCommands.Pages,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handlePagesCommand(s, i, serverConfig)
+			},
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Speech, slashCommands.Speech,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handleSpeechCommand(s, i, serverConfig)
+			},
+		)
+
+		registerSlashCommand(
+			serverID, serverConfig.Commands.Imagine, slashCommands.Imagine,
+			func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handleImagineCommand(s, i, serverConfig)
+			},
+		)
+
+		components := map[string]func(*discordgo.Session, *discordgo.InteractionCreate, ServerConfig){
+			stopButtonID:       handleStopButton,
+			speechButtonID:     handleSpeechButton,
+			regenerateButtonID: handleRegenerateButton,
+			continueButtonID:   handleContinueButton,
+			branchButtonID:     handleBranchButton,
+		}
+
+		for customID, handler := range components {
+			interactionHandlers[serverID][componentPrefix+customID] = func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+				handler(s, i, serverConfig)
 			}
 		}
 	}
 }
+
+// registerSlashCommand registers the handler and the slash commands of every alias of a command.
+func registerSlashCommand(
+	serverID string,
+	command config.Command,
+	newCommand func(alias string) *discordgo.ApplicationCommand,
+	handler func(s *discordgo.Session, i *discordgo.InteractionCreate),
+) {
+	if !command.Enable {
+		return
+	}
+
+	for _, alias := range command.Aliases {
+		interactionHandlers[serverID][alias] = handler
+
+		if _, err := DiscordClient.ApplicationCommandCreate(DiscordClient.State.User.ID, serverID, newCommand(alias)); err != nil {
+			Logger.Error("failed to create slash command", zap.Error(err))
+			continue
+		}
+	}
+}
+
+// commandChoices returns the choices of a command option with the values as names.
+func commandChoices(values []string) []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(values))
+	for _, value := range values {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: value, Value: value})
+	}
+
+	return choices
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/cost"
+	"chatbot-gpt/internal/locale"
+)
+
+const (
+	// defaultImageSize is the size of the generated images when none is chosen.
+	defaultImageSize = openai.CreateImageSize1024x1024
+
+	// maxShownImagePrompt is the maximum number of characters of the prompt shown with the images.
+	maxShownImagePrompt = 1500
+
+	// interactionLifetime is how long the response of an interaction can be edited,
+	// after which the images could not be sent anymore.
+	interactionLifetime = 15 * time.Minute
+)
+
+// errNoImage is returned when the image API answers without an image.
+var errNoImage = errors.New("no image generated")
+
+// imageSizes are the sizes of the images offered by the imagine command.
+var imageSizes = []string{
+	openai.CreateImageSize1024x1024,
+	openai.CreateImageSize1792x1024,
+	openai.CreateImageSize1024x1792,
+	openai.CreateImageSize512x512,
+	openai.CreateImageSize256x256,
+}
+
+// imageQualities are the qualities of the images offered by the imagine command.
+var imageQualities = []string{
+	openai.CreateImageQualityStandard,
+	openai.CreateImageQualityHD,
+}
+
+// imageOptions are the sizes and the qualities of the images supported by an image model.
+type imageOptions struct {
+	sizes     []string
+	qualities []string
+}
+
+// modelImageOptions are the options supported by the known image models.
+// The qualities include the empty one, which is the default of the model.
+var modelImageOptions = map[string]imageOptions{
+	"dall-e-2": {
+		sizes: []string{
+			openai.CreateImageSize256x256,
+			openai.CreateImageSize512x512,
+			openai.CreateImageSize1024x1024,
+		},
+		qualities: []string{""},
+	},
+	"dall-e-3": {
+		sizes: []string{
+			openai.CreateImageSize1024x1024,
+			openai.CreateImageSize1792x1024,
+			openai.CreateImageSize1024x1792,
+		},
+		qualities: []string{"", openai.CreateImageQualityStandard, openai.CreateImageQualityHD},
+	},
+}
+
+// supportsImageOptions reports whether the image model supports the size and the quality.
+// The options of unknown models are left for their provider to check.
+func supportsImageOptions(modelID, size, quality string) bool {
+	options, ok := modelImageOptions[modelID]
+	if !ok {
+		return true
+	}
+
+	return slices.Contains(options.sizes, size) && slices.Contains(options.qualities, quality)
+}
+
+// imageSpending is the cost of the images generated today, by server and by user in a server.
+var imageSpending = struct {
+	sync.Mutex
+	m map[string]dailySpending
+}{m: make(map[string]dailySpending)}
+
+// dailySpending is the cost spent on a day.
+type dailySpending struct {
+	day   string
+	spent float64
+}
+
+// reserveImageCost reserves the cost of the images for the user in the server,
+// and reports whether it is within the daily quotas.
+func reserveImageCost(guildID, userID, day string, numDollars float64) bool {
+	imageSpending.Lock()
+	defer imageSpending.Unlock()
+
+	quotas := map[string]float64{
+		guildID:                            ImageConfig.DailyServerQuota,
+		modelSelectionKey(guildID, userID): ImageConfig.DailyUserQuota,
+	}
+
+	for key, quota := range quotas {
+		spending := imageSpending.m[key]
+		if spending.day != day {
+			spending = dailySpending{day: day}
+		}
+
+		if quota > 0 && spending.spent+numDollars > quota {
+			return false
+		}
+	}
+
+	for key := range quotas {
+		spending := imageSpending.m[key]
+		if spending.day != day {
+			spending = dailySpending{day: day}
+		}
+
+		spending.spent += numDollars
+		imageSpending.m[key] = spending
+	}
+
+	return true
+}
+
+// refundImageCost gives back the cost reserved for images which were not generated.
+func refundImageCost(guildID, userID, day string, numDollars float64) {
+	imageSpending.Lock()
+	defer imageSpending.Unlock()
+
+	for _, key := range []string{guildID, modelSelectionKey(guildID, userID)} {
+		if spending := imageSpending.m[key]; spending.day == day {
+			spending.spent = max(spending.spent-numDollars, 0)
+			imageSpending.m[key] = spending
+		}
+	}
+}
+
+// generateImages generates the images one request at a time in parallel, since some models generate one image per request.
+// It returns the images which were generated and the first error.
+func generateImages(ctx context.Context, request openai.ImageRequest, count int) ([]*discordgo.File, error) {
+	imageProvider, err := providerForModel(request.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([][]byte, count)
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+	for n := 0; n < count; n++ {
+		wg.Add(1)
+
+		go func(n int) {
+			defer wg.Done()
+
+			resp, err := imageProvider.CreateImage(ctx, request)
+			if err != nil {
+				errs[n] = err
+				return
+			}
+
+			if len(resp.Data) == 0 {
+				errs[n] = errNoImage
+				return
+			}
+
+			images[n], errs[n] = base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+		}(n)
+	}
+
+	wg.Wait()
+
+	var files []*discordgo.File
+	var firstErr error
+
+	for n, image := range images {
+		if errs[n] != nil {
+			if firstErr == nil {
+				firstErr = errs[n]
+			}
+
+			continue
+		}
+
+		files = append(files, &discordgo.File{
+			Name:        fmt.Sprintf("image-%d.png", len(files)+1),
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(image),
+		})
+	}
+
+	return files, firstErr
+}
+
+// handleImagineCommand handles the imagine command, which generates images of the prompt.
+func handleImagineCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	var prompt, quality string
+	size := defaultImageSize
+	count := 1
+
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "prompt":
+			prompt = option.StringValue()
+		case "size":
+			size = option.StringValue()
+		case "quality":
+			quality = option.StringValue()
+		case "count":
+			count = min(max(int(option.IntValue()), 1), ImageConfig.MaxCount)
+		}
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+		zap.String("prompt", prompt),
+		zap.String("size", size),
+		zap.String("quality", quality),
+		zap.Int("count", count),
+	)
+
+	if ImageConfig.ModelID == "" {
+		respondInteractionError(s, i, serverConfig.Language, "imagine_unavailable")
+		return
+	}
+
+	if !supportsImageOptions(ImageConfig.ModelID, size, quality) {
+		respondInteractionError(s, i, serverConfig.Language, "image_options_unsupported")
+		return
+	}
+
+	costCalculator := cost.NewCalculator(ImageConfig.ModelID)
+	day := time.Now().In(serverConfig.Location).Format(time.DateOnly)
+
+	// The quotas cannot be enforced on images of an unknown cost.
+	hasQuota := ImageConfig.DailyUserQuota > 0 || ImageConfig.DailyServerQuota > 0
+	if hasQuota && !costCalculator.HasImageCost(quality, size) {
+		respondInteractionError(s, i, serverConfig.Language, "image_unpriced")
+		return
+	}
+
+	numDollars := costCalculator.GetImageCost(quality, size, count)
+	if !reserveImageCost(i.GuildID, i.Member.User.ID, day, numDollars) {
+		respondInteractionError(s, i, serverConfig.Language, "image_quota_exceeded")
+		return
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}); err != nil {
+		refundImageCost(i.GuildID, i.Member.User.ID, day, numDollars)
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), interactionLifetime)
+	defer cancel()
+
+	files, imageErr := generateImages(ctx, openai.ImageRequest{
+		Prompt:         prompt,
+		Model:          ImageConfig.ModelID,
+		N:              1,
+		Quality:        quality,
+		Size:           size,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+		User:           i.Member.User.ID,
+	}, count)
+
+	// Only the images which were generated are charged.
+	refundImageCost(i.GuildID, i.Member.User.ID, day, costCalculator.GetImageCost(quality, size, count-len(files)))
+
+	if len(files) == 0 {
+		Logger.Debug("failed to generate images", zap.Error(imageErr))
+		editInteractionError(s, i, serverConfig.Language, "error_response")
+
+		return
+	}
+
+	if imageErr != nil {
+		Logger.Debug("failed to generate some images", zap.Error(imageErr))
+	}
+
+	shownPrompt := prompt
+	if utf8.RuneCountInString(shownPrompt) > maxShownImagePrompt {
+		shownPrompt = string([]rune(shownPrompt)[:maxShownImagePrompt]) + "…"
+	}
+
+	content := fmt.Sprintf(
+		"🎨 %s\n\n🖼️ %s  📐 %s ×%d  →  %s",
+		shownPrompt, ImageConfig.ModelID, strings.TrimSpace(size+" "+quality), len(files),
+		formatPrices(costCalculator.GetImageCost(quality, size, len(files))),
+	)
+
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Files:   files,
+	}); err != nil {
+		Logger.Debug("failed to upload images", zap.Error(err))
+	}
+}
+
+// respondInteractionError responds to the interaction with an error message seen only by the user.
+func respondInteractionError(s *discordgo.Session, i *discordgo.InteractionCreate, lang locale.Language, errorMessage string) {
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       Localizer.Fetch("error", lang),
+					Description: Localizer.Fetch(errorMessage, lang),
+					Timestamp:   time.Now().Format(time.RFC3339),
+					Color:       0xCC0000,
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
+
+// editInteractionError edits the deferred response of the interaction into an error message.
+func editInteractionError(s *discordgo.Session, i *discordgo.InteractionCreate, lang locale.Language, errorMessage string) {
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{
+			{
+				Title:       Localizer.Fetch("error", lang),
+				Description: Localizer.Fetch(errorMessage, lang),
+				Timestamp:   time.Now().Format(time.RFC3339),
+				Color:       0xCC0000,
+			},
+		},
+	}); err != nil {
+		Logger.Debug("failed to send message", zap.Error(err))
+	}
+}
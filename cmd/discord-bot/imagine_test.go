@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"chatbot-gpt/internal/config"
+)
+
+func TestSupportsImageOptions(t *testing.T) {
+	tests := []struct {
+		modelID, size, quality string
+		want                   bool
+	}{
+		{"dall-e-3", "1792x1024", "hd", true},
+		{"dall-e-3", "1024x1024", "", true},
+		{"dall-e-3", "512x512", "standard", false},
+		{"dall-e-3", "1024x1024", "ultra", false},
+		{"dall-e-2", "256x256", "", true},
+		{"dall-e-2", "1792x1024", "", false},
+		{"dall-e-2", "512x512", "hd", false},
+		{"stable-diffusion", "768x768", "any", true},
+	}
+
+	for _, tt := range tests {
+		if got := supportsImageOptions(tt.modelID, tt.size, tt.quality); got != tt.want {
+			t.Errorf("supportsImageOptions(%q, %q, %q) = %v, want %v", tt.modelID, tt.size, tt.quality, got, tt.want)
+		}
+	}
+}
+
+func TestReserveImageCost(t *testing.T) {
+	ImageConfig = config.Image{DailyUserQuota: 1, DailyServerQuota: 1.5}
+
+	if !reserveImageCost("guild", "alice", "2024-01-01", 0.8) {
+		t.Fatal("reserveImageCost() refused a cost within the quotas")
+	}
+
+	if reserveImageCost("guild", "alice", "2024-01-01", 0.4) {
+		t.Error("reserveImageCost() allowed a cost beyond the user quota")
+	}
+
+	if reserveImageCost("guild", "bob", "2024-01-01", 0.8) {
+		t.Error("reserveImageCost() allowed a cost beyond the server quota")
+	}
+
+	refundImageCost("guild", "alice", "2024-01-01", 0.8)
+
+	if !reserveImageCost("guild", "bob", "2024-01-01", 0.8) {
+		t.Error("reserveImageCost() refused a cost refunded by another user")
+	}
+
+	if !reserveImageCost("guild", "alice", "2024-01-02", 1) {
+		t.Error("reserveImageCost() refused a cost of a new day")
+	}
+}
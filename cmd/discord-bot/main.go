@@ -10,6 +10,8 @@ import (
 )
 
 func main() {
+	initConfig()
+
 	DiscordClient.AddHandler(func(s *discordgo.Session, _ *discordgo.Ready) {
 		botAccount := s.State.User.Username + "#" + s.State.User.Discriminator
 		Logger.Info("logged in as " + botAccount)
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// maxAutocompleteChoices is the maximum number of choices Discord accepts for autocompletion.
+const maxAutocompleteChoices = 25
+
+// modelSelections is the map of models selected by users, keyed by channel and user.
+var modelSelections = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// modelSelectionKey returns the key of the model selection of a user in a channel.
+func modelSelectionKey(channelID, userID string) string {
+	return channelID + ":" + userID
+}
+
+// selectedModel returns the model selected by the user in the channel,
+// or the model of the channel if the user has not selected an allowed one.
+func selectedModel(channelID, userID string, channelConfig ChannelConfig) string {
+	modelSelections.RLock()
+	modelID, ok := modelSelections.m[modelSelectionKey(channelID, userID)]
+	modelSelections.RUnlock()
+
+	if ok && slices.Contains(channelConfig.AllowedModels, modelID) {
+		return modelID
+	}
+
+	return channelConfig.ModelID
+}
+
+// selectModel selects the model for the user in the channel.
+func selectModel(channelID, userID, modelID string) {
+	modelSelections.Lock()
+	defer modelSelections.Unlock()
+
+	modelSelections.m[modelSelectionKey(channelID, userID)] = modelID
+}
+
+// handleModelCommand handles the model command.
+func handleModelCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	channelConfig, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	options := i.ApplicationCommandData().Options
+	input := ""
+	if len(options) > 0 {
+		input = options[0].StringValue()
+	}
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		var choices []*discordgo.ApplicationCommandOptionChoice
+		if isChatChannel {
+			for _, modelID := range channelConfig.AllowedModels {
+				if strings.Contains(modelID, input) && len(choices) < maxAutocompleteChoices {
+					choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: modelID, Value: modelID})
+				}
+			}
+		}
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{Choices: choices},
+		}); err != nil {
+			Logger.Error("failed to respond to autocomplete", zap.Error(err))
+		}
+
+		return
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+		zap.String("model", input),
+	)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "✅ " + Localizer.Fetch("model_selected", serverConfig.Language),
+		Description: input,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Color:       0x379C6F,
+	}
+
+	if !isChatChannel || !slices.Contains(channelConfig.AllowedModels, input) {
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("model_unavailable", serverConfig.Language)
+		embed.Color = 0xCC0000
+	} else {
+		selectModel(i.ChannelID, i.Member.User.ID, input)
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
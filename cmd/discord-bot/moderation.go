@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/moderation"
+)
+
+const (
+	// moderationInput is the direction of the messages of the users.
+	moderationInput = "input"
+
+	// moderationOutput is the direction of the replies of the model.
+	moderationOutput = "output"
+
+	// maxLoggedContent is the maximum number of characters of a flagged message shown in the mod channel.
+	maxLoggedContent = 1000
+)
+
+// moderationEvent is a message moderated in a chat channel.
+type moderationEvent struct {
+	guildID   string
+	channelID string
+	userID    string
+	messageID string
+	direction string
+	content   string
+}
+
+// moderate decides the action on the message by the moderation policy of the server,
+// logging the flagged messages to its mod channel. Messages are allowed if they cannot be scored.
+func moderate(
+	ctx context.Context, s *discordgo.Session, serverConfig ServerConfig, event moderationEvent,
+) moderation.Action {
+	scores, err := Moderator.Score(ctx, event.content)
+	if err != nil && ctx.Err() == nil {
+		// The message is let through unchecked, so the failure is not left to the debug logs.
+		Logger.Warn(
+			"failed to moderate message",
+			zap.String("guildID", event.guildID),
+			zap.String("direction", event.direction),
+			zap.Error(err),
+		)
+	}
+
+	action, categories := serverConfig.Moderation.Policy.Decide(scores)
+	if action == moderation.Allow {
+		return action
+	}
+
+	Logger.Info(
+		"message flagged",
+		zap.String("guildID", event.guildID),
+		zap.String("userID", event.userID),
+		zap.String("direction", event.direction),
+		zap.Stringer("action", action),
+		zap.Strings("categories", categories),
+	)
+
+	if serverConfig.Moderation.LogChannelID != "" {
+		logModerationEvent(s, serverConfig, event, action, categories, scores)
+	}
+
+	return action
+}
+
+// logModerationEvent sends the flagged message to the mod channel of the server.
+func logModerationEvent(
+	s *discordgo.Session, serverConfig ServerConfig, event moderationEvent,
+	action moderation.Action, categories []string, scores moderation.Scores,
+) {
+	content := event.content
+	if utf8.RuneCountInString(content) > maxLoggedContent {
+		content = string([]rune(content)[:maxLoggedContent]) + "…"
+	}
+
+	var flagged []string
+	for _, category := range categories {
+		flagged = append(flagged, fmt.Sprintf("`%s` %.2f", category, scores[category]))
+	}
+
+	color := 0xCC0000
+	if action == moderation.Warn {
+		color = 0xE3A23B
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "🚩 " + Localizer.Fetch("moderation_flagged", serverConfig.Language),
+		Description: fmt.Sprintf(
+			"<@%s> · <#%s> · [%s](https://discord.com/channels/%s/%s/%s)\n%s",
+			event.userID, event.channelID, event.direction,
+			event.guildID, event.channelID, event.messageID, "```\n"+strings.ReplaceAll(content, "```", "'''")+"\n```",
+		),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: action.String(), Value: strings.Join(flagged, "\n")},
+		},
+		Timestamp: time.Now().Format(time.RFC3339),
+		Color:     color,
+	}
+
+	if _, err := s.ChannelMessageSendEmbed(serverConfig.Moderation.LogChannelID, embed); err != nil {
+		Logger.Debug("failed to log flagged message", zap.Error(err))
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+)
+
+const (
+	// overLimitReject rejects prompts that exceed the token limit.
+	overLimitReject = "reject"
+
+	// overLimitTruncate truncates prompts to the token limit.
+	overLimitTruncate = "truncate"
+
+	// overLimitSummarize summarizes prompts chunk by chunk until they fit in the token limit.
+	overLimitSummarize = "summarize"
+
+	// truncatedMarker is appended to truncated prompts so that the model knows the content is incomplete.
+	truncatedMarker = "\n\n[truncated]"
+
+	// summarizedMarker is prepended to summarized prompts so that the model knows the content is a summary.
+	summarizedMarker = "[summary of a long message]\n\n"
+
+	// summaryInstructionTokens is the number of tokens reserved for the summarization instruction.
+	summaryInstructionTokens = 128
+
+	// minSummaryTokens is the minimum number of tokens of each chunk summary.
+	minSummaryTokens = 128
+
+	// maxSummaryRounds is the maximum number of reduce rounds before falling back to truncation.
+	maxSummaryRounds = 3
+)
+
+// errNoRoomForPrompt is returned when the token limit leaves no room for any part of the prompt.
+var errNoRoomForPrompt = errors.New("no room for the prompt")
+
+// splitTokens splits the content into chunks that do not exceed the given number of tokens.
+// A character whose bytes are split between tokens at the end of a chunk is moved to the next chunk.
+func splitTokens(modelID, content string, chunkTokens int) []string {
+	tokenizer := tokenizerForModel(modelID)
+	tokens := tokenizer.Encode(content, nil, nil)
+
+	var chunks []string
+	for start := 0; start < len(tokens); {
+		end := min(start+chunkTokens, len(tokens))
+		for back := 0; back < utf8.UTFMax-1 && end < len(tokens) && end-1 > start; back++ {
+			if !endsInSplitRune(tokenizer.Decode(tokens[start:end])) {
+				break
+			}
+
+			end--
+		}
+
+		chunks = append(chunks, strings.ToValidUTF8(tokenizer.Decode(tokens[start:end]), ""))
+		start = end
+	}
+
+	return chunks
+}
+
+// endsInSplitRune reports whether the text ends with the first bytes of a character.
+func endsInSplitRune(text string) bool {
+	r, size := utf8.DecodeLastRuneInString(text)
+	return r == utf8.RuneError && size == 1
+}
+
+// truncateContent truncates the content to fit in the given number of tokens.
+// It returns errNoRoomForPrompt if not even the truncation marker fits.
+func truncateContent(modelID, content string, maxTokens int) (string, error) {
+	tokenizer := tokenizerForModel(modelID)
+	tokens := tokenizer.Encode(content, nil, nil)
+	if len(tokens) <= maxTokens {
+		return content, nil
+	}
+
+	markerTokens := len(tokenizer.Encode(truncatedMarker, nil, nil))
+	if maxTokens <= markerTokens {
+		return "", errNoRoomForPrompt
+	}
+
+	// The bytes of a character split by the truncation are dropped.
+	return strings.ToValidUTF8(tokenizer.Decode(tokens[:maxTokens-markerTokens]), "") + truncatedMarker, nil
+}
+
+// summarizeChunk summarizes a chunk of a long message.
+func summarizeChunk(
+	ctx context.Context, modelID, chunk string, index, total, maxTokens int, userID string,
+) (string, openai.Usage, error) {
+	chatProvider, err := providerForModel(modelID)
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{
+			Role: openai.ChatMessageRoleUser,
+			Content: fmt.Sprintf(
+				"The following is part %d of %d of a message that is too long to be read at once. "+
+					"Summarize it in the same language, keeping every question, instruction, name, "+
+					"number and error message needed to answer it later.\n\n%s",
+				index, total, chunk,
+			),
+		},
+	}
+
+	stream, err := chatProvider.CreateChatStream(ctx, openai.ChatCompletionRequest{
+		Model:     modelID,
+		MaxTokens: maxTokens,
+		User:      userID,
+		Messages:  messages,
+	})
+	if err != nil {
+		return "", openai.Usage{}, err
+	}
+
+	defer stream.Close()
+
+	var summary strings.Builder
+	for {
+		resp, recvErr := stream.Recv()
+		if errors.Is(recvErr, io.EOF) {
+			break
+		}
+
+		if recvErr != nil {
+			return "", openai.Usage{}, recvErr
+		}
+
+		if len(resp.Choices) > 0 {
+			summary.WriteString(resp.Choices[0].Delta.Content)
+		}
+	}
+
+	if usage := stream.Usage(); usage != nil {
+		return summary.String(), *usage, nil
+	}
+
+	// The tokens are predicted for the providers which do not report the usage.
+	usage := openai.Usage{
+		PromptTokens: predictTokens(modelID, messages, true),
+		CompletionTokens: predictTokens(
+			modelID, []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleAssistant, Content: summary.String()}}, false,
+		),
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	return summary.String(), usage, nil
+}
+
+// summarizeContent summarizes the content map-reduce style until it fits in the given number of tokens.
+// Each chunk summary does not exceed the given completion token limit.
+func summarizeContent(
+	ctx context.Context, modelID, content string, maxTokens, completionTokenLimit int, userID string,
+) (string, openai.Usage, error) {
+	var usage openai.Usage
+
+	chunkTokens := maxTokens - summaryInstructionTokens
+	if chunkTokens <= 0 {
+		truncated, err := truncateContent(modelID, content, maxTokens)
+		return truncated, usage, err
+	}
+
+	for round := 0; round < maxSummaryRounds; round++ {
+		if len(tokenizerForModel(modelID).Encode(content, nil, nil)) <= maxTokens {
+			return content, usage, nil
+		}
+
+		chunks := splitTokens(modelID, content, chunkTokens)
+		summaries := make([]string, 0, len(chunks))
+		summaryTokens := min(max(maxTokens/len(chunks), minSummaryTokens), completionTokenLimit)
+
+		for i, chunk := range chunks {
+			summary, chunkUsage, err := summarizeChunk(ctx, modelID, chunk, i+1, len(chunks), summaryTokens, userID)
+			if err != nil {
+				return "", usage, err
+			}
+
+			usage.PromptTokens += chunkUsage.PromptTokens
+			usage.CompletionTokens += chunkUsage.CompletionTokens
+			usage.TotalTokens += chunkUsage.TotalTokens
+			summaries = append(summaries, summary)
+		}
+
+		content = strings.Join(summaries, "\n\n")
+
+		Logger.Debug(
+			"summarized long prompt",
+			zap.Int("round", round+1),
+			zap.Int("chunks", len(chunks)),
+		)
+	}
+
+	truncated, err := truncateContent(modelID, content, maxTokens)
+
+	return truncated, usage, err
+}
+
+// fitPrompt applies the over-limit strategy to a prompt that exceeds the given number of tokens.
+// It returns the fitted prompt, the usage spent on fitting it and the notice to show to the user,
+// or a nil prompt if it is rejected or the token limit leaves no room for it.
+func fitPrompt(
+	ctx context.Context, modelID, strategy string, prompt openai.ChatCompletionMessage,
+	maxTokens, completionTokenLimit int, userID string,
+) (*openai.ChatCompletionMessage, openai.Usage, string, error) {
+	// Leave room for the per-message overhead counted by predictTokens.
+	contentTokens := maxTokens - predictTokens(modelID, []openai.ChatCompletionMessage{{Role: prompt.Role}}, false)
+
+	switch strategy {
+	case overLimitTruncate:
+		truncated, err := truncateContent(modelID, prompt.Content, contentTokens)
+		if errors.Is(err, errNoRoomForPrompt) {
+			return nil, openai.Usage{}, "", nil
+		}
+
+		prompt.Content = truncated
+		return &prompt, openai.Usage{}, "prompt_truncated", nil
+	case overLimitSummarize:
+		markerTokens := len(tokenizerForModel(modelID).Encode(summarizedMarker, nil, nil))
+		summary, usage, err := summarizeContent(
+			ctx, modelID, prompt.Content, contentTokens-markerTokens, completionTokenLimit, userID,
+		)
+		if errors.Is(err, errNoRoomForPrompt) {
+			return nil, usage, "", nil
+		}
+
+		if err != nil {
+			return nil, usage, "", err
+		}
+
+		prompt.Content = summarizedMarker + summary
+		return &prompt, usage, "prompt_summarized", nil
+	}
+
+	return nil, openai.Usage{}, "", nil
+}
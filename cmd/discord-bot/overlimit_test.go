@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// byteLoader loads encodings whose tokens are single bytes, so that the tokenizers need no download
+// and split the characters of several bytes between tokens.
+type byteLoader struct{}
+
+func (byteLoader) LoadTiktokenBpe(string) (map[string]int, error) {
+	ranks := make(map[string]int, 256)
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+
+	return ranks, nil
+}
+
+func init() {
+	tiktoken.SetBpeLoader(byteLoader{})
+}
+
+func TestTruncateContent(t *testing.T) {
+	content := strings.Repeat("é", 20)
+	markerTokens := len(truncatedMarker)
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		want      string
+		err       error
+	}{
+		{name: "fits", maxTokens: len(content), want: content},
+		{name: "character boundary", maxTokens: markerTokens + 4, want: "éé" + truncatedMarker},
+		{name: "split character", maxTokens: markerTokens + 3, want: "é" + truncatedMarker},
+		{name: "no room", maxTokens: markerTokens, err: errNoRoomForPrompt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := truncateContent("gpt-4", content, tt.maxTokens)
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("truncateContent() = %q, %v, want %q, %v", got, err, tt.want, tt.err)
+			}
+
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateContent() = %q, which is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	content := "aéb€c"
+
+	chunks := splitTokens("gpt-4", content, 3)
+	if strings.Join(chunks, "") != content {
+		t.Errorf("splitTokens() = %q, want the chunks of %q", chunks, content)
+	}
+
+	for _, chunk := range chunks {
+		if !utf8.ValidString(chunk) || len(chunk) > 3 {
+			t.Errorf("chunk %q is not valid UTF-8 of at most 3 tokens", chunk)
+		}
+	}
+}
+
+func TestFitPrompt(t *testing.T) {
+	prompt := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser, Content: strings.Repeat("long prompt ", 10)}
+	roleTokens := predictTokens("gpt-4", []openai.ChatCompletionMessage{{Role: prompt.Role}}, false)
+
+	tests := []struct {
+		name      string
+		strategy  string
+		maxTokens int
+		want      string
+		notice    string
+	}{
+		{name: "reject", strategy: overLimitReject, maxTokens: roleTokens + 50},
+		{
+			name:      "truncate",
+			strategy:  overLimitTruncate,
+			maxTokens: roleTokens + len(truncatedMarker) + 4,
+			want:      "long" + truncatedMarker,
+			notice:    "prompt_truncated",
+		},
+		{name: "no room to truncate", strategy: overLimitTruncate, maxTokens: roleTokens + len(truncatedMarker)},
+		{name: "no room to summarize", strategy: overLimitSummarize, maxTokens: roleTokens + len(summarizedMarker)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fitted, usage, notice, err := fitPrompt(context.Background(), "gpt-4", tt.strategy, prompt, tt.maxTokens, 100, "user")
+			if err != nil || notice != tt.notice || usage != (openai.Usage{}) {
+				t.Fatalf("fitPrompt() = %v, %q, %v, want %q", usage, notice, err, tt.notice)
+			}
+
+			if tt.want == "" {
+				if fitted != nil {
+					t.Errorf("fitPrompt() = %q, want no prompt", fitted.Content)
+				}
+
+				return
+			}
+
+			if fitted == nil || fitted.Content != tt.want || fitted.Role != prompt.Role {
+				t.Errorf("fitPrompt() = %+v, want %q", fitted, tt.want)
+			}
+		})
+	}
+}
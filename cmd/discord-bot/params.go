@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/config"
+)
+
+// adminPermissions is the permissions required to use the admin commands.
+var adminPermissions int64 = discordgo.PermissionAdministrator
+
+// channelSampling returns the sampling parameters of the channel with the persona,
+// the parameters of the persona taking precedence.
+func channelSampling(channelConfig ChannelConfig, persona Persona) config.Sampling {
+	return channelConfig.Sampling.Merge(persona.Sampling)
+}
+
+// applySampling sets the sampling parameters to the request.
+func applySampling(request *openai.ChatCompletionRequest, sampling config.Sampling) {
+	if sampling.Temperature != nil {
+		request.Temperature = *sampling.Temperature
+
+		// A zero temperature is omitted from the request, which means the default of the provider.
+		if request.Temperature == 0 {
+			request.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+
+	if sampling.TopP != nil {
+		request.TopP = *sampling.TopP
+	}
+
+	if sampling.PresencePenalty != nil {
+		request.PresencePenalty = *sampling.PresencePenalty
+	}
+
+	if sampling.FrequencyPenalty != nil {
+		request.FrequencyPenalty = *sampling.FrequencyPenalty
+	}
+
+	request.Stop = sampling.Stop
+	request.Seed = sampling.Seed
+	request.LogitBias = sampling.LogitBias
+}
+
+// samplingFields returns the embed fields of the sampling parameters.
+func samplingFields(sampling config.Sampling, defaultValue string) []*discordgo.MessageEmbedField {
+	float := func(value *float32) string {
+		if value == nil {
+			return defaultValue
+		}
+
+		return fmt.Sprintf("%g", *value)
+	}
+
+	stop := defaultValue
+	if len(sampling.Stop) > 0 {
+		stop = fmt.Sprintf("%q", sampling.Stop)
+	}
+
+	seed := defaultValue
+	if sampling.Seed != nil {
+		seed = fmt.Sprint(*sampling.Seed)
+	}
+
+	logitBias := defaultValue
+	if len(sampling.LogitBias) > 0 {
+		var biases []string
+		for token, bias := range sampling.LogitBias {
+			biases = append(biases, fmt.Sprintf("%s: %d", token, bias))
+		}
+
+		slices.Sort(biases)
+		logitBias = strings.Join(biases, ", ")
+	}
+
+	return []*discordgo.MessageEmbedField{
+		{Name: "temperature", Value: float(sampling.Temperature), Inline: true},
+		{Name: "top_p", Value: float(sampling.TopP), Inline: true},
+		{Name: "seed", Value: seed, Inline: true},
+		{Name: "presence_penalty", Value: float(sampling.PresencePenalty), Inline: true},
+		{Name: "frequency_penalty", Value: float(sampling.FrequencyPenalty), Inline: true},
+		{Name: "stop", Value: stop, Inline: true},
+		{Name: "logit_bias", Value: logitBias},
+	}
+}
+
+// handleParamsCommand handles the params command, which shows the sampling parameters of the channel to admins.
+func handleParamsCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+	)
+
+	channelConfig, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	embed := &discordgo.MessageEmbed{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Color:     0x379C6F,
+	}
+
+	switch {
+	case i.Member.Permissions&adminPermissions == 0:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("permission_denied", serverConfig.Language)
+		embed.Color = 0xCC0000
+	case !isChatChannel:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("not_chat_channel", serverConfig.Language)
+		embed.Color = 0xCC0000
+	default:
+		persona := selectedPersona(i.ChannelID, channelConfig)
+		modelID := selectedModel(i.ChannelID, i.Member.User.ID, channelConfig)
+
+		embed.Title = Localizer.Fetch("params", serverConfig.Language)
+		embed.Description = "🤖 " + modelID
+		if persona.Name != "" {
+			embed.Description += "  🎭 " + personaDisplayName(persona)
+		}
+
+		embed.Fields = samplingFields(
+			channelSampling(channelConfig, persona), Localizer.Fetch("params_default", serverConfig.Language),
+		)
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/model"
+	"chatbot-gpt/internal/prompt"
+)
+
+// personaPermissions is the permissions required to switch the persona of a channel.
+var personaPermissions int64 = discordgo.PermissionManageChannels
+
+// personaSelections is the map of personas selected in channels, keyed by channel.
+var personaSelections = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// selectedPersona returns the persona selected in the channel,
+// or the persona of the channel if no allowed one has been selected.
+func selectedPersona(channelID string, channelConfig ChannelConfig) Persona {
+	personaSelections.RLock()
+	name, ok := personaSelections.m[channelID]
+	personaSelections.RUnlock()
+
+	if !ok || !slices.Contains(channelConfig.AllowedPersonas, name) {
+		name = channelConfig.Persona
+	}
+
+	return Personas[name]
+}
+
+// selectPersona selects the persona of the channel.
+func selectPersona(channelID, name string) {
+	personaSelections.Lock()
+	defer personaSelections.Unlock()
+
+	personaSelections.m[channelID] = name
+}
+
+// personaDisplayName returns the name of the persona shown to users.
+func personaDisplayName(persona Persona) string {
+	if persona.DisplayName != "" {
+		return persona.DisplayName
+	}
+
+	return persona.Name
+}
+
+// promptData returns the data of the system prompt templates for the message.
+func promptData(s *discordgo.Session, data *discordgo.MessageCreate, location *time.Location) *prompt.Data {
+	user := prompt.User{
+		ID:          data.Author.ID,
+		Name:        data.Author.Username,
+		DisplayName: data.Author.Username,
+	}
+
+	if data.Member != nil && data.Member.Nick != "" {
+		user.DisplayName = data.Member.Nick
+	}
+
+	guild := prompt.Guild{ID: data.GuildID}
+	if g, err := s.State.Guild(data.GuildID); err == nil {
+		guild.Name = g.Name
+	} else if g, err := s.Guild(data.GuildID); err == nil {
+		guild.Name = g.Name
+	}
+
+	channel := prompt.Channel{ID: data.ChannelID}
+	if c, err := s.State.Channel(data.ChannelID); err == nil {
+		channel.Name, channel.Topic = c.Name, c.Topic
+	} else if c, err := s.Channel(data.ChannelID); err == nil {
+		channel.Name, channel.Topic = c.Name, c.Topic
+	}
+
+	return prompt.NewData(time.Now().In(location), user, guild, channel, func() ([]string, error) {
+		messages, err := s.ChannelMessagesPinned(data.ChannelID)
+		if err != nil {
+			return nil, err
+		}
+
+		var contents []string
+		for _, message := range messages {
+			if message.Content != "" {
+				contents = append(contents, message.Content)
+			}
+		}
+
+		return contents, nil
+	})
+}
+
+// systemMessages returns the messages of the system prompt of the persona, rendered for the message.
+func systemMessages(
+	s *discordgo.Session, data *discordgo.MessageCreate, persona Persona, location *time.Location,
+) ([]openai.ChatCompletionMessage, error) {
+	if persona.SystemPrompt == nil {
+		return nil, nil
+	}
+
+	content, err := persona.SystemPrompt.Execute(promptData(s, data, location))
+	if err != nil {
+		return nil, err
+	}
+
+	return []openai.ChatCompletionMessage{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: content,
+		},
+	}, nil
+}
+
+// adaptSystemRole converts the system messages to user messages for models without the system role.
+func adaptSystemRole(messages []openai.ChatCompletionMessage, capability model.Capability) []openai.ChatCompletionMessage {
+	if capability.SupportsSystemRole {
+		return messages
+	}
+
+	adapted := slices.Clone(messages)
+	for i := range adapted {
+		if adapted[i].Role == openai.ChatMessageRoleSystem {
+			adapted[i].Role = openai.ChatMessageRoleUser
+		}
+	}
+
+	return adapted
+}
+
+// handlePersonaCommand handles the persona command.
+func handlePersonaCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	channelConfig, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	options := i.ApplicationCommandData().Options
+	input := ""
+	if len(options) > 0 {
+		input = options[0].StringValue()
+	}
+
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		var choices []*discordgo.ApplicationCommandOptionChoice
+		if isChatChannel {
+			for _, name := range channelConfig.AllowedPersonas {
+				if name != "" && strings.Contains(name, input) && len(choices) < maxAutocompleteChoices {
+					choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: name, Value: name})
+				}
+			}
+		}
+
+		if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+			Data: &discordgo.InteractionResponseData{Choices: choices},
+		}); err != nil {
+			Logger.Error("failed to respond to autocomplete", zap.Error(err))
+		}
+
+		return
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+		zap.String("persona", input),
+	)
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "✅ " + Localizer.Fetch("persona_selected", serverConfig.Language),
+		Description: personaDisplayName(Personas[input]),
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Color:       0x379C6F,
+	}
+
+	var flags discordgo.MessageFlags
+
+	switch {
+	case i.Member.Permissions&personaPermissions == 0:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("permission_denied", serverConfig.Language)
+		embed.Color = 0xCC0000
+		flags = discordgo.MessageFlagsEphemeral
+	case !isChatChannel || !slices.Contains(channelConfig.AllowedPersonas, input):
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("persona_unavailable", serverConfig.Language)
+		embed.Color = 0xCC0000
+		flags = discordgo.MessageFlagsEphemeral
+	default:
+		selectPersona(i.ChannelID, input)
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  flags,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/locale"
+)
+
+const (
+	// replyCacheSize is the number of recent replies kept for their buttons.
+	replyCacheSize = 256
+
+	// regenerateButtonID is the custom ID of the button answering the message of a reply again.
+	regenerateButtonID = "regenerate"
+
+	// continueButtonID is the custom ID of the button continuing a reply cut off by the token limit.
+	continueButtonID = "continue"
+
+	// branchButtonID is the custom ID of the button opening a thread from a reply.
+	branchButtonID = "branch"
+
+	// continuePrompt is the prompt asking the model to continue its answer.
+	continuePrompt = "Continue exactly where your last answer was cut off, without repeating it."
+
+	// branchArchiveDuration is the number of minutes without messages after which a branch thread is archived.
+	branchArchiveDuration = 1440
+
+	// maxThreadName is the maximum number of characters of a thread name.
+	maxThreadName = 100
+)
+
+// reply is a reply of the bot to a message in a chat channel.
+type reply struct {
+	// message is the message answered by the reply, which is answered again to regenerate it.
+	message *discordgo.Message
+
+	// text is the text of the answer, without the notices and the footer.
+	text string
+
+	// historyKey is the key of the history storing the interaction.
+	historyKey string
+
+	// numMessages is the number of messages of the interaction in the history.
+	numMessages int
+
+	// depth is the number of messages in the history once the interaction was stored.
+	depth int
+
+	// finishReason is the reason the model stopped answering.
+	finishReason openai.FinishReason
+
+	// continuation reports whether the reply continues the previous one, its message being the transient instruction.
+	continuation bool
+}
+
+// replies keeps the recent replies by the ID of their last message, which holds their buttons,
+// and the ID of the latest reply of each history.
+var replies = struct {
+	sync.Mutex
+	order  []string
+	m      map[string]reply
+	latest map[string]string
+}{m: make(map[string]reply), latest: make(map[string]string)}
+
+// branches are the parent channels of the threads opened from replies, by thread.
+var branches = struct {
+	sync.RWMutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// rememberReply keeps the reply ending with the message as the latest of its history.
+func rememberReply(messageID string, r reply) {
+	replies.Lock()
+	defer replies.Unlock()
+
+	if _, ok := replies.m[messageID]; !ok {
+		replies.order = append(replies.order, messageID)
+	}
+
+	replies.m[messageID] = r
+	replies.latest[r.historyKey] = messageID
+
+	for len(replies.order) > replyCacheSize {
+		evicted := replies.order[0]
+		if historyKey := replies.m[evicted].historyKey; replies.latest[historyKey] == evicted {
+			delete(replies.latest, historyKey)
+		}
+
+		delete(replies.m, evicted)
+		replies.order = replies.order[1:]
+	}
+}
+
+// replyOf returns the reply ending with the message.
+func replyOf(messageID string) (reply, bool) {
+	replies.Lock()
+	defer replies.Unlock()
+
+	r, ok := replies.m[messageID]
+
+	return r, ok
+}
+
+// takeLatestReply reports whether the reply ending with the message is the last interaction of its history,
+// in which case it is no longer the latest, so that it is regenerated or continued only once.
+func takeLatestReply(messageID string) bool {
+	replies.Lock()
+	defer replies.Unlock()
+
+	r, ok := replies.m[messageID]
+	if !ok || replies.latest[r.historyKey] != messageID || MessageDatabase.Count(r.historyKey) != r.depth {
+		return false
+	}
+
+	delete(replies.latest, r.historyKey)
+
+	return true
+}
+
+// isBranch reports whether the channel is a thread opened from a reply.
+func isBranch(channelID string) bool {
+	branches.RLock()
+	defer branches.RUnlock()
+
+	_, ok := branches.m[channelID]
+
+	return ok
+}
+
+// chatChannelConfig returns the config of the chat channel, which is the config of its parent for a branch thread.
+func chatChannelConfig(serverConfig ServerConfig, channelID string) (ChannelConfig, bool) {
+	if channelConfig, ok := serverConfig.ChatChannels[channelID]; ok {
+		return channelConfig, true
+	}
+
+	branches.RLock()
+	parentID, ok := branches.m[channelID]
+	branches.RUnlock()
+
+	if !ok {
+		return ChannelConfig{}, false
+	}
+
+	channelConfig, ok := serverConfig.ChatChannels[parentID]
+
+	return channelConfig, ok
+}
+
+// historyKey returns the key of the history of the user in the channel.
+// The history is shared by the chat channels, except in the branch threads which have their own.
+func historyKey(channelID, userID string) string {
+	if isBranch(channelID) {
+		return modelSelectionKey(channelID, userID)
+	}
+
+	return userID
+}
+
+// replyComponents returns the buttons of the reply in the channel.
+func replyComponents(r reply, channelID string, withSpeech bool, lang locale.Language) []discordgo.MessageComponent {
+	buttons := []discordgo.MessageComponent{
+		discordgo.Button{
+			Label:    Localizer.Fetch("regenerate", lang),
+			Emoji:    discordgo.ComponentEmoji{Name: "🔄"},
+			Style:    discordgo.SecondaryButton,
+			CustomID: regenerateButtonID,
+		},
+	}
+
+	if r.finishReason == openai.FinishReasonLength {
+		buttons = append(buttons, discordgo.Button{
+			Label:    Localizer.Fetch("continue", lang),
+			Emoji:    discordgo.ComponentEmoji{Name: "⏩"},
+			Style:    discordgo.SecondaryButton,
+			CustomID: continueButtonID,
+		})
+	}
+
+	// Threads cannot be opened in threads.
+	if !isBranch(channelID) {
+		buttons = append(buttons, discordgo.Button{
+			Label:    Localizer.Fetch("branch", lang),
+			Emoji:    discordgo.ComponentEmoji{Name: "🌿"},
+			Style:    discordgo.SecondaryButton,
+			CustomID: branchButtonID,
+		})
+	}
+
+	if withSpeech {
+		buttons = append(buttons, discordgo.Button{
+			Emoji:    discordgo.ComponentEmoji{Name: "🔊"},
+			Style:    discordgo.SecondaryButton,
+			CustomID: speechButtonID,
+		})
+	}
+
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+}
+
+// withoutButtons returns the components of a received message without the buttons of the custom IDs.
+func withoutButtons(components []discordgo.MessageComponent, customIDs ...string) []discordgo.MessageComponent {
+	kept := []discordgo.MessageComponent{}
+
+	for _, component := range components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			kept = append(kept, component)
+			continue
+		}
+
+		var buttons []discordgo.MessageComponent
+		for _, rowComponent := range row.Components {
+			if button, ok := rowComponent.(*discordgo.Button); ok && slices.Contains(customIDs, button.CustomID) {
+				continue
+			}
+
+			buttons = append(buttons, rowComponent)
+		}
+
+		if len(buttons) > 0 {
+			kept = append(kept, discordgo.ActionsRow{Components: buttons})
+		}
+	}
+
+	return kept
+}
+
+// replyForButton returns the reply of the button, responding with an error unless it is known and asked by the user.
+func replyForButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) (reply, bool) {
+	Logger.Debug(
+		"received interaction",
+		zap.String("component", i.MessageComponentData().CustomID),
+		zap.String("user", i.Member.User.Username),
+		zap.String("message", i.Message.ID),
+	)
+
+	r, ok := replyOf(i.Message.ID)
+	if !ok {
+		respondInteractionError(s, i, serverConfig.Language, "reply_expired")
+		return reply{}, false
+	}
+
+	if r.message.Author.ID != i.Member.User.ID {
+		respondInteractionError(s, i, serverConfig.Language, "reply_not_yours")
+		return reply{}, false
+	}
+
+	return r, true
+}
+
+// removeButtons acknowledges the interaction by removing the buttons of the custom IDs from its message.
+func removeButtons(s *discordgo.Session, i *discordgo.InteractionCreate, customIDs ...string) {
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+		return
+	}
+
+	components := withoutButtons(i.Message.Components, customIDs...)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Components: &components,
+	}); err != nil {
+		Logger.Debug("failed to remove the buttons", zap.Error(err))
+	}
+}
+
+// handleRegenerateButton handles the regenerate button, which replaces the last interaction of the history
+// with a new answer to the same message.
+func handleRegenerateButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	r, ok := replyForButton(s, i, serverConfig)
+	if !ok {
+		return
+	}
+
+	if !takeLatestReply(i.Message.ID) {
+		respondInteractionError(s, i, serverConfig.Language, "reply_outdated")
+		return
+	}
+
+	MessageDatabase.Remove(r.historyKey, r.numMessages)
+	removeButtons(s, i, regenerateButtonID, continueButtonID)
+
+	answerMessage(s, &discordgo.MessageCreate{Message: r.message}, r.continuation)
+}
+
+// handleContinueButton handles the continue button, which asks the model to continue the reply.
+func handleContinueButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	if _, ok := replyForButton(s, i, serverConfig); !ok {
+		return
+	}
+
+	if !takeLatestReply(i.Message.ID) {
+		respondInteractionError(s, i, serverConfig.Language, "reply_outdated")
+		return
+	}
+
+	removeButtons(s, i, regenerateButtonID, continueButtonID)
+
+	// The continuation answers the reply as if the user asked for it, without storing the request in the history.
+	answerMessage(s, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        i.Message.ID,
+			ChannelID: i.ChannelID,
+			GuildID:   i.GuildID,
+			Content:   continuePrompt,
+			Author:    i.Member.User,
+			Member:    i.Member,
+		},
+	}, true)
+}
+
+// handleBranchButton handles the branch button, which opens a thread from the reply
+// whose history is the history of the user up to the reply.
+func handleBranchButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	r, ok := replyForButton(s, i, serverConfig)
+	if !ok {
+		return
+	}
+
+	channelConfig, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	// The messages stored after the reply are left out, unless the history was cleared since.
+	skip := MessageDatabase.Count(r.historyKey) - r.depth
+	if !isChatChannel || skip < 0 {
+		respondInteractionError(s, i, serverConfig.Language, "reply_outdated")
+		return
+	}
+
+	thread, err := s.MessageThreadStartComplex(i.ChannelID, i.Message.ID, &discordgo.ThreadStart{
+		Name:                threadName(r.text, serverConfig.Language),
+		AutoArchiveDuration: branchArchiveDuration,
+	})
+	if err != nil {
+		Logger.Debug("failed to open a thread", zap.Error(err))
+		respondInteractionError(s, i, serverConfig.Language, "branch_failed")
+
+		return
+	}
+
+	branches.Lock()
+	branches.m[thread.ID] = i.ChannelID
+	branches.Unlock()
+
+	userID := i.Member.User.ID
+	MessageDatabase.Copy(r.historyKey, historyKey(thread.ID, userID), skip)
+	selectModel(thread.ID, userID, selectedModel(i.ChannelID, userID, channelConfig))
+	selectPersona(thread.ID, selectedPersona(i.ChannelID, channelConfig).Name)
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title:       "✅ " + Localizer.Fetch("branch_created", serverConfig.Language),
+					Description: "<#" + thread.ID + ">",
+					Timestamp:   time.Now().Format(time.RFC3339),
+					Color:       0x379C6F,
+				},
+			},
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
+
+// threadName returns the name of a thread opened from the reply, which is its first line.
+func threadName(text string, lang locale.Language) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	name = strings.TrimSpace(strings.Trim(name, "#*_`> "))
+
+	if name == "" {
+		return Localizer.Fetch("branch", lang)
+	}
+
+	if utf8.RuneCountInString(name) > maxThreadName {
+		name = string([]rune(name)[:maxThreadName-1]) + "…"
+	}
+
+	return name
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRememberReplyEviction(t *testing.T) {
+	t.Cleanup(func() {
+		replies.order = nil
+		replies.m = make(map[string]reply)
+		replies.latest = make(map[string]string)
+	})
+
+	// The first reply is the latest of its history until it expires.
+	rememberReply("first", reply{historyKey: "quiet"})
+	for n := 0; n < replyCacheSize; n++ {
+		rememberReply(fmt.Sprintf("reply-%d", n), reply{historyKey: fmt.Sprintf("user-%d", n%2)})
+	}
+
+	if _, ok := replyOf("first"); ok {
+		t.Error("replyOf() found the expired reply")
+	}
+
+	if _, ok := replies.latest["quiet"]; ok {
+		t.Error("the expired reply is still the latest of its history")
+	}
+
+	if len(replies.m) != replyCacheSize || len(replies.latest) != 2 {
+		t.Errorf("kept %d replies and %d latest, want %d and 2", len(replies.m), len(replies.latest), replyCacheSize)
+	}
+
+	if got := replies.latest["user-1"]; got != fmt.Sprintf("reply-%d", replyCacheSize-1) {
+		t.Errorf("latest reply of user-1 = %q", got)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/cost"
+	"chatbot-gpt/internal/locale"
+)
+
+const (
+	// maxSpeechInput is the maximum number of characters read aloud by the speech API.
+	maxSpeechInput = 4096
+
+	// speechButtonID is the custom ID of the button reading a reply aloud.
+	speechButtonID = "speech"
+)
+
+// speechContentTypes are the content types of the speech formats.
+var speechContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+}
+
+// citationPattern matches the footnote citations of the replies, which are not read aloud.
+var citationPattern = regexp.MustCompile(`\[\d+\]`)
+
+// speechSelections is the map of the speech preferences of users, keyed by channel and user.
+var speechSelections = struct {
+	sync.RWMutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+// speechTextOf returns the text of the reply ending with the message, so that the whole reply is read without its footer.
+// Replies older than the cache are read from the message, without the notices and the footer.
+func speechTextOf(message *discordgo.Message) string {
+	if r, ok := replyOf(message.ID); ok {
+		return r.text
+	}
+
+	text := message.Content
+	for _, marker := range []string{"\n\n`[", "\n\n⚠️ ", "\n\n🤖 "} {
+		if i := strings.Index(text, marker); i >= 0 {
+			text = text[:i]
+		}
+	}
+
+	return text
+}
+
+// speechSelected reports whether the replies to the user in the channel are read aloud,
+// as selected by the user or else by the channel.
+func speechSelected(channelID, userID string, channelConfig ChannelConfig) bool {
+	speechSelections.RLock()
+	defer speechSelections.RUnlock()
+
+	if enabled, ok := speechSelections.m[modelSelectionKey(channelID, userID)]; ok {
+		return enabled
+	}
+
+	return channelConfig.Speech
+}
+
+// selectSpeech selects whether the replies to the user in the channel are read aloud.
+func selectSpeech(channelID, userID string, enabled bool) {
+	speechSelections.Lock()
+	defer speechSelections.Unlock()
+
+	speechSelections.m[modelSelectionKey(channelID, userID)] = enabled
+}
+
+// speechInput returns the text read aloud, without citations and cut to the limit of the speech API.
+func speechInput(text string) string {
+	text = strings.TrimSpace(citationPattern.ReplaceAllString(text, ""))
+
+	if utf8.RuneCountInString(text) > maxSpeechInput {
+		text = string([]rune(text)[:maxSpeechInput])
+	}
+
+	return text
+}
+
+// synthesizeSpeech reads the text aloud with the voice, returning the audio file and the number of characters read.
+func synthesizeSpeech(ctx context.Context, text, voice string) (*discordgo.File, int, error) {
+	chatProvider, err := providerForModel(SpeechConfig.ModelID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	input := speechInput(text)
+
+	audio, err := chatProvider.CreateSpeech(ctx, openai.CreateSpeechRequest{
+		Model:          openai.SpeechModel(SpeechConfig.ModelID),
+		Input:          input,
+		Voice:          openai.SpeechVoice(voice),
+		ResponseFormat: openai.SpeechResponseFormat(SpeechConfig.Format),
+		Speed:          SpeechConfig.Speed,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer audio.Close()
+
+	data, err := io.ReadAll(audio)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &discordgo.File{
+		Name:        "reply." + SpeechConfig.Format,
+		ContentType: speechContentTypes[SpeechConfig.Format],
+		Reader:      bytes.NewReader(data),
+	}, utf8.RuneCountInString(input), nil
+}
+
+// getSpeechCostPriceString returns the cost price of reading the number of characters aloud.
+func getSpeechCostPriceString(numChars int) string {
+	numDollars := cost.NewCalculator(SpeechConfig.ModelID).GetSpeechCost(numChars)
+
+	return fmt.Sprintf("🔊 %s  🔤 %d  →  %s", SpeechConfig.ModelID, numChars, formatPrices(numDollars))
+}
+
+// speechAttachment returns the audio of the reply if the user selected speech, adding its cost to the footer.
+// It returns nil if the reply is not read aloud.
+func speechAttachment(
+	ctx context.Context, response *discordResponse, text, channelID, userID string, channelConfig ChannelConfig,
+) *discordgo.File {
+	if !speechSelected(channelID, userID, channelConfig) {
+		return nil
+	}
+
+	file, numChars, err := synthesizeSpeech(ctx, text, channelConfig.Voice)
+	if err != nil {
+		Logger.Debug("failed to synthesize speech", zap.Error(err))
+		return nil
+	}
+
+	response.content += "\n" + getSpeechCostPriceString(numChars)
+
+	return file
+}
+
+// handleSpeechButton handles the speech button, which attaches the audio of the reply to its message.
+func handleSpeechButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	if SpeechConfig.ModelID == "" {
+		return
+	}
+
+	channelConfig, _ := chatChannelConfig(serverConfig, i.ChannelID)
+
+	voice := channelConfig.Voice
+	if voice == "" {
+		voice = SpeechConfig.Voice
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("component", speechButtonID),
+		zap.String("user", i.Member.User.Username),
+		zap.String("message", i.Message.ID),
+	)
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+		return
+	}
+
+	file, numChars, err := synthesizeSpeech(context.Background(), speechTextOf(i.Message), voice)
+	if err != nil {
+		Logger.Debug("failed to synthesize speech", zap.Error(err))
+		sendInteractionError(s, i, serverConfig.Language, "speech_failed")
+
+		return
+	}
+
+	content := i.Message.Content
+	if footer := "\n" + getSpeechCostPriceString(numChars); len(content)+len(footer) <= 2000 {
+		content += footer
+	}
+
+	// The other buttons of the reply are kept.
+	components := withoutButtons(i.Message.Components, speechButtonID)
+	if _, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+		Files:      []*discordgo.File{file},
+	}); err != nil {
+		Logger.Debug("failed to attach speech", zap.Error(err))
+		sendInteractionError(s, i, serverConfig.Language, "speech_failed")
+	}
+}
+
+// handleSpeechCommand handles the speech command, which selects whether the replies to the user are read aloud.
+func handleSpeechCommand(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	_, isChatChannel := chatChannelConfig(serverConfig, i.ChannelID)
+
+	enabled := true
+	if options := i.ApplicationCommandData().Options; len(options) > 0 {
+		enabled = options[0].BoolValue()
+	}
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("command", i.ApplicationCommandData().Name),
+		zap.String("user", i.Member.User.Username),
+		zap.Bool("enabled", enabled),
+	)
+
+	embed := &discordgo.MessageEmbed{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Color:     0x379C6F,
+	}
+
+	switch {
+	case !isChatChannel:
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("not_chat_channel", serverConfig.Language)
+		embed.Color = 0xCC0000
+	case SpeechConfig.ModelID == "":
+		embed.Title = Localizer.Fetch("error", serverConfig.Language)
+		embed.Description = Localizer.Fetch("speech_unavailable", serverConfig.Language)
+		embed.Color = 0xCC0000
+	default:
+		selectSpeech(i.ChannelID, i.Member.User.ID, enabled)
+
+		embed.Title = "✅ " + Localizer.Fetch("speech_disabled", serverConfig.Language)
+		if enabled {
+			embed.Title = "✅ " + Localizer.Fetch("speech_enabled", serverConfig.Language)
+		}
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
+
+// sendInteractionError sends an error message to the user of a deferred interaction.
+func sendInteractionError(s *discordgo.Session, i *discordgo.InteractionCreate, lang locale.Language, errorMessage string) {
+	if _, err := s.FollowupMessageCreate(i.Interaction, false, &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       Localizer.Fetch("error", lang),
+				Description: Localizer.Fetch(errorMessage, lang),
+				Timestamp:   time.Now().Format(time.RFC3339),
+				Color:       0xCC0000,
+			},
+		},
+		Flags: discordgo.MessageFlagsEphemeral,
+	}); err != nil {
+		Logger.Debug("failed to send message", zap.Error(err))
+	}
+}
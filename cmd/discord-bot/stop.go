@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+
+	"chatbot-gpt/internal/locale"
+)
+
+const (
+	// stopButtonID is the custom ID prefix of the button stopping a reply, followed by the ID of its first message.
+	stopButtonID = "stop"
+
+	// stoppedMarker marks the answers stopped by the user in the history, so that the model knows they are incomplete.
+	stoppedMarker = "\n\n[truncated: stopped by the user]"
+)
+
+// errGenerationStopped is returned when the reply is stopped by the user.
+var errGenerationStopped = errors.New("generation stopped by the user")
+
+// generation is a reply being generated.
+type generation struct {
+	userID string
+	cancel context.CancelFunc
+}
+
+// generations are the replies being generated, by the ID of their first message.
+var generations = struct {
+	sync.Mutex
+	m map[string]generation
+}{m: make(map[string]generation)}
+
+// startGeneration registers the reply starting with the message for the user, returning the context of its requests
+// and the function to call once it is done.
+func startGeneration(messageID, userID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	generations.Lock()
+	generations.m[messageID] = generation{userID: userID, cancel: cancel}
+	generations.Unlock()
+
+	return ctx, func() {
+		generations.Lock()
+		delete(generations.m, messageID)
+		generations.Unlock()
+
+		cancel()
+	}
+}
+
+// stopComponents returns the stop button of the reply starting with the message.
+func stopComponents(messageID string, lang locale.Language) []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    Localizer.Fetch("stop_generation", lang),
+					Emoji:    discordgo.ComponentEmoji{Name: "⏹"},
+					Style:    discordgo.DangerButton,
+					CustomID: stopButtonID + ":" + messageID,
+				},
+			},
+		},
+	}
+}
+
+// handleStopButton handles the stop button, which cancels the requests of the reply.
+// Only the user who asked and the members who manage messages can stop a reply.
+func handleStopButton(s *discordgo.Session, i *discordgo.InteractionCreate, serverConfig ServerConfig) {
+	_, messageID, _ := strings.Cut(i.MessageComponentData().CustomID, ":")
+
+	Logger.Debug(
+		"received interaction",
+		zap.String("component", stopButtonID),
+		zap.String("user", i.Member.User.Username),
+		zap.String("message", messageID),
+	)
+
+	generations.Lock()
+	gen, isGenerating := generations.m[messageID]
+	generations.Unlock()
+
+	if isGenerating && gen.userID != i.Member.User.ID && i.Member.Permissions&discordgo.PermissionManageMessages == 0 {
+		respondInteractionError(s, i, serverConfig.Language, "stop_not_allowed")
+		return
+	}
+
+	if isGenerating {
+		gen.cancel()
+	}
+
+	if err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	}); err != nil {
+		Logger.Error("failed to respond to interaction", zap.Error(err))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/model"
+)
+
+const (
+	// defaultMaxToolIterations is the default number of rounds of tool calls in a response.
+	defaultMaxToolIterations = 5
+
+	// defaultToolTimeout is the default timeout of a tool call in milliseconds.
+	defaultToolTimeout = 10000
+
+	// minToolResultTokens is the minimum number of tokens kept of a tool result.
+	minToolResultTokens = 32
+)
+
+// predictToolTokens predicts the number of tokens of the tool definitions.
+func predictToolTokens(modelID string, tools []openai.Tool) int {
+	if len(tools) == 0 {
+		return 0
+	}
+
+	definitions, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+
+	return len(tokenizerForModel(modelID).Encode(string(definitions), nil, nil))
+}
+
+// adaptToolMessages removes the tool calls and the tool messages for models without tools.
+func adaptToolMessages(messages []openai.ChatCompletionMessage, capability model.Capability) []openai.ChatCompletionMessage {
+	if capability.SupportsTools {
+		return messages
+	}
+
+	var adapted []openai.ChatCompletionMessage
+	for _, message := range messages {
+		if message.Role == openai.ChatMessageRoleTool {
+			continue
+		}
+
+		if len(message.ToolCalls) > 0 {
+			if message.Content == "" {
+				continue
+			}
+
+			message.ToolCalls = nil
+		}
+
+		adapted = append(adapted, message)
+	}
+
+	return adapted
+}
+
+// trimToolMessages removes the tool messages at the beginning of the history,
+// whose tool calls have been left out by the token limit.
+func trimToolMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	for len(messages) > 0 && messages[0].Role == openai.ChatMessageRoleTool {
+		messages = messages[1:]
+	}
+
+	return messages
+}
+
+// mergeToolCallDeltas merges the tool call deltas of a stream into the tool calls.
+// Deltas without an index are complete tool calls of a replayed completion.
+func mergeToolCallDeltas(toolCalls []openai.ToolCall, deltas []openai.ToolCall) []openai.ToolCall {
+	for _, delta := range deltas {
+		if delta.Index == nil {
+			toolCalls = append(toolCalls, delta)
+			continue
+		}
+
+		index := slices.IndexFunc(toolCalls, func(call openai.ToolCall) bool {
+			return call.Index != nil && *call.Index == *delta.Index
+		})
+
+		if index < 0 {
+			toolCalls = append(toolCalls, delta)
+			continue
+		}
+
+		if delta.ID != "" {
+			toolCalls[index].ID = delta.ID
+		}
+
+		if delta.Type != "" {
+			toolCalls[index].Type = delta.Type
+		}
+
+		toolCalls[index].Function.Name += delta.Function.Name
+		toolCalls[index].Function.Arguments += delta.Function.Arguments
+	}
+
+	return toolCalls
+}
+
+// fitToolResults truncates the tool results to share the remaining tokens.
+// It reports whether the remaining tokens are used up.
+func fitToolResults(modelID string, results []openai.ChatCompletionMessage, remainingTokens int) bool {
+	if len(results) == 0 {
+		return false
+	}
+
+	overhead := predictTokens(modelID, results[:1], false) - len(tokenizerForModel(modelID).Encode(results[0].Content, nil, nil))
+	perResult := remainingTokens/len(results) - overhead
+
+	exhausted := perResult < minToolResultTokens
+	perResult = max(perResult, minToolResultTokens)
+
+	// The minimum tokens of a result leave room for the truncation marker, so truncating does not fail.
+	for i := range results {
+		results[i].Content, _ = truncateContent(modelID, results[i].Content, perResult)
+	}
+
+	return exhausted
+}
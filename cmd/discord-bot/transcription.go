@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/cost"
+)
+
+// maxAudioSize is the maximum size in bytes of an audio attachment accepted by the transcription API.
+const maxAudioSize = 25 << 20
+
+// audioExtensions are the extensions of the audio files accepted by the transcription API.
+var audioExtensions = map[string]bool{
+	".flac": true, ".m4a": true, ".mp3": true, ".mp4": true, ".mpeg": true,
+	".mpga": true, ".oga": true, ".ogg": true, ".wav": true, ".webm": true,
+}
+
+// transcript is the transcript of an audio attachment.
+type transcript struct {
+	text    string
+	seconds float64
+}
+
+// audioAttachments returns the voice messages and the audio attachments accepted by the transcription API.
+func audioAttachments(attachments []*discordgo.MessageAttachment) []*discordgo.MessageAttachment {
+	var audios []*discordgo.MessageAttachment
+
+	for _, attachment := range attachments {
+		mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+		isAudio := strings.HasPrefix(mediaType, "audio/") || audioExtensions[strings.ToLower(path.Ext(attachment.Filename))]
+
+		if isAudio && attachment.Size <= maxAudioSize && !strings.HasPrefix(mediaType, "video/") {
+			audios = append(audios, attachment)
+		}
+	}
+
+	return audios
+}
+
+// transcribe transcribes the audio attachment with the transcription model.
+func transcribe(ctx context.Context, attachment *discordgo.MessageAttachment) (transcript, error) {
+	chatProvider, err := providerForModel(TranscriptionConfig.ModelID)
+	if err != nil {
+		return transcript{}, err
+	}
+
+	data, _, err := downloadAttachment(ctx, attachment, maxAudioSize)
+	if err != nil {
+		return transcript{}, err
+	}
+
+	resp, err := chatProvider.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    TranscriptionConfig.ModelID,
+		FilePath: attachment.Filename,
+		Reader:   bytes.NewReader(data),
+		Language: TranscriptionConfig.Language,
+		Format:   openai.AudioResponseFormatVerboseJSON,
+	})
+	if err != nil {
+		return transcript{}, err
+	}
+
+	return transcript{text: strings.TrimSpace(resp.Text), seconds: resp.Duration}, nil
+}
+
+// transcribeAll transcribes the audio attachments in order.
+func transcribeAll(ctx context.Context, attachments []*discordgo.MessageAttachment) ([]transcript, error) {
+	var transcripts []transcript
+
+	for _, attachment := range attachments {
+		t, err := transcribe(ctx, attachment)
+		if err != nil {
+			return nil, err
+		}
+
+		transcripts = append(transcripts, t)
+	}
+
+	return transcripts, nil
+}
+
+// withTranscripts appends the transcripts to the content of the message.
+func withTranscripts(content string, transcripts []transcript) string {
+	texts := []string{content}
+	for _, t := range transcripts {
+		texts = append(texts, t.text)
+	}
+
+	return strings.TrimSpace(strings.Join(texts, "\n\n"))
+}
+
+// quoteTranscripts formats the transcripts as quoted blocks shown before the response.
+func quoteTranscripts(transcripts []transcript) string {
+	var quotes []string
+
+	for _, t := range transcripts {
+		quotes = append(quotes, "> 🎙️ "+strings.ReplaceAll(t.text, "\n", "\n> "))
+	}
+
+	return strings.Join(quotes, "\n") + "\n\n"
+}
+
+// getTranscriptionCostPriceString returns the cost price of transcribing the transcripts.
+func getTranscriptionCostPriceString(transcripts []transcript) string {
+	var seconds float64
+	for _, t := range transcripts {
+		seconds += t.seconds
+	}
+
+	numDollars := cost.NewCalculator(TranscriptionConfig.ModelID).GetAudioCost(seconds)
+
+	return fmt.Sprintf("🎙️ %s  ⏱️ %.1fs  →  %s", TranscriptionConfig.ModelID, seconds, formatPrices(numDollars))
+}
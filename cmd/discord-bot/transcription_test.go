@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+// transcriptionServer starts a server of the attachments, by file name under /attachments/,
+// and of an OpenAI-compatible transcription endpoint, which transcribes an audio file as its content.
+// It sets up the transcription with the server.
+func transcriptionServer(t *testing.T, audios map[string]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := strings.CutPrefix(r.URL.Path, "/attachments/"); ok {
+			audio, ok := audios[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			_, _ = io.WriteString(w, audio)
+			return
+		}
+
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.FormValue("model") != "whisper-1" || r.FormValue("language") != "en" ||
+			r.FormValue("response_format") != string(openai.AudioResponseFormatVerboseJSON) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"message":"invalid request","type":"invalid_request_error"}}`)
+			return
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		audio, _ := io.ReadAll(file)
+		if string(audio) == "noise" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":{"message":"Audio file might be corrupted","type":"invalid_request_error"}}`)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"text":" `+header.Filename+`: `+string(audio)+`\n","duration":2.5}`)
+	}))
+	t.Cleanup(server.Close)
+
+	initModelRegistry([]config.Model{{ID: "unserved", Provider: "missing"}})
+	initProviders(config.Provider{
+		Name:    "test",
+		Type:    "openai-compatible",
+		BaseURL: server.URL + "/v1",
+		Retry:   config.Retry{MaxAttempts: 1},
+	}, nil)
+
+	TranscriptionConfig = config.Transcription{ModelID: "whisper-1", Language: "en"}
+
+	return server
+}
+
+// audioAttachment returns an attachment of the server.
+func audioAttachment(server *httptest.Server, filename string) *discordgo.MessageAttachment {
+	return &discordgo.MessageAttachment{
+		Filename:    filename,
+		URL:         server.URL + "/attachments/" + filename,
+		ContentType: "audio/ogg",
+	}
+}
+
+func TestTranscribeAll(t *testing.T) {
+	server := transcriptionServer(t, map[string]string{"voice.ogg": "Hello", "memo.mp3": "World"})
+
+	transcripts, err := transcribeAll(context.Background(), []*discordgo.MessageAttachment{
+		audioAttachment(server, "voice.ogg"),
+		audioAttachment(server, "memo.mp3"),
+	})
+	if err != nil {
+		t.Fatalf("transcribeAll() error = %v", err)
+	}
+
+	want := []transcript{{text: "voice.ogg: Hello", seconds: 2.5}, {text: "memo.mp3: World", seconds: 2.5}}
+	if len(transcripts) != len(want) || transcripts[0] != want[0] || transcripts[1] != want[1] {
+		t.Errorf("transcribeAll() = %+v, want %+v", transcripts, want)
+	}
+
+	if got := withTranscripts("Listen:", transcripts); got != "Listen:\n\nvoice.ogg: Hello\n\nmemo.mp3: World" {
+		t.Errorf("withTranscripts() = %q", got)
+	}
+
+	if got := quoteTranscripts(transcripts); got != "> 🎙️ voice.ogg: Hello\n> 🎙️ memo.mp3: World\n\n" {
+		t.Errorf("quoteTranscripts() = %q", got)
+	}
+}
+
+func TestTranscribeErrors(t *testing.T) {
+	server := transcriptionServer(t, map[string]string{"voice.ogg": "Hello", "noise.ogg": "noise"})
+
+	tests := []struct {
+		name        string
+		attachments []*discordgo.MessageAttachment
+		modelID     string
+		check       func(err error) bool
+	}{
+		{
+			name:        "missing attachment",
+			attachments: []*discordgo.MessageAttachment{audioAttachment(server, "voice.ogg"), audioAttachment(server, "gone.ogg")},
+			modelID:     "whisper-1",
+			check: func(err error) bool {
+				return err != nil && strings.Contains(err.Error(), "failed to download gone.ogg")
+			},
+		},
+		{
+			name:        "rejected audio",
+			attachments: []*discordgo.MessageAttachment{audioAttachment(server, "noise.ogg")},
+			modelID:     "whisper-1",
+			check: func(err error) bool {
+				var apiErr *openai.APIError
+				return errors.As(err, &apiErr) && apiErr.HTTPStatusCode == http.StatusBadRequest &&
+					apiErr.Message == "Audio file might be corrupted"
+			},
+		},
+		{
+			name:        "unknown provider",
+			attachments: []*discordgo.MessageAttachment{audioAttachment(server, "voice.ogg")},
+			modelID:     "unserved",
+			check: func(err error) bool {
+				return errors.Is(err, errUnknownProvider)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			TranscriptionConfig.ModelID = tt.modelID
+
+			transcripts, err := transcribeAll(context.Background(), tt.attachments)
+			if !tt.check(err) || transcripts != nil {
+				t.Errorf("transcribeAll() = %+v, %v", transcripts, err)
+			}
+		})
+	}
+}
+
+func TestAudioAttachments(t *testing.T) {
+	attachments := []*discordgo.MessageAttachment{
+		{Filename: "voice-message.ogg", ContentType: "audio/ogg", Size: 1000},
+		{Filename: "song.MP3", Size: 1000},
+		{Filename: "clip.mp4", ContentType: "video/mp4", Size: 1000},
+		{Filename: "huge.wav", ContentType: "audio/wav", Size: maxAudioSize + 1},
+		{Filename: "notes.txt", ContentType: "text/plain", Size: 10},
+	}
+
+	var names []string
+	for _, attachment := range audioAttachments(attachments) {
+		names = append(names, attachment.Filename)
+	}
+
+	if got := strings.Join(names, ","); got != "voice-message.ogg,song.MP3" {
+		t.Errorf("audioAttachments() = %s, want voice-message.ogg,song.MP3", got)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/model"
+)
+
+const (
+	// maxImageSize is the maximum size in bytes of an image attachment sent to the model.
+	maxImageSize = 20 << 20
+
+	// lowDetailImageTokens is the number of tokens of a low detail image, and the base of a high detail image.
+	lowDetailImageTokens = 85
+
+	// imageTileTokens is the number of tokens of a 512px tile of a high detail image.
+	imageTileTokens = 170
+
+	// maxImageTiles is the number of tiles assumed when the size of an image is unknown.
+	maxImageTiles = 8
+
+	// imageHistoryKeep keeps the images in the stored history.
+	imageHistoryKeep = "keep"
+
+	// imageHistoryDrop replaces the images with placeholders in the stored history.
+	imageHistoryDrop = "drop"
+
+	// imagePlaceholder replaces the images left out of a message.
+	imagePlaceholder = "[image]"
+)
+
+// imageContentTypes are the content types of the images accepted by the models.
+var imageContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageAttachments returns the image attachments of the message accepted by the models.
+func imageAttachments(attachments []*discordgo.MessageAttachment) []*discordgo.MessageAttachment {
+	var images []*discordgo.MessageAttachment
+
+	for _, attachment := range attachments {
+		mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+		if imageContentTypes[mediaType] && attachment.Size <= maxImageSize {
+			images = append(images, attachment)
+		}
+	}
+
+	return images
+}
+
+// downloadImage downloads the image attachment as a data URL,
+// since the URLs of Discord attachments expire while the image is kept in the history.
+func downloadImage(ctx context.Context, attachment *discordgo.MessageAttachment) (string, error) {
+	data, _, err := downloadAttachment(ctx, attachment, maxImageSize)
+	if err != nil {
+		return "", err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(attachment.ContentType)
+
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// imageParts downloads the image attachments as the image parts of a message.
+func imageParts(
+	ctx context.Context, attachments []*discordgo.MessageAttachment, detail string,
+) ([]openai.ChatMessagePart, error) {
+	var parts []openai.ChatMessagePart
+
+	for _, attachment := range attachments {
+		dataURL, err := downloadImage(ctx, attachment)
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL:    dataURL,
+				Detail: openai.ImageURLDetail(detail),
+			},
+		})
+	}
+
+	return parts, nil
+}
+
+// imageSize returns the size of the image of the data URL, which is unknown for the formats without a decoder.
+func imageSize(dataURL string) (int, int, bool) {
+	_, encoded, found := strings.Cut(dataURL, ";base64,")
+	if !found {
+		return 0, 0, false
+	}
+
+	config, _, err := image.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(encoded)))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return config.Width, config.Height, true
+}
+
+// imageTokens predicts the number of tokens of the image of the data URL.
+// High detail images are scaled to fit 2048px, then their short side to 768px, and counted by 512px tiles.
+func imageTokens(imageURL *openai.ChatMessageImageURL) int {
+	if imageURL.Detail == openai.ImageURLDetailLow {
+		return lowDetailImageTokens
+	}
+
+	width, height, ok := imageSize(imageURL.URL)
+	if !ok {
+		return lowDetailImageTokens + imageTileTokens*maxImageTiles
+	}
+
+	w, h := float64(width), float64(height)
+	if longSide := max(w, h); longSide > 2048 {
+		w, h = w*2048/longSide, h*2048/longSide
+	}
+
+	if shortSide := min(w, h); shortSide > 768 {
+		w, h = w*768/shortSide, h*768/shortSide
+	}
+
+	tiles := ((int(w) + 511) / 512) * ((int(h) + 511) / 512)
+
+	return lowDetailImageTokens + imageTileTokens*tiles
+}
+
+// withImages returns the message with the image parts after its text.
+func withImages(message openai.ChatCompletionMessage, images []openai.ChatMessagePart) openai.ChatCompletionMessage {
+	if len(images) == 0 {
+		return message
+	}
+
+	message.MultiContent = append([]openai.ChatMessagePart{
+		{Type: openai.ChatMessagePartTypeText, Text: message.Content},
+	}, images...)
+	message.Content = ""
+
+	return message
+}
+
+// withoutImages returns the message with its images replaced by placeholders.
+func withoutImages(message openai.ChatCompletionMessage) openai.ChatCompletionMessage {
+	if len(message.MultiContent) == 0 {
+		return message
+	}
+
+	var texts []string
+	for _, part := range message.MultiContent {
+		switch part.Type {
+		case openai.ChatMessagePartTypeText:
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		case openai.ChatMessagePartTypeImageURL:
+			texts = append(texts, imagePlaceholder)
+		}
+	}
+
+	message.Content = strings.Join(texts, "\n")
+	message.MultiContent = nil
+
+	return message
+}
+
+// adaptImages replaces the images with placeholders for models without vision.
+func adaptImages(messages []openai.ChatCompletionMessage, capability model.Capability) []openai.ChatCompletionMessage {
+	if capability.SupportsVision {
+		return messages
+	}
+
+	adapted := make([]openai.ChatCompletionMessage, len(messages))
+	for i, message := range messages {
+		adapted[i] = withoutImages(message)
+	}
+
+	return adapted
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"go.uber.org/zap"
+)
+
+// webhookName is the name of the webhooks created by the bot to speak as personas.
+const webhookName = "chatbot-gpt"
+
+// responseWriter writes the response messages to a channel.
+type responseWriter interface {
+	// Send sends a new message.
+	Send(content string) (*discordgo.Message, error)
+
+	// Edit edits the content of a message sent by the writer.
+	Edit(messageID, content string) error
+
+	// EditComplex edits the content of a message sent by the writer, replacing its components and adding the files.
+	// Nil components remove the components of the message.
+	EditComplex(messageID, content string, components []discordgo.MessageComponent, files []*discordgo.File) error
+
+	// Delete deletes a message sent by the writer.
+	Delete(messageID string) error
+}
+
+// botWriter writes the messages as the bot, replying to the message of the user.
+type botWriter struct {
+	s         *discordgo.Session
+	guildID   string
+	channelID string
+	messageID string
+}
+
+// Send sends a new message.
+func (w *botWriter) Send(content string) (*discordgo.Message, error) {
+	return w.s.ChannelMessageSendComplex(w.channelID, &discordgo.MessageSend{
+		Content: content,
+		Reference: &discordgo.MessageReference{
+			MessageID: w.messageID,
+			GuildID:   w.guildID,
+		},
+	})
+}
+
+// Edit edits the content of a message.
+func (w *botWriter) Edit(messageID, content string) error {
+	_, err := w.s.ChannelMessageEdit(w.channelID, messageID, content)
+	return err
+}
+
+// EditComplex edits the content and the components of a message, adding the files.
+func (w *botWriter) EditComplex(
+	messageID, content string, components []discordgo.MessageComponent, files []*discordgo.File,
+) error {
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+
+	_, err := w.s.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		ID:         messageID,
+		Channel:    w.channelID,
+		Content:    &content,
+		Components: components,
+		Files:      files,
+	})
+	return err
+}
+
+// Delete deletes a message.
+func (w *botWriter) Delete(messageID string) error {
+	return w.s.ChannelMessageDelete(w.channelID, messageID)
+}
+
+// webhookWriter writes the messages through a webhook, with the display name and the avatar of a persona.
+type webhookWriter struct {
+	s         *discordgo.Session
+	webhook   *discordgo.Webhook
+	username  string
+	avatarURL string
+}
+
+// Send sends a new message.
+func (w *webhookWriter) Send(content string) (*discordgo.Message, error) {
+	return w.s.WebhookExecute(w.webhook.ID, w.webhook.Token, true, &discordgo.WebhookParams{
+		Content:   content,
+		Username:  w.username,
+		AvatarURL: w.avatarURL,
+	})
+}
+
+// Edit edits the content of a message.
+func (w *webhookWriter) Edit(messageID, content string) error {
+	_, err := w.s.WebhookMessageEdit(w.webhook.ID, w.webhook.Token, messageID, &discordgo.WebhookEdit{
+		Content: &content,
+	})
+	return err
+}
+
+// EditComplex edits the content and the components of a message, adding the files.
+func (w *webhookWriter) EditComplex(
+	messageID, content string, components []discordgo.MessageComponent, files []*discordgo.File,
+) error {
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+
+	_, err := w.s.WebhookMessageEdit(w.webhook.ID, w.webhook.Token, messageID, &discordgo.WebhookEdit{
+		Content:    &content,
+		Components: &components,
+		Files:      files,
+	})
+	return err
+}
+
+// Delete deletes a message.
+func (w *webhookWriter) Delete(messageID string) error {
+	return w.s.WebhookMessageDelete(w.webhook.ID, w.webhook.Token, messageID)
+}
+
+// channelWebhooks caches the webhooks of the bot by channel.
+var channelWebhooks = struct {
+	sync.Mutex
+	m map[string]*discordgo.Webhook
+}{m: make(map[string]*discordgo.Webhook)}
+
+// webhookForChannel returns the webhook of the bot in the channel, creating it if needed.
+func webhookForChannel(s *discordgo.Session, channelID string) (*discordgo.Webhook, error) {
+	channelWebhooks.Lock()
+	defer channelWebhooks.Unlock()
+
+	if webhook, ok := channelWebhooks.m[channelID]; ok {
+		return webhook, nil
+	}
+
+	webhooks, err := s.ChannelWebhooks(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Name == webhookName && webhook.Token != "" {
+			channelWebhooks.m[channelID] = webhook
+			return webhook, nil
+		}
+	}
+
+	webhook, err := s.WebhookCreate(channelID, webhookName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	channelWebhooks.m[channelID] = webhook
+
+	return webhook, nil
+}
+
+// isBotWebhook reports whether the webhook is the one used by the bot in the channel.
+func isBotWebhook(channelID, webhookID string) bool {
+	channelWebhooks.Lock()
+	defer channelWebhooks.Unlock()
+
+	webhook, ok := channelWebhooks.m[channelID]
+
+	return ok && webhook.ID == webhookID
+}
+
+// newResponseWriter returns the writer of the response to the message.
+// Personas with a display name or an avatar speak through a webhook, falling back to the bot if it is unavailable.
+func newResponseWriter(s *discordgo.Session, data *discordgo.MessageCreate, persona Persona) responseWriter {
+	bot := &botWriter{
+		s:         s,
+		guildID:   data.GuildID,
+		channelID: data.ChannelID,
+		messageID: data.ID,
+	}
+
+	if persona.DisplayName == "" && persona.AvatarURL == "" {
+		return bot
+	}
+
+	webhook, err := webhookForChannel(s, data.ChannelID)
+	if err != nil {
+		Logger.Debug("failed to get the webhook of the channel", zap.String("channelID", data.ChannelID), zap.Error(err))
+		return bot
+	}
+
+	return &webhookWriter{
+		s:         s,
+		webhook:   webhook,
+		username:  persona.DisplayName,
+		avatarURL: persona.AvatarURL,
+	}
+}
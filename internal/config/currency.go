@@ -0,0 +1,9 @@
+package config
+
+// Currency is the configuration for a currency in which costs are shown and which the bot can convert.
+// The rate is in units of the currency per US dollar.
+type Currency struct {
+	Code   string  `json:"code"   yaml:"code"`
+	Rate   float64 `json:"rate"   yaml:"rate"`
+	Symbol string  `json:"symbol" yaml:"symbol" default:""`
+}
@@ -2,23 +2,62 @@ package config
 
 // Discord is the configuration for the Discord bot.
 type Discord struct {
-	Production bool                         `json:"production" yaml:"production" default:"false"`
-	Token      string                       `json:"token"      yaml:"token"      default:""`
-	Locales    map[string]map[string]string `json:"locales"    yaml:"locales"    default:"{}"`
-	Servers    []struct {
+	Production    bool                         `json:"production"    yaml:"production"    default:"false"`
+	Token         string                       `json:"token"         yaml:"token"         default:""`
+	Locales       map[string]map[string]string `json:"locales"       yaml:"locales"       default:"{}"`
+	Scheduler     Scheduler                    `json:"scheduler"     yaml:"scheduler"`
+	Personas      []Persona                    `json:"personas"      yaml:"personas"      default:"[]"`
+	Currencies    []Currency                   `json:"currencies"    yaml:"currencies"    default:"[]"`
+	Search        Search                       `json:"search"        yaml:"search"`
+	Transcription Transcription                `json:"transcription" yaml:"transcription"`
+	Speech        Speech                       `json:"speech"        yaml:"speech"`
+	Images        Image                        `json:"images"        yaml:"images"`
+	Moderation    Moderation                   `json:"moderation"    yaml:"moderation"`
+	Servers       []struct {
 		ID           string `json:"id" yaml:"id"`
 		Language     string `json:"language" yaml:"language" default:"enUS"`
+		Persona      string `json:"persona" yaml:"persona" default:""`
+		Timezone     string `json:"timezone" yaml:"timezone" default:"UTC"`
 		ChatChannels []struct {
-			ID                   string `json:"id" yaml:"id"`
-			MessageEditInterval  int    `json:"message_edit_interval" yaml:"message_edit_interval" default:"5000"`
-			PromptTokenLimit     int    `json:"prompt_token_limit" yaml:"prompt_token_limit" default:"500"`
-			CompletionTokenLimit int    `json:"completion_token_limit" yaml:"completion_token_limit" default:"500"`
+			ID                   string   `json:"id" yaml:"id"`
+			ModelID              string   `json:"model_id" yaml:"model_id" default:""`
+			AllowedModels        []string `json:"allowed_models" yaml:"allowed_models" default:"[]"`
+			FallbackModels       []string `json:"fallback_models" yaml:"fallback_models" default:"[]"`
+			MessageEditInterval  int      `json:"message_edit_interval" yaml:"message_edit_interval" default:"5000"`
+			PromptTokenLimit     int      `json:"prompt_token_limit" yaml:"prompt_token_limit" default:"500"`
+			CompletionTokenLimit int      `json:"completion_token_limit" yaml:"completion_token_limit" default:"500"`
+			OverLimitStrategy    string   `json:"over_limit_strategy" yaml:"over_limit_strategy" default:"reject"`
+			Persona              string   `json:"persona" yaml:"persona" default:""`
+			AllowedPersonas      []string `json:"allowed_personas" yaml:"allowed_personas" default:"[]"`
+			Sampling             Sampling `json:"sampling" yaml:"sampling"`
+			Tools                []string `json:"tools" yaml:"tools" default:"[]"`
+			MaxToolIterations    int      `json:"max_tool_iterations" yaml:"max_tool_iterations" default:"5"`
+			ToolTimeout          int      `json:"tool_timeout" yaml:"tool_timeout" default:"10000"`
+			MaxContinuations     int      `json:"max_continuations" yaml:"max_continuations" default:"0"`
+			ImageDetail          string   `json:"image_detail" yaml:"image_detail" default:"auto"`
+			ImageHistory         string   `json:"image_history" yaml:"image_history" default:"drop"`
+			MaxAttachmentSize    int      `json:"max_attachment_size" yaml:"max_attachment_size" default:"102400"`
+			Speech               bool     `json:"speech" yaml:"speech" default:"false"`
+			Voice                string   `json:"voice" yaml:"voice" default:""`
 		} `json:"chat_channels" yaml:"chat_channels" default:"[]"`
-		Commands struct {
-			ClearContext struct {
-				Enable  bool     `json:"enable" yaml:"enable" default:"false"`
-				Aliases []string `json:"aliases" yaml:"aliases" default:"[]"`
-			} `json:"clear_context" yaml:"clear_context"`
-		} `json:"commands" yaml:"commands"`
+		Commands   Commands         `json:"commands" yaml:"commands"`
+		Moderation ServerModeration `json:"moderation" yaml:"moderation"`
 	} `json:"servers"    yaml:"servers"    default:"[]"`
 }
+
+// Commands is the configuration for the slash commands of a server.
+type Commands struct {
+	ClearContext Command `json:"clear_context" yaml:"clear_context"`
+	Model        Command `json:"model" yaml:"model"`
+	Persona      Command `json:"persona" yaml:"persona"`
+	Params       Command `json:"params" yaml:"params"`
+	Pages        Command `json:"pages" yaml:"pages"`
+	Speech       Command `json:"speech" yaml:"speech"`
+	Imagine      Command `json:"imagine" yaml:"imagine"`
+}
+
+// Command is the configuration for a slash command.
+type Command struct {
+	Enable  bool     `json:"enable" yaml:"enable" default:"false"`
+	Aliases []string `json:"aliases" yaml:"aliases" default:"[]"`
+}
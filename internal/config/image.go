@@ -0,0 +1,10 @@
+package config
+
+// Image is the configuration for generating images with the imagine command.
+// The quotas are in US dollars per day, and 0 means unlimited.
+type Image struct {
+	ModelID          string  `json:"model_id"           yaml:"model_id"           default:"dall-e-3"`
+	MaxCount         int     `json:"max_count"          yaml:"max_count"          default:"4"`
+	DailyUserQuota   float64 `json:"daily_user_quota"   yaml:"daily_user_quota"   default:"0"`
+	DailyServerQuota float64 `json:"daily_server_quota" yaml:"daily_server_quota" default:"0"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// Model is the configuration for the capabilities of a model.
+// Fields left blank keep the built-in defaults of the model.
+type Model struct {
+	ID                 string `json:"id"                   yaml:"id"`
+	Provider           string `json:"provider"             yaml:"provider"`
+	ContextWindow      int    `json:"context_window"       yaml:"context_window"`
+	MaxOutputTokens    int    `json:"max_output_tokens"    yaml:"max_output_tokens"`
+	SupportsSystemRole *bool  `json:"supports_system_role" yaml:"supports_system_role"`
+	SupportsStreaming  *bool  `json:"supports_streaming"   yaml:"supports_streaming"`
+	SupportsVision     *bool  `json:"supports_vision"      yaml:"supports_vision"`
+	SupportsTools      *bool  `json:"supports_tools"       yaml:"supports_tools"`
+	SupportsJSONMode   *bool  `json:"supports_json_mode"   yaml:"supports_json_mode"`
+}
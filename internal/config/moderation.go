@@ -0,0 +1,30 @@
+package config
+
+// Moderation is the configuration for moderating the messages of the servers which enable it.
+// The moderation endpoint is not used when the model is empty, leaving only the rules.
+type Moderation struct {
+	ModelID string           `json:"model_id" yaml:"model_id" default:"text-moderation-latest"`
+	Rules   []ModerationRule `json:"rules"    yaml:"rules"    default:"[]"`
+}
+
+// ModerationRule scores the messages matching the regular expression 1 in the category.
+type ModerationRule struct {
+	Category string `json:"category" yaml:"category"`
+	Pattern  string `json:"pattern"  yaml:"pattern"`
+}
+
+// ServerModeration is the configuration for moderating the messages of a server.
+type ServerModeration struct {
+	Enable      bool                  `json:"enable"       yaml:"enable"       default:"false"`
+	CheckOutput bool                  `json:"check_output" yaml:"check_output" default:"false"`
+	LogChannel  string                `json:"log_channel"  yaml:"log_channel"  default:""`
+	Thresholds  []ModerationThreshold `json:"thresholds"   yaml:"thresholds"   default:"[]"`
+}
+
+// ModerationThreshold is the scores from which the messages of a category are warned about or blocked.
+// The category * applies to the categories not listed, and a threshold of 0 never applies.
+type ModerationThreshold struct {
+	Category string  `json:"category" yaml:"category"`
+	Warn     float64 `json:"warn"     yaml:"warn"`
+	Block    float64 `json:"block"    yaml:"block"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// Persona is the configuration for a persona of the bot.
+type Persona struct {
+	Name         string   `json:"name"          yaml:"name"`
+	DisplayName  string   `json:"display_name"  yaml:"display_name"  default:""`
+	AvatarURL    string   `json:"avatar_url"    yaml:"avatar_url"    default:""`
+	SystemPrompt string   `json:"system_prompt" yaml:"system_prompt" default:""`
+	Sampling     Sampling `json:"sampling"      yaml:"sampling"`
+}
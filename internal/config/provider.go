@@ -0,0 +1,22 @@
+package config
+
+// Provider is the configuration for a chat model provider.
+type Provider struct {
+	Name        string            `json:"name"        yaml:"name"        default:"openai"`
+	Type        string            `json:"type"        yaml:"type"        default:"openai"`
+	Token       string            `json:"token"       yaml:"token"       default:""`
+	BaseURL     string            `json:"base_url"    yaml:"base_url"    default:""`
+	OrgID       string            `json:"org_id"      yaml:"org_id"      default:""`
+	Headers     map[string]string `json:"headers"     yaml:"headers"     default:"{}"`
+	APIVersion  string            `json:"api_version" yaml:"api_version" default:""`
+	Deployments map[string]string `json:"deployments" yaml:"deployments" default:"{}"`
+	Retry       Retry             `json:"retry"       yaml:"retry"`
+}
+
+// Retry is the configuration for retrying failed provider requests.
+// Zero values fall back to the defaults of the provider package.
+type Retry struct {
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts" default:"3"`
+	BaseDelay   int `json:"base_delay"   yaml:"base_delay"   default:"500"`
+	MaxDelay    int `json:"max_delay"    yaml:"max_delay"    default:"30000"`
+}
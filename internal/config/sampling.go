@@ -0,0 +1,46 @@
+package config
+
+// Sampling is the configuration for the sampling parameters of chat completions.
+// Parameters left empty use the defaults of the provider.
+type Sampling struct {
+	Temperature      *float32       `json:"temperature"       yaml:"temperature"`
+	TopP             *float32       `json:"top_p"             yaml:"top_p"`
+	PresencePenalty  *float32       `json:"presence_penalty"  yaml:"presence_penalty"`
+	FrequencyPenalty *float32       `json:"frequency_penalty" yaml:"frequency_penalty"`
+	Stop             []string       `json:"stop"              yaml:"stop"`
+	Seed             *int           `json:"seed"              yaml:"seed"`
+	LogitBias        map[string]int `json:"logit_bias"        yaml:"logit_bias"`
+}
+
+// Merge returns the sampling parameters with the parameters set in other taking precedence.
+func (s Sampling) Merge(other Sampling) Sampling {
+	if other.Temperature != nil {
+		s.Temperature = other.Temperature
+	}
+
+	if other.TopP != nil {
+		s.TopP = other.TopP
+	}
+
+	if other.PresencePenalty != nil {
+		s.PresencePenalty = other.PresencePenalty
+	}
+
+	if other.FrequencyPenalty != nil {
+		s.FrequencyPenalty = other.FrequencyPenalty
+	}
+
+	if len(other.Stop) > 0 {
+		s.Stop = other.Stop
+	}
+
+	if other.Seed != nil {
+		s.Seed = other.Seed
+	}
+
+	if len(other.LogitBias) > 0 {
+		s.LogitBias = other.LogitBias
+	}
+
+	return s
+}
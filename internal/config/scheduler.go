@@ -0,0 +1,9 @@
+package config
+
+// Scheduler is the configuration for the limits of concurrent chat requests.
+// A limit of zero means unlimited.
+type Scheduler struct {
+	MaxConcurrent int `json:"max_concurrent" yaml:"max_concurrent" default:"4"`
+	MaxPerServer  int `json:"max_per_server" yaml:"max_per_server" default:"2"`
+	MaxPerUser    int `json:"max_per_user"   yaml:"max_per_user"   default:"1"`
+}
@@ -0,0 +1,15 @@
+package config
+
+// Search is the configuration for the web search tool, backed by a SearXNG-compatible JSON API.
+// The tool is available only when the endpoint is set.
+type Search struct {
+	Endpoint         string `json:"endpoint"           yaml:"endpoint"           default:""`
+	MaxResults       int    `json:"max_results"        yaml:"max_results"        default:"5"`
+	FetchResults     int    `json:"fetch_results"      yaml:"fetch_results"      default:"2"`
+	MaxContentLength int    `json:"max_content_length" yaml:"max_content_length" default:"3000"`
+	UserAgent        string `json:"user_agent"         yaml:"user_agent"         default:"chatbot-gpt"`
+
+	// AllowPrivateAddresses allows fetching the results on loopback and private networks,
+	// such as a local stand-in server.
+	AllowPrivateAddresses bool `json:"allow_private_addresses" yaml:"allow_private_addresses" default:"false"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// Speech is the configuration for reading the replies aloud as audio attachments.
+// Speech is disabled when the model is empty.
+type Speech struct {
+	ModelID string  `json:"model_id" yaml:"model_id" default:"tts-1"`
+	Voice   string  `json:"voice"    yaml:"voice"    default:"alloy"`
+	Format  string  `json:"format"   yaml:"format"   default:"mp3"`
+	Speed   float64 `json:"speed"    yaml:"speed"    default:"1"`
+}
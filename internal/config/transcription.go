@@ -0,0 +1,8 @@
+package config
+
+// Transcription is the configuration for transcribing voice messages and audio attachments.
+// Audio is ignored when the model is empty.
+type Transcription struct {
+	ModelID  string `json:"model_id" yaml:"model_id" default:"whisper-1"`
+	Language string `json:"language" yaml:"language" default:""`
+}
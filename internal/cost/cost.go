@@ -1,22 +1,20 @@
 package cost
 
-import openai "github.com/sashabaranov/go-openai"
-
 // Calculator is a calculator for calculating the cost of a completion.
 type Calculator struct {
-	*openai.Model
+	modelID string
 }
 
-// NewCalculator creates a new calculator.
-func NewCalculator(model *openai.Model) *Calculator {
+// NewCalculator creates a new calculator for the given model.
+func NewCalculator(modelID string) *Calculator {
 	return &Calculator{
-		Model: model,
+		modelID: modelID,
 	}
 }
 
 // GetPromptCost returns the cost of a prompt.
 func (c *Calculator) GetPromptCost(numTokens int) float64 {
-	modelID := shortModelID(c.ID)
+	modelID := shortModelID(c.modelID)
 	if costTable, ok := modelCosts[modelID]; ok {
 		return float64(numTokens) * costTable.promptCost
 	}
@@ -26,10 +24,40 @@ func (c *Calculator) GetPromptCost(numTokens int) float64 {
 
 // GetSampledCost returns the cost of a sampled completion.
 func (c *Calculator) GetSampledCost(numTokens int) float64 {
-	modelID := shortModelID(c.ID)
+	modelID := shortModelID(c.modelID)
 	if costTable, ok := modelCosts[modelID]; ok {
 		return float64(numTokens) * costTable.sampledCost
 	}
 
 	return 0
 }
+
+// GetAudioCost returns the cost of transcribing the given seconds of audio.
+func (c *Calculator) GetAudioCost(seconds float64) float64 {
+	return seconds * audioCosts[c.modelID]
+}
+
+// GetSpeechCost returns the cost of synthesizing the speech of the given number of characters.
+func (c *Calculator) GetSpeechCost(numChars int) float64 {
+	return float64(numChars) / 1000 * speechCosts[c.modelID]
+}
+
+// HasImageCost reports whether the cost of the images of the quality and the size is known.
+func (c *Calculator) HasImageCost(quality, size string) bool {
+	if quality == "" {
+		quality = "standard"
+	}
+
+	_, ok := imageCosts[c.modelID][quality+" "+size]
+
+	return ok
+}
+
+// GetImageCost returns the cost of generating the given number of images of the quality and the size.
+func (c *Calculator) GetImageCost(quality, size string, numImages int) float64 {
+	if quality == "" {
+		quality = "standard"
+	}
+
+	return float64(numImages) * imageCosts[c.modelID][quality+" "+size]
+}
@@ -0,0 +1,87 @@
+package cost
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShortModelID(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4":                       "gpt-4",
+		"gpt-4-0613":                  "gpt-4",
+		"gpt-4-32k-0314":              "gpt-4-32k",
+		"gpt-4-turbo":                 "gpt-4-turbo",
+		"gpt-4-turbo-2024-04-09":      "gpt-4-turbo",
+		"gpt-4-1106-preview":          "gpt-4-1106-preview",
+		"gpt-4-vision-preview":        "gpt-4-vision-preview",
+		"gpt-4o-2024-05-13":           "gpt-4o",
+		"gpt-4o-mini-2024-07-18":      "gpt-4o-mini",
+		"gpt-3.5-turbo-0301":          "gpt-3.5-turbo",
+		"claude-3-opus-20240229":      "claude-3-opus",
+		"gemini-pro":                  "gemini-1.0-pro",
+		"gpt-4-unknown":               "gpt-4-unknown",
+		"ft:gpt-4-0613:org::id":       "ft:gpt-4-0613:org::id",
+		"custom-model-20240101":       "custom-model-20240101",
+		"gpt-3.5-turbo-instruct-0914": "gpt-3.5-turbo-instruct-0914",
+	}
+
+	for modelID, want := range tests {
+		if got := shortModelID(modelID); got != want {
+			t.Errorf("shortModelID(%q) = %q, want %q", modelID, got, want)
+		}
+	}
+}
+
+func TestCalculator(t *testing.T) {
+	tests := []struct {
+		modelID              string
+		promptCost, sampled  float64
+		promptTokens, tokens int
+	}{
+		{modelID: "gpt-4-0613", promptCost: 0.03, sampled: 0.06, promptTokens: 1000, tokens: 1000},
+		{modelID: "gpt-4-0125-preview", promptCost: 0.01, sampled: 0.03, promptTokens: 1000, tokens: 1000},
+		{modelID: "gpt-4o", promptCost: 0.005, sampled: 0.015, promptTokens: 1000, tokens: 1000},
+		{modelID: "unknown", promptCost: 0, sampled: 0, promptTokens: 1000, tokens: 1000},
+	}
+
+	for _, tt := range tests {
+		calculator := NewCalculator(tt.modelID)
+
+		if got := calculator.GetPromptCost(tt.promptTokens); math.Abs(got-tt.promptCost) > 1e-9 {
+			t.Errorf("GetPromptCost() of %s = %v, want %v", tt.modelID, got, tt.promptCost)
+		}
+
+		if got := calculator.GetSampledCost(tt.tokens); math.Abs(got-tt.sampled) > 1e-9 {
+			t.Errorf("GetSampledCost() of %s = %v, want %v", tt.modelID, got, tt.sampled)
+		}
+	}
+}
+
+func TestImageCost(t *testing.T) {
+	if got := NewCalculator("dall-e-3").GetImageCost("hd", "1792x1024", 2); math.Abs(got-0.24) > 1e-9 {
+		t.Errorf("GetImageCost() = %v, want 0.24", got)
+	}
+
+	if got := NewCalculator("dall-e-2").GetImageCost("", "512x512", 1); math.Abs(got-0.018) > 1e-9 {
+		t.Errorf("GetImageCost() = %v, want 0.018", got)
+	}
+}
+
+func TestHasImageCost(t *testing.T) {
+	tests := []struct {
+		modelID, quality, size string
+		want                   bool
+	}{
+		{"dall-e-3", "hd", "1024x1792", true},
+		{"dall-e-3", "", "1024x1024", true},
+		{"dall-e-3", "hd", "512x512", false},
+		{"dall-e-2", "", "256x256", true},
+		{"stable-diffusion", "", "1024x1024", false},
+	}
+
+	for _, tt := range tests {
+		if got := NewCalculator(tt.modelID).HasImageCost(tt.quality, tt.size); got != tt.want {
+			t.Errorf("HasImageCost(%q, %q) of %s = %v, want %v", tt.quality, tt.size, tt.modelID, got, tt.want)
+		}
+	}
+}
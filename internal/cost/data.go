@@ -1,5 +1,7 @@
 package cost
 
+import "regexp"
+
 type modelCostData struct {
 	promptCost  float64
 	sampledCost float64
@@ -18,8 +20,93 @@ var modelCosts = map[string]modelCostData{
 		promptCost:  0.06 / 1000,
 		sampledCost: 0.12 / 1000,
 	},
+	"gpt-4-turbo": {
+		promptCost:  0.01 / 1000,
+		sampledCost: 0.03 / 1000,
+	},
+	"gpt-4-turbo-preview": {
+		promptCost:  0.01 / 1000,
+		sampledCost: 0.03 / 1000,
+	},
+	"gpt-4-1106-preview": {
+		promptCost:  0.01 / 1000,
+		sampledCost: 0.03 / 1000,
+	},
+	"gpt-4-0125-preview": {
+		promptCost:  0.01 / 1000,
+		sampledCost: 0.03 / 1000,
+	},
+	"gpt-4-vision-preview": {
+		promptCost:  0.01 / 1000,
+		sampledCost: 0.03 / 1000,
+	},
+	"gpt-4o": {
+		promptCost:  0.005 / 1000,
+		sampledCost: 0.015 / 1000,
+	},
+	"gpt-4o-mini": {
+		promptCost:  0.00015 / 1000,
+		sampledCost: 0.0006 / 1000,
+	},
+	"claude-3-opus": {
+		promptCost:  0.015 / 1000,
+		sampledCost: 0.075 / 1000,
+	},
+	"claude-3-sonnet": {
+		promptCost:  0.003 / 1000,
+		sampledCost: 0.015 / 1000,
+	},
+	"claude-3-haiku": {
+		promptCost:  0.00025 / 1000,
+		sampledCost: 0.00125 / 1000,
+	},
+	"gemini-1.0-pro": {
+		promptCost:  0.0005 / 1000,
+		sampledCost: 0.0015 / 1000,
+	},
+	"gemini-1.5-pro": {
+		promptCost:  0.0035 / 1000,
+		sampledCost: 0.0105 / 1000,
+	},
+	"gemini-1.5-flash": {
+		promptCost:  0.00035 / 1000,
+		sampledCost: 0.00105 / 1000,
+	},
+}
+
+// audioCosts are the costs of transcription models per second of audio.
+var audioCosts = map[string]float64{
+	"whisper-1": 0.006 / 60,
+}
+
+// imageCosts are the costs of image models per image, by quality and size.
+var imageCosts = map[string]map[string]float64{
+	"dall-e-2": {
+		"standard 256x256":   0.016,
+		"standard 512x512":   0.018,
+		"standard 1024x1024": 0.020,
+	},
+	"dall-e-3": {
+		"standard 1024x1024": 0.040,
+		"standard 1024x1792": 0.080,
+		"standard 1792x1024": 0.080,
+		"hd 1024x1024":       0.080,
+		"hd 1024x1792":       0.120,
+		"hd 1792x1024":       0.120,
+	},
+}
+
+// speechCosts are the costs of speech models per 1K characters.
+var speechCosts = map[string]float64{
+	"tts-1":    0.015,
+	"tts-1-hd": 0.030,
 }
 
+// datedSnapshotPattern matches the model IDs ending with the date of a snapshot,
+// as -YYYYMMDD, -YYYY-MM-DD or -MMDD.
+var datedSnapshotPattern = regexp.MustCompile(`^(.+)-(?:\d{8}|\d{4}-\d{2}-\d{2}|\d{4})$`)
+
+// shortModelID returns the model ID under which the costs of the model are listed.
 func shortModelID(modelID string) string {
 	switch modelID {
 	case "gpt-3.5-turbo-0301":
@@ -28,6 +115,16 @@ func shortModelID(modelID string) string {
 		return "gpt-4"
 	case "gpt-4-32k-0314":
 		return "gpt-4-32k"
+	case "gemini-pro":
+		return "gemini-1.0-pro"
+	}
+
+	// Dated snapshots such as claude-3-opus-20240229 or gpt-4-0613 share the cost of their family.
+	// Other suffixes name different models, such as gpt-4-turbo, which must have costs of their own.
+	if match := datedSnapshotPattern.FindStringSubmatch(modelID); match != nil {
+		if _, ok := modelCosts[match[1]]; ok {
+			return match[1]
+		}
 	}
 
 	return modelID
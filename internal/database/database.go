@@ -12,4 +12,7 @@ type ChatDatabase interface {
 	Store(userID string, newMessage *openai.ChatCompletionMessage, numToken int) error
 	Optimize(userID string, tokenLimit int)
 	Clear(userID string)
+	Count(userID string) int
+	Remove(userID string, count int)
+	Copy(fromUserID, toUserID string, skip int)
 }
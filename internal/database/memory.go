@@ -1,6 +1,7 @@
 package database
 
 import (
+	"sync"
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -14,7 +15,9 @@ type messageData struct {
 }
 
 // MemoryChatDatabase is a simple in-memory database for storing chat messages.
+// It is safe for concurrent use, since the messages are handled concurrently.
 type MemoryChatDatabase struct {
+	mu   sync.Mutex
 	data map[string][]messageData
 }
 
@@ -23,6 +26,9 @@ func (m *MemoryChatDatabase) Fetch(
 	userID string,
 	maxToken int,
 ) ([]*openai.ChatCompletionMessage, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	tokens := 0
 	var messages []*openai.ChatCompletionMessage
 
@@ -46,6 +52,9 @@ func (m *MemoryChatDatabase) Store(
 	newMessage *openai.ChatCompletionMessage,
 	numToken int,
 ) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	// Insert the new message at the beginning of the slice
 	newMessages := []messageData{
 		{
@@ -66,6 +75,9 @@ func (m *MemoryChatDatabase) Store(
 
 // Optimize deletes old messages from the database.
 func (m *MemoryChatDatabase) Optimize(userID string, tokenLimit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	tokens := 0
 
 	var newMessages []messageData
@@ -86,9 +98,37 @@ func (m *MemoryChatDatabase) Optimize(userID string, tokenLimit int) {
 
 // Clear clears the user's message history
 func (m *MemoryChatDatabase) Clear(userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.data[userID] = nil
 }
 
+// Count counts the messages in the user's message history
+func (m *MemoryChatDatabase) Count(userID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.data[userID])
+}
+
+// Remove removes the newest messages from the user's message history
+func (m *MemoryChatDatabase) Remove(userID string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.data[userID] = m.data[userID][min(count, len(m.data[userID])):]
+}
+
+// Copy copies the user's message history, except the newest skipped messages, to another user
+func (m *MemoryChatDatabase) Copy(fromUserID, toUserID string, skip int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	messages := m.data[fromUserID][min(skip, len(m.data[fromUserID])):]
+	m.data[toUserID] = append([]messageData(nil), messages...)
+}
+
 // NewMemoryChatDatabase creates a new MemoryChatDatabase
 func NewMemoryChatDatabase() ChatDatabase {
 	return &MemoryChatDatabase{
@@ -0,0 +1,248 @@
+package document
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+const (
+	// maxFormDepth is the maximum depth of nested form XObjects.
+	maxFormDepth = 8
+
+	// spaceAdjustment is the TJ adjustment, in thousandths of a text space unit, above which a space is shown.
+	spaceAdjustment = 180
+)
+
+// extractor extracts the text of a page by interpreting its content streams.
+type extractor struct {
+	pdf   *pdf
+	fonts map[any]*font
+
+	sb   strings.Builder
+	font *font
+
+	// y is the vertical position of the text line, to break lines when it moves.
+	y       float64
+	hasY    bool
+	newline bool
+	space   bool
+}
+
+// text returns the extracted text of the page.
+func (e *extractor) text() string {
+	var lines []string
+
+	for _, line := range strings.Split(e.sb.String(), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+
+	return strings.TrimSpace(collapseBlankLines(strings.Join(lines, "\n")))
+}
+
+// collapseBlankLines collapses the runs of blank lines into one.
+func collapseBlankLines(text string) string {
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+
+	return text
+}
+
+// fontOf returns the decoder of the font of the resources, cached by the font object.
+func (e *extractor) fontOf(resources dict, fontName name) *font {
+	fonts := e.pdf.dict(resources["Font"])
+	if fonts == nil {
+		return nil
+	}
+
+	object := fonts[fontName]
+	if r, ok := object.(ref); ok {
+		if f, ok := e.fonts[r]; ok {
+			return f
+		}
+	}
+
+	d := e.pdf.dict(object)
+	if d == nil {
+		return nil
+	}
+
+	f := e.pdf.newFont(d)
+	if r, ok := object.(ref); ok {
+		e.fonts[r] = f
+	}
+
+	return f
+}
+
+// moveTo breaks the line when the text moves vertically.
+func (e *extractor) moveTo(y float64) {
+	if e.hasY && math.Abs(y-e.y) > 0.5 {
+		e.newline = true
+	}
+
+	e.y, e.hasY = y, true
+}
+
+// show writes the text of the shown string.
+func (e *extractor) show(s []byte) {
+	if e.font == nil {
+		e.font = &font{encoding: winAnsiEncoding()}
+	}
+
+	text := e.font.decode(s)
+	if text == "" {
+		return
+	}
+
+	switch {
+	case e.newline:
+		e.sb.WriteString("\n")
+	case e.space:
+		e.sb.WriteString(" ")
+	}
+
+	e.newline, e.space = false, false
+	e.sb.WriteString(text)
+}
+
+// skipInlineImage skips the data of an inline image after its ID operator.
+func skipInlineImage(l *lexer) {
+	for {
+		index := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if index < 0 {
+			l.pos = len(l.data)
+			return
+		}
+
+		l.pos += index + 2
+
+		// EI ends the image only as a separate word.
+		before := l.pos - 3
+		if (before < 0 || isSpace(l.data[before])) && (l.pos >= len(l.data) || isSpace(l.data[l.pos])) {
+			return
+		}
+	}
+}
+
+// number returns the operand as a number.
+func number(operand any) float64 {
+	n, _ := operand.(float64)
+	return n
+}
+
+// run interprets the content stream with its resources.
+func (e *extractor) run(content []byte, resources dict, depth int) {
+	l := &lexer{data: content}
+
+	var operands []any
+	for {
+		tok := l.token()
+		if tok == nil {
+			return
+		}
+
+		switch t := tok.(type) {
+		case delimiter:
+			switch t {
+			case "[":
+				operands = append(operands, l.array())
+			case "<<":
+				operands = append(operands, l.dict())
+			}
+
+			continue
+		case keyword:
+		default:
+			operands = append(operands, tok)
+			continue
+		}
+
+		op := tok.(keyword)
+		last := func(i int) any {
+			if len(operands) < i {
+				return nil
+			}
+
+			return operands[len(operands)-i]
+		}
+
+		switch op {
+		case "BT":
+			e.space = true
+		case "Tf":
+			if fontName, ok := last(2).(name); ok {
+				e.font = e.fontOf(resources, fontName)
+			}
+		case "Td", "TD":
+			if ty := number(last(1)); ty != 0 {
+				e.moveTo(e.y + ty)
+			} else if number(last(2)) > 0 {
+				e.space = true
+			}
+		case "Tm":
+			e.moveTo(number(last(1)))
+			e.space = true
+		case "T*":
+			e.newline = true
+		case "Tj":
+			if s, ok := last(1).([]byte); ok {
+				e.show(s)
+			}
+		case "'", "\"":
+			e.newline = true
+
+			if s, ok := last(1).([]byte); ok {
+				e.show(s)
+			}
+		case "TJ":
+			items, _ := last(1).([]any)
+			for _, item := range items {
+				switch v := item.(type) {
+				case []byte:
+					e.show(v)
+				case float64:
+					if -v > spaceAdjustment {
+						e.space = true
+					}
+				}
+			}
+		case "ID":
+			skipInlineImage(l)
+		case "Do":
+			if depth < maxFormDepth {
+				if xName, ok := last(1).(name); ok {
+					e.runForm(resources, xName, depth)
+				}
+			}
+		}
+
+		operands = operands[:0]
+	}
+}
+
+// runForm interprets the form XObject of the resources.
+func (e *extractor) runForm(resources dict, xName name, depth int) {
+	xObjects := e.pdf.dict(resources["XObject"])
+	if xObjects == nil {
+		return
+	}
+
+	s, ok := e.pdf.resolve(xObjects[xName]).(stream)
+	if !ok || s.dict["Subtype"] != name("Form") {
+		return
+	}
+
+	data, err := e.pdf.decode(s)
+	if err != nil {
+		return
+	}
+
+	formResources := e.pdf.dict(s.dict["Resources"])
+	if formResources == nil {
+		formResources = resources
+	}
+
+	e.run(data, formResources, depth+1)
+}
@@ -0,0 +1,178 @@
+// Package document extracts the text of PDF and DOCX documents in pure Go.
+package document
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrInvalidPageRange is returned when a page range cannot be parsed.
+	ErrInvalidPageRange = errors.New("invalid page range")
+
+	// ErrMalformed is returned when the document is too damaged to be read.
+	ErrMalformed = errors.New("malformed document")
+)
+
+// Kind is a kind of supported document.
+type Kind string
+
+const (
+	// KindPDF is a PDF document.
+	KindPDF Kind = "pdf"
+
+	// KindDOCX is a Word document.
+	KindDOCX Kind = "docx"
+)
+
+// KindOf returns the kind of the document by its file name and content type.
+func KindOf(filename, contentType string) (Kind, bool) {
+	switch {
+	case strings.EqualFold(path.Ext(filename), ".pdf"), strings.HasPrefix(contentType, "application/pdf"):
+		return KindPDF, true
+	case strings.EqualFold(path.Ext(filename), ".docx"),
+		strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"):
+		return KindDOCX, true
+	}
+
+	return "", false
+}
+
+// Extract extracts the text of each page of the document.
+// The documents are untrusted, so a parser failing on a damaged one returns ErrMalformed rather than panicking.
+func Extract(kind Kind, data []byte) (pages []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("%w: %v", ErrMalformed, r)
+		}
+	}()
+
+	switch kind {
+	case KindPDF:
+		return ExtractPDF(data)
+	case KindDOCX:
+		return ExtractDOCX(data)
+	}
+
+	return nil, fmt.Errorf("unsupported document kind: %s", kind)
+}
+
+// PageRange is a selection of pages, such as 1-3,5. An empty range selects all pages.
+type PageRange []struct {
+	First, Last int
+}
+
+// ParsePageRange parses the page range, such as 1-3,5 or 10-.
+func ParsePageRange(s string) (PageRange, error) {
+	var pageRange PageRange
+
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "all") {
+		return pageRange, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		firstText, lastText, isRange := strings.Cut(strings.TrimSpace(part), "-")
+
+		first, err := strconv.Atoi(strings.TrimSpace(firstText))
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPageRange, part)
+		}
+
+		last := first
+		if isRange {
+			last = int(^uint(0) >> 1)
+
+			if lastText = strings.TrimSpace(lastText); lastText != "" {
+				if last, err = strconv.Atoi(lastText); err != nil || last < first {
+					return nil, fmt.Errorf("%w: %s", ErrInvalidPageRange, part)
+				}
+			}
+		}
+
+		pageRange = append(pageRange, struct{ First, Last int }{first, last})
+	}
+
+	return pageRange, nil
+}
+
+// Contains reports whether the page range contains the page, starting at 1.
+func (r PageRange) Contains(page int) bool {
+	if len(r) == 0 {
+		return true
+	}
+
+	for _, pages := range r {
+		if page >= pages.First && page <= pages.Last {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the page range in the syntax parsed by ParsePageRange.
+func (r PageRange) String() string {
+	if len(r) == 0 {
+		return "all"
+	}
+
+	var parts []string
+	for _, pages := range r {
+		switch {
+		case pages.First == pages.Last:
+			parts = append(parts, strconv.Itoa(pages.First))
+		case pages.Last == int(^uint(0)>>1):
+			parts = append(parts, strconv.Itoa(pages.First)+"-")
+		default:
+			parts = append(parts, fmt.Sprintf("%d-%d", pages.First, pages.Last))
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Cache is a cache of the extracted pages of documents, keyed by attachment ID,
+// which evicts the oldest documents beyond its capacity.
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []string
+	pages    map[string][]string
+}
+
+// Get returns the cached pages of the document.
+func (c *Cache) Get(id string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pages, ok := c.pages[id]
+
+	return pages, ok
+}
+
+// Put caches the pages of the document.
+func (c *Cache) Put(id string, pages []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pages[id]; !ok {
+		c.order = append(c.order, id)
+	}
+
+	c.pages[id] = pages
+
+	for len(c.order) > c.capacity {
+		delete(c.pages, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// NewCache creates a new cache of the capacity.
+func NewCache(capacity int) *Cache {
+	return &Cache{capacity: max(capacity, 1), pages: make(map[string][]string)}
+}
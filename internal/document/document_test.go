@@ -0,0 +1,90 @@
+package document
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := []struct {
+		filename, contentType string
+		want                  Kind
+		ok                    bool
+	}{
+		{"report.PDF", "", KindPDF, true},
+		{"scan", "application/pdf", KindPDF, true},
+		{"notes.docx", "", KindDOCX, true},
+		{"notes", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", KindDOCX, true},
+		{"notes.doc", "application/msword", "", false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := KindOf(tt.filename, tt.contentType); got != tt.want || ok != tt.ok {
+			t.Errorf("KindOf(%q, %q) = %q, %v, want %q, %v", tt.filename, tt.contentType, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		pages map[int]bool
+	}{
+		{input: "", want: "all", pages: map[int]bool{1: true, 100: true}},
+		{input: "All", want: "all", pages: map[int]bool{1: true}},
+		{input: "1-3, 5", want: "1-3,5", pages: map[int]bool{1: true, 3: true, 4: false, 5: true, 6: false}},
+		{input: "10-", want: "10-", pages: map[int]bool{9: false, 10: true, 1000: true}},
+		{input: "2", want: "2", pages: map[int]bool{1: false, 2: true, 3: false}},
+	}
+
+	for _, tt := range tests {
+		pageRange, err := ParsePageRange(tt.input)
+		if err != nil {
+			t.Errorf("ParsePageRange(%q) error = %v", tt.input, err)
+			continue
+		}
+
+		if got := pageRange.String(); got != tt.want {
+			t.Errorf("ParsePageRange(%q).String() = %q, want %q", tt.input, got, tt.want)
+		}
+
+		for page, want := range tt.pages {
+			if got := pageRange.Contains(page); got != want {
+				t.Errorf("ParsePageRange(%q).Contains(%d) = %v, want %v", tt.input, page, got, want)
+			}
+		}
+	}
+
+	for _, input := range []string{"0", "a", "3-1", "1-b", "-2", "1,,2"} {
+		if _, err := ParsePageRange(input); !errors.Is(err, ErrInvalidPageRange) {
+			t.Errorf("ParsePageRange(%q) error = %v, want %v", input, err, ErrInvalidPageRange)
+		}
+	}
+}
+
+func TestExtractRecovers(t *testing.T) {
+	pages, err := Extract(KindPDF, []byte("1 0 obj << /Type /ObjStm /N 1 /First 0 >> stream\n5 -3\nendstream"))
+	if err == nil || pages != nil {
+		t.Errorf("Extract() = %q, %v, want an error", pages, err)
+	}
+
+	if _, err := Extract("txt", nil); err == nil {
+		t.Error("Extract() of an unsupported kind returned no error")
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	cache.Put("a", []string{"A"})
+	cache.Put("b", []string{"B"})
+	cache.Put("c", []string{"C"})
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Get(a) found the oldest document beyond the capacity")
+	}
+
+	if pages, ok := cache.Get("c"); !ok || pages[0] != "C" {
+		t.Errorf("Get(c) = %q, %v, want [C], true", pages, ok)
+	}
+}
@@ -0,0 +1,90 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// errNoDocumentXML is returned when the DOCX has no main document part.
+var errNoDocumentXML = errors.New("no word/document.xml")
+
+// ExtractDOCX extracts the text of the DOCX. Word documents have no fixed pages,
+// so the text is returned as a single page.
+func ExtractDOCX(data []byte) ([]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		text, err := documentXMLText(io.LimitReader(r, maxDecodedSize))
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{text}, nil
+	}
+
+	return nil, errNoDocumentXML
+}
+
+// documentXMLText extracts the text of the runs of the WordprocessingML document,
+// breaking the lines at paragraphs.
+func documentXMLText(r io.Reader) (string, error) {
+	decoder := xml.NewDecoder(r)
+
+	var sb strings.Builder
+	inText := false
+
+	for {
+		tok, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			case "tc":
+				sb.WriteString("\t")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return strings.TrimSpace(collapseBlankLines(sb.String())), nil
+}
@@ -0,0 +1,122 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// buildDOCX builds a DOCX archive of the files by name.
+func buildDOCX(t testing.TB, files map[string]string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+
+	for filename, content := range files {
+		f, err := w.Create(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// documentXML wraps the body in a WordprocessingML document.
+func documentXML(body string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		body + `</w:body></w:document>`
+}
+
+func TestExtractDOCX(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "paragraphs",
+			body: `<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> World</w:t></w:r></w:p>` +
+				`<w:p><w:r><w:t>Second</w:t></w:r></w:p>`,
+			want: "Hello World\nSecond",
+		},
+		{
+			name: "tabs and breaks",
+			body: `<w:p><w:r><w:t>A</w:t><w:tab/><w:t>B</w:t><w:br/><w:t>C</w:t></w:r></w:p>`,
+			want: "A\tB\nC",
+		},
+		{
+			name: "table",
+			body: `<w:tbl><w:tr><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc>` +
+				`<w:tc><w:p><w:r><w:t>2</w:t></w:r></w:p></w:tc></w:tr></w:tbl>`,
+			want: "1\n\t2",
+		},
+		{
+			name: "blank lines",
+			body: `<w:p><w:r><w:t>A</w:t></w:r></w:p><w:p/><w:p/><w:p/><w:p><w:r><w:t>B</w:t></w:r></w:p>`,
+			want: "A\n\nB",
+		},
+		{
+			name: "instructions are not text",
+			body: `<w:p><w:r><w:instrText>PAGE</w:instrText><w:t>Text</w:t></w:r></w:p>`,
+			want: "Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildDOCX(t, map[string]string{
+				"[Content_Types].xml": "<Types/>",
+				"word/document.xml":   documentXML(tt.body),
+			})
+
+			pages, err := ExtractDOCX(data)
+			if err != nil {
+				t.Fatalf("ExtractDOCX() error = %v", err)
+			}
+
+			if len(pages) != 1 || pages[0] != tt.want {
+				t.Errorf("ExtractDOCX() = %q, want [%q]", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDOCXErrors(t *testing.T) {
+	if _, err := ExtractDOCX(buildDOCX(t, map[string]string{"word/styles.xml": "<w:styles/>"})); !errors.Is(
+		err, errNoDocumentXML,
+	) {
+		t.Errorf("ExtractDOCX() without document error = %v, want %v", err, errNoDocumentXML)
+	}
+
+	if _, err := ExtractDOCX([]byte("not a zip")); err == nil {
+		t.Error("ExtractDOCX() of a non-zip file returned no error")
+	}
+
+	if _, err := ExtractDOCX(buildDOCX(t, map[string]string{"word/document.xml": "<w:document><w:body>"})); err == nil {
+		t.Error("ExtractDOCX() of an unclosed document returned no error")
+	}
+}
+
+func FuzzExtractDOCX(f *testing.F) {
+	f.Add(buildDOCX(f, map[string]string{
+		"word/document.xml": documentXML(`<w:p><w:r><w:t>Hello</w:t><w:tab/><w:br/></w:r></w:p>`),
+	}))
+	f.Add([]byte("PK\x03\x04"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// The parser must not panic, whatever it returns.
+		_, _ = ExtractDOCX(data)
+	})
+}
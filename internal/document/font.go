@@ -0,0 +1,341 @@
+package document
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// codeRange is a code space range of a CMap, whose codes have the same number of bytes.
+type codeRange struct {
+	low, high []byte
+}
+
+// contains reports whether the code is within the range.
+func (r codeRange) contains(code []byte) bool {
+	if len(code) != len(r.low) {
+		return false
+	}
+
+	for i := range code {
+		if code[i] < r.low[i] || code[i] > r.high[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// font decodes the strings shown with a font into text.
+type font struct {
+	// codeRanges are the code space ranges of the codes, of two bytes by default for composite fonts.
+	codeRanges []codeRange
+	composite  bool
+
+	// toUnicode maps the codes to text by the ToUnicode CMap of the font.
+	toUnicode map[string]string
+
+	// encoding maps the one byte codes of simple fonts without a ToUnicode CMap.
+	encoding [256]rune
+}
+
+// newFont creates the decoder of the font dictionary.
+func (p *pdf) newFont(d dict) *font {
+	f := &font{composite: d["Subtype"] == name("Type0"), encoding: winAnsiEncoding()}
+
+	if s, ok := p.resolve(d["ToUnicode"]).(stream); ok {
+		if data, err := p.decode(s); err == nil {
+			f.parseCMap(data)
+		}
+	}
+
+	switch encoding := p.resolve(d["Encoding"]).(type) {
+	case name:
+		f.setBaseEncoding(encoding)
+	case dict:
+		if base, ok := p.resolve(encoding["BaseEncoding"]).(name); ok {
+			f.setBaseEncoding(base)
+		}
+
+		if differences, ok := p.resolve(encoding["Differences"]).([]any); ok {
+			code := 0
+			for _, item := range differences {
+				switch v := p.resolve(item).(type) {
+				case float64:
+					code = int(v)
+				case name:
+					if r, ok := glyphRune(string(v)); ok && code >= 0 && code < 256 {
+						f.encoding[code] = r
+					}
+
+					code++
+				}
+			}
+		}
+	}
+
+	return f
+}
+
+// setBaseEncoding sets the named encoding of a simple font.
+func (f *font) setBaseEncoding(encoding name) {
+	if encoding == "MacRomanEncoding" {
+		f.encoding = macRomanEncoding()
+	}
+}
+
+// parseCMap parses the code space ranges and the mappings of a ToUnicode CMap.
+func (f *font) parseCMap(data []byte) {
+	f.toUnicode = make(map[string]string)
+	l := &lexer{data: data}
+
+	var operands []any
+	for {
+		tok := l.token()
+		if tok == nil {
+			return
+		}
+
+		kw, ok := tok.(keyword)
+		if !ok {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch kw {
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				low, ok1 := operands[i].([]byte)
+				high, ok2 := operands[i+1].([]byte)
+
+				if ok1 && ok2 && len(low) == len(high) && len(low) > 0 {
+					f.codeRanges = append(f.codeRanges, codeRange{low: low, high: high})
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].([]byte)
+				dst, ok2 := operands[i+1].([]byte)
+
+				if ok1 && ok2 {
+					f.toUnicode[string(src)] = decodeUTF16(dst)
+				}
+			}
+		case "endbfrange":
+			f.parseBFRanges(operands)
+		}
+
+		if strings.HasPrefix(string(kw), "end") || strings.HasPrefix(string(kw), "begin") {
+			operands = nil
+		}
+	}
+}
+
+// parseBFRanges parses the operands of a bfrange section, whose destinations are strings or arrays.
+// The arrays are read as delimiters by the token loop, so they are grouped here.
+func (f *font) parseBFRanges(operands []any) {
+	var values []any
+
+	for i := 0; i < len(operands); i++ {
+		if d, ok := operands[i].(delimiter); ok && d == "[" {
+			var array [][]byte
+			for i++; i < len(operands); i++ {
+				if d, ok := operands[i].(delimiter); ok && d == "]" {
+					break
+				}
+
+				if s, ok := operands[i].([]byte); ok {
+					array = append(array, s)
+				}
+			}
+
+			values = append(values, array)
+
+			continue
+		}
+
+		values = append(values, operands[i])
+	}
+
+	for i := 0; i+2 < len(values); i += 3 {
+		low, ok1 := values[i].([]byte)
+		high, ok2 := values[i+1].([]byte)
+
+		if !ok1 || !ok2 || len(low) != len(high) || len(low) == 0 {
+			continue
+		}
+
+		first, last := codeValue(low), codeValue(high)
+		if last < first || last-first > 0xFFFF {
+			continue
+		}
+
+		for code := first; code <= last; code++ {
+			src := codeBytes(code, len(low))
+
+			switch dst := values[i+2].(type) {
+			case []byte:
+				// The last code unit is incremented along the range.
+				units := utf16Units(dst)
+				if len(units) > 0 {
+					units[len(units)-1] += uint16(code - first)
+				}
+
+				f.toUnicode[string(src)] = string(utf16.Decode(units))
+			case [][]byte:
+				if code-first < len(dst) {
+					f.toUnicode[string(src)] = decodeUTF16(dst[code-first])
+				}
+			}
+		}
+	}
+}
+
+// codeValue returns the value of the big-endian code.
+func codeValue(code []byte) int {
+	value := 0
+	for _, b := range code {
+		value = value<<8 | int(b)
+	}
+
+	return value
+}
+
+// codeBytes returns the big-endian code of the value.
+func codeBytes(value, length int) []byte {
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = byte(value)
+		value >>= 8
+	}
+
+	return code
+}
+
+// utf16Units returns the UTF-16BE code units of the bytes.
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+
+	return units
+}
+
+// decodeUTF16 decodes the UTF-16BE bytes.
+func decodeUTF16(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// codeLength returns the number of bytes of the code at the beginning of the string.
+func (f *font) codeLength(s []byte) int {
+	for _, r := range f.codeRanges {
+		if len(r.low) <= len(s) && r.contains(s[:len(r.low)]) {
+			return len(r.low)
+		}
+	}
+
+	if f.composite && len(s) >= 2 {
+		return 2
+	}
+
+	return 1
+}
+
+// decode decodes the shown string into text.
+func (f *font) decode(s []byte) string {
+	var sb strings.Builder
+
+	for len(s) > 0 {
+		n := f.codeLength(s)
+		code := s[:n]
+		s = s[n:]
+
+		if text, ok := f.toUnicode[string(code)]; ok {
+			sb.WriteString(text)
+			continue
+		}
+
+		// The codes of composite fonts are glyph IDs, which cannot be mapped without a ToUnicode CMap.
+		if f.composite || n != 1 {
+			continue
+		}
+
+		if r := f.encoding[code[0]]; r != 0 {
+			sb.WriteRune(r)
+		}
+	}
+
+	return sb.String()
+}
+
+// winAnsiEncoding returns the Windows-1252 encoding used by most simple fonts.
+func winAnsiEncoding() [256]rune {
+	var encoding [256]rune
+
+	for i := 32; i < 256; i++ {
+		encoding[i] = rune(i)
+	}
+
+	for i, r := range []rune("€\x00‚ƒ„…†‡ˆ‰Š‹Œ\x00Ž\x00\x00‘’“”•–—˜™š›œ\x00žŸ") {
+		encoding[0x80+i] = r
+	}
+
+	encoding['\t'], encoding['\n'], encoding['\r'] = ' ', ' ', ' '
+
+	return encoding
+}
+
+// macRomanEncoding returns the Mac OS Roman encoding.
+func macRomanEncoding() [256]rune {
+	var encoding [256]rune
+
+	for i := 32; i < 128; i++ {
+		encoding[i] = rune(i)
+	}
+
+	high := []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø" +
+		"¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔ\uf8ffÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+	for i, r := range high {
+		encoding[0x80+i] = r
+	}
+
+	return encoding
+}
+
+// glyphNames are the common glyph names which are not single letters or uniXXXX names.
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "quoteright": '’', "quoteleft": '‘', "parenleft": '(',
+	"parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-', "minus": '−',
+	"period": '.', "slash": '/', "colon": ':', "semicolon": ';', "less": '<', "equal": '=',
+	"greater": '>', "question": '?', "at": '@', "bracketleft": '[', "backslash": '\\',
+	"bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`', "braceleft": '{',
+	"bar": '|', "braceright": '}', "asciitilde": '~', "quotedblleft": '“', "quotedblright": '”',
+	"endash": '–', "emdash": '—', "bullet": '•', "ellipsis": '…', "fi": 'ﬁ', "fl": 'ﬂ',
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+	"degree": '°', "copyright": '©', "registered": '®', "trademark": '™', "section": '§',
+	"paragraph": '¶', "dagger": '†', "daggerdbl": '‡', "multiply": '×', "divide": '÷',
+}
+
+// glyphRune returns the character of the glyph name.
+func glyphRune(glyph string) (rune, bool) {
+	if r, ok := glyphNames[glyph]; ok {
+		return r, true
+	}
+
+	if len(glyph) == 1 {
+		return rune(glyph[0]), true
+	}
+
+	for _, prefix := range []string{"uni", "u"} {
+		if hexCode, ok := strings.CutPrefix(glyph, prefix); ok && len(hexCode) >= 4 {
+			if code, err := strconv.ParseUint(hexCode[:4], 16, 32); err == nil {
+				return rune(code), true
+			}
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,339 @@
+package document
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+)
+
+// name is a PDF name object, such as /Type.
+type name string
+
+// keyword is a bare PDF keyword, such as obj, R or a content stream operator.
+type keyword string
+
+// dict is a PDF dictionary.
+type dict map[name]any
+
+// ref is an indirect reference to an object.
+type ref struct {
+	num, gen int
+}
+
+// stream is a PDF stream with its dictionary and raw data.
+type stream struct {
+	dict dict
+	data []byte
+}
+
+// delimiter is a delimiter token of the lexer, such as [ or <<.
+type delimiter string
+
+// lexer reads the tokens of PDF objects and content streams.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+// seek moves to the position, and reports whether it is within the data.
+func (l *lexer) seek(pos int) bool {
+	if pos < 0 || pos > len(l.data) {
+		return false
+	}
+
+	l.pos = pos
+
+	return true
+}
+
+// isSpace reports whether the byte is PDF white space.
+func isSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isDelimiter reports whether the byte is a PDF delimiter.
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+// skipSpaces skips the white space and the comments.
+func (l *lexer) skipSpaces() {
+	if l.pos < 0 {
+		l.pos = len(l.data)
+	}
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+
+		switch {
+		case isSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads the next token, or returns nil at the end of the data.
+// Tokens are numbers (float64), names, strings ([]byte), keywords and delimiters.
+func (l *lexer) token() any {
+	l.skipSpaces()
+
+	if l.pos >= len(l.data) {
+		return nil
+	}
+
+	c := l.data[l.pos]
+
+	switch {
+	case c == '/':
+		return l.name()
+	case c == '(':
+		return l.literalString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return delimiter("<<")
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return delimiter(">>")
+	case c == '<':
+		return l.hexString()
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return delimiter(c)
+	case c == ')' || c == '>':
+		// A stray delimiter of a damaged file.
+		l.pos++
+		return l.token()
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+
+	word := string(l.data[start:l.pos])
+	if isNumber(word) {
+		if number, err := strconv.ParseFloat(word, 64); err == nil {
+			return number
+		}
+	}
+
+	return keyword(word)
+}
+
+// isNumber reports whether the word has the syntax of a PDF number,
+// unlike the words such as inf or nan also parsed as floats.
+func isNumber(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if c := word[i]; (c < '0' || c > '9') && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+
+	return word != ""
+}
+
+// isIndex reports whether the number is an integer from 0 to the limit, excluded.
+func isIndex(number float64, limit int) bool {
+	return number >= 0 && number < float64(limit) && number == float64(int(number))
+}
+
+// name reads a name, decoding its #xx escapes.
+func (l *lexer) name() name {
+	l.pos++
+
+	var sb []byte
+	for l.pos < len(l.data) && !isSpace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		c := l.data[l.pos]
+		if c == '#' && l.pos+2 < len(l.data) {
+			if decoded, err := hex.DecodeString(string(l.data[l.pos+1 : l.pos+3])); err == nil {
+				sb = append(sb, decoded[0])
+				l.pos += 3
+
+				continue
+			}
+		}
+
+		sb = append(sb, c)
+		l.pos++
+	}
+
+	return name(sb)
+}
+
+// literalString reads a literal string, such as (Hello\nWorld).
+func (l *lexer) literalString() []byte {
+	l.pos++
+
+	var sb []byte
+	depth := 0
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return sb
+			}
+
+			depth--
+		case '\\':
+			if l.pos >= len(l.data) {
+				return sb
+			}
+
+			c = l.data[l.pos]
+			l.pos++
+
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// A line continuation.
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					value := int(c - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+
+					c = byte(value)
+				}
+			}
+		}
+
+		sb = append(sb, c)
+	}
+
+	return sb
+}
+
+// hexString reads a hexadecimal string, such as <48656C6C6F>.
+func (l *lexer) hexString() []byte {
+	l.pos++
+
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+
+		l.pos++
+	}
+
+	// The > is skipped unless the string is not closed.
+	if l.pos < len(l.data) {
+		l.pos++
+	}
+
+	// A missing last digit is zero.
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+
+	decoded := make([]byte, len(digits)/2)
+	n, _ := hex.Decode(decoded, digits)
+
+	return decoded[:n]
+}
+
+// object reads an object, resolving the n g R references as refs.
+func (l *lexer) object() any {
+	tok := l.token()
+
+	switch t := tok.(type) {
+	case delimiter:
+		switch t {
+		case "<<":
+			return l.dict()
+		case "[":
+			return l.array()
+		}
+
+		return t
+	case float64:
+		// An integer may start a reference.
+		if t != float64(int(t)) || t < 0 {
+			return t
+		}
+
+		saved := l.pos
+		if gen, ok := l.token().(float64); ok {
+			if kw, ok := l.token().(keyword); ok && kw == "R" {
+				return ref{num: int(t), gen: int(gen)}
+			}
+		}
+
+		l.pos = saved
+
+		return t
+	}
+
+	return tok
+}
+
+// dict reads the entries of a dictionary after its <<.
+func (l *lexer) dict() dict {
+	d := make(dict)
+
+	for {
+		tok := l.token()
+
+		switch t := tok.(type) {
+		case nil:
+			return d
+		case delimiter:
+			if t == ">>" {
+				return d
+			}
+		case name:
+			d[t] = l.object()
+		}
+	}
+}
+
+// array reads the elements of an array after its [.
+func (l *lexer) array() []any {
+	var a []any
+
+	for {
+		saved := l.pos
+		tok := l.token()
+
+		if tok == nil {
+			return a
+		}
+
+		if t, ok := tok.(delimiter); ok && t == "]" {
+			return a
+		}
+
+		l.pos = saved
+		a = append(a, l.object())
+	}
+}
@@ -0,0 +1,415 @@
+package document
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+const (
+	// maxDecodedSize is the maximum size of a decoded stream, against decompression bombs.
+	maxDecodedSize = 64 << 20
+
+	// maxObjectNumber is the maximum number of an object, as limited by the PDF specification.
+	maxObjectNumber = 8388608
+)
+
+var (
+	// ErrEncrypted is returned when the PDF is encrypted.
+	ErrEncrypted = errors.New("encrypted PDF")
+
+	// ErrNoPages is returned when no page of the PDF can be found.
+	ErrNoPages = errors.New("no pages")
+
+	// errUnsupportedFilter is returned when a stream is encoded with an unsupported filter.
+	errUnsupportedFilter = errors.New("unsupported filter")
+)
+
+// objectHeader matches the header of an indirect object, such as 12 0 obj.
+var objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdf is a parsed PDF file.
+type pdf struct {
+	objects map[int]any
+	trailer dict
+}
+
+// parsePDF parses the objects of the PDF.
+// The objects are found by scanning the file rather than by the cross-reference table,
+// which is often broken.
+func parsePDF(data []byte) (*pdf, error) {
+	p := &pdf{objects: make(map[int]any), trailer: make(dict)}
+	l := &lexer{data: data}
+
+	for l.pos < len(data) {
+		match := objectHeader.FindSubmatchIndex(data[l.pos:])
+		if match == nil {
+			break
+		}
+
+		num := atoi(data[l.pos+match[2] : l.pos+match[3]])
+		l.pos += match[1]
+
+		// Later objects of incremental updates replace the earlier ones.
+		object := p.readObject(l)
+		p.objects[num] = object
+
+		if s, ok := object.(stream); ok && s.dict["Type"] == name("XRef") {
+			p.updateTrailer(s.dict)
+		}
+	}
+
+	for _, match := range regexp.MustCompile(`trailer\s*<<`).FindAllIndex(data, -1) {
+		if l.seek(match[1]) {
+			p.updateTrailer(l.dict())
+		}
+	}
+
+	if _, ok := p.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+
+	p.expandObjectStreams()
+
+	return p, nil
+}
+
+// atoi parses the decimal digits.
+func atoi(digits []byte) int {
+	n := 0
+	for _, d := range digits {
+		n = n*10 + int(d-'0')
+	}
+
+	return n
+}
+
+// updateTrailer updates the trailer with the entries of a trailer dictionary or a cross-reference stream.
+func (p *pdf) updateTrailer(d dict) {
+	for _, key := range []name{"Root", "Encrypt", "Info"} {
+		if value, ok := d[key]; ok {
+			p.trailer[key] = value
+		}
+	}
+}
+
+// readObject reads the object after its header, with the data of a stream.
+func (p *pdf) readObject(l *lexer) any {
+	object := l.object()
+
+	d, ok := object.(dict)
+	if !ok {
+		return object
+	}
+
+	saved := l.pos
+	if kw, ok := l.token().(keyword); !ok || kw != "stream" {
+		l.pos = saved
+		return object
+	}
+
+	// The data starts after the end of line of the stream keyword.
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+
+	start := l.pos
+	end := -1
+
+	// The length is trusted only if it is direct and the stream ends there.
+	if length, ok := d["Length"].(float64); ok && isIndex(length, len(l.data)-start+1) {
+		after := bytes.TrimLeft(l.data[start+int(length):min(start+int(length)+32, len(l.data))], "\r\n\t \x00")
+		if bytes.HasPrefix(after, []byte("endstream")) {
+			end = start + int(length)
+		}
+	}
+
+	if end < 0 {
+		index := bytes.Index(l.data[start:], []byte("endstream"))
+		if index < 0 {
+			l.pos = len(l.data)
+			return stream{dict: d, data: l.data[start:]}
+		}
+
+		end = start + index
+	}
+
+	l.pos = end + len("endstream")
+
+	return stream{dict: d, data: l.data[start:end]}
+}
+
+// expandObjectStreams adds the objects compressed in object streams.
+func (p *pdf) expandObjectStreams() {
+	for _, object := range p.objects {
+		s, ok := object.(stream)
+		if !ok || s.dict["Type"] != name("ObjStm") {
+			continue
+		}
+
+		data, err := p.decode(s)
+		if err != nil {
+			continue
+		}
+
+		n, _ := p.resolve(s.dict["N"]).(float64)
+		first, _ := p.resolve(s.dict["First"]).(float64)
+
+		if !isIndex(first, len(data)) {
+			continue
+		}
+
+		// The header has a pair of numbers per object, so it cannot have more objects than bytes.
+		header := &lexer{data: data}
+		for i := 0; i < len(data) && float64(i) < n; i++ {
+			num, ok1 := header.token().(float64)
+			offset, ok2 := header.token().(float64)
+
+			if !ok1 || !ok2 || !isIndex(num, maxObjectNumber) || !isIndex(first+offset, len(data)) || offset < 0 {
+				break
+			}
+
+			// Objects outside of the object streams are newer.
+			if _, ok := p.objects[int(num)]; ok {
+				continue
+			}
+
+			l := &lexer{data: data}
+			if l.seek(int(first + offset)) {
+				p.objects[int(num)] = l.object()
+			}
+		}
+	}
+}
+
+// resolve resolves the reference, following chains of references.
+func (p *pdf) resolve(object any) any {
+	for i := 0; i < 32; i++ {
+		r, ok := object.(ref)
+		if !ok {
+			return object
+		}
+
+		object = p.objects[r.num]
+	}
+
+	return nil
+}
+
+// dict resolves the object as a dictionary, including the dictionary of a stream.
+func (p *pdf) dict(object any) dict {
+	switch o := p.resolve(object).(type) {
+	case dict:
+		return o
+	case stream:
+		return o.dict
+	}
+
+	return nil
+}
+
+// decode decodes the data of the stream with its filters.
+func (p *pdf) decode(s stream) ([]byte, error) {
+	var filters []any
+
+	switch f := p.resolve(s.dict["Filter"]).(type) {
+	case name:
+		filters = []any{f}
+	case []any:
+		filters = f
+	}
+
+	data := s.data
+	for _, filter := range filters {
+		var err error
+
+		switch p.resolve(filter) {
+		case name("FlateDecode"), name("Fl"):
+			data, err = inflate(data)
+		case name("ASCIIHexDecode"), name("AHx"):
+			data = (&lexer{data: append(append([]byte("<"), data...), '>')}).hexString()
+		case name("ASCII85Decode"), name("A85"):
+			data, err = decodeASCII85(data)
+		default:
+			err = fmt.Errorf("%w: %v", errUnsupportedFilter, filter)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// inflate decompresses zlib data, keeping what can be read of truncated or damaged data.
+func inflate(data []byte) ([]byte, error) {
+	var r io.Reader
+
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(r, maxDecodedSize))
+	if len(decoded) > 0 {
+		return decoded, nil
+	}
+
+	return nil, err
+}
+
+// decodeASCII85 decodes ASCII base-85 data ending with ~>.
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if end := bytes.Index(data, []byte("~>")); end >= 0 {
+		data = data[:end]
+	}
+
+	return io.ReadAll(ascii85.NewDecoder(bytes.NewReader(data)))
+}
+
+// page is a page of the PDF with its inherited resources.
+type page struct {
+	dict      dict
+	resources dict
+}
+
+// pages returns the pages of the PDF in order.
+func (p *pdf) pages() []page {
+	var pages []page
+	visited := make(map[any]bool)
+
+	var walk func(node any, resources dict)
+	walk = func(node any, resources dict) {
+		if r, ok := node.(ref); ok {
+			if visited[r] {
+				return
+			}
+
+			visited[r] = true
+		}
+
+		d := p.dict(node)
+		if d == nil {
+			return
+		}
+
+		if own := p.dict(d["Resources"]); own != nil {
+			resources = own
+		}
+
+		kids, ok := p.resolve(d["Kids"]).([]any)
+		if !ok {
+			pages = append(pages, page{dict: d, resources: resources})
+			return
+		}
+
+		for _, kid := range kids {
+			walk(kid, resources)
+		}
+	}
+
+	if root := p.dict(p.trailer["Root"]); root != nil {
+		walk(root["Pages"], nil)
+	}
+
+	if len(pages) > 0 {
+		return pages
+	}
+
+	// Without a usable page tree, the pages are taken in the order of their object numbers.
+	var nums []int
+	for num, object := range p.objects {
+		if d := p.dict(object); d != nil && d["Type"] == name("Page") {
+			nums = append(nums, num)
+		}
+	}
+
+	slices.Sort(nums)
+
+	for _, num := range nums {
+		d := p.dict(p.objects[num])
+		pages = append(pages, page{dict: d, resources: p.inheritedResources(d)})
+	}
+
+	return pages
+}
+
+// inheritedResources returns the resources of the page, inherited from its parents if needed.
+func (p *pdf) inheritedResources(d dict) dict {
+	for i := 0; d != nil && i < 32; i++ {
+		if resources := p.dict(d["Resources"]); resources != nil {
+			return resources
+		}
+
+		d = p.dict(d["Parent"])
+	}
+
+	return nil
+}
+
+// contents returns the decoded content streams of the page.
+func (p *pdf) contents(pg page) []byte {
+	var streams []any
+
+	switch c := p.resolve(pg.dict["Contents"]).(type) {
+	case stream:
+		streams = []any{c}
+	case []any:
+		streams = c
+	}
+
+	var contents []byte
+	for _, object := range streams {
+		s, ok := p.resolve(object).(stream)
+		if !ok {
+			continue
+		}
+
+		data, err := p.decode(s)
+		if err != nil {
+			continue
+		}
+
+		contents = append(contents, data...)
+		contents = append(contents, '\n')
+	}
+
+	return contents
+}
+
+// ExtractPDF extracts the text of each page of the PDF.
+func ExtractPDF(data []byte) ([]string, error) {
+	p, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := p.pages()
+	if len(pages) == 0 {
+		return nil, ErrNoPages
+	}
+
+	texts := make([]string, len(pages))
+	fonts := make(map[any]*font)
+
+	for i, pg := range pages {
+		e := &extractor{pdf: p, fonts: fonts}
+		e.run(p.contents(pg), pg.resources, 0)
+		texts[i] = e.text()
+	}
+
+	return texts, nil
+}
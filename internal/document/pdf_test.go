@@ -0,0 +1,259 @@
+package document
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF builds a PDF of the objects, numbered from 1, with the first one as its catalog.
+func buildPDF(objects ...string) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("%PDF-1.7\n")
+	for i, object := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+
+	return b.Bytes()
+}
+
+// streamObject returns a stream object of the data with the entries of its dictionary.
+func streamObject(entries string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", entries, len(data), data)
+}
+
+// deflate compresses the data with zlib.
+func deflate(t testing.TB, data string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+// singlePagePDF builds a PDF of a page with the content stream, showing text with a Helvetica font named F1.
+func singlePagePDF(content string) []byte {
+	return buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		streamObject("", []byte(content)),
+	)
+}
+
+func TestExtractPDF(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "show",
+			content: "BT /F1 12 Tf 72 712 Td (Hello World) Tj ET",
+			want:    "Hello World",
+		},
+		{
+			name:    "lines",
+			content: "BT /F1 12 Tf 72 712 Td (First line) Tj 0 -14 Td (Second line) Tj ET",
+			want:    "First line\nSecond line",
+		},
+		{
+			name:    "kerning",
+			content: "BT /F1 12 Tf [(Hel) 20 (lo) -250 (World)] TJ ET",
+			want:    "Hello World",
+		},
+		{
+			name:    "escapes",
+			content: `BT /F1 12 Tf (Caf\351 \(ouvert\)) Tj ET`,
+			want:    "Café (ouvert)",
+		},
+		{
+			name:    "hex string",
+			content: "BT /F1 12 Tf <48656C6C6F> Tj ET",
+			want:    "Hello",
+		},
+		{
+			name:    "inline image",
+			content: "BT /F1 12 Tf 72 712 Td (Before) Tj ET BI /W 1 /H 1 ID \x00EI\xff EI BT 0 -14 Td (After) Tj ET",
+			want:    "Before\nAfter",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages, err := ExtractPDF(singlePagePDF(tt.content))
+			if err != nil {
+				t.Fatalf("ExtractPDF() error = %v", err)
+			}
+
+			if len(pages) != 1 || pages[0] != tt.want {
+				t.Errorf("ExtractPDF() = %q, want [%q]", pages, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractPDFPagesInOrder(t *testing.T) {
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [4 0 R 3 0 R] /Count 2 /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		streamObject("", []byte("BT /F1 12 Tf (Second) Tj ET")),
+		streamObject("", []byte("BT /F1 12 Tf (First) Tj ET")),
+	)
+
+	pages, err := ExtractPDF(data)
+	if err != nil {
+		t.Fatalf("ExtractPDF() error = %v", err)
+	}
+
+	if want := []string{"First", "Second"}; strings.Join(pages, "|") != strings.Join(want, "|") {
+		t.Errorf("ExtractPDF() = %q, want %q", pages, want)
+	}
+}
+
+func TestExtractPDFCompressed(t *testing.T) {
+	content := deflate(t, "BT /F1 12 Tf (Compressed text \216) Tj ET")
+
+	// The page and its font are in a compressed object stream.
+	page := "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>"
+	font := "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /MacRomanEncoding >>"
+	header := fmt.Sprintf("3 0 4 %d ", len(page)+1)
+	objects := header + page + " " + font
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		streamObject(
+			fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(header)),
+			deflate(t, objects),
+		),
+		"null",
+		streamObject("/Filter /FlateDecode", content),
+	)
+
+	// The objects 3 and 4 are only defined in the object stream.
+	data = bytes.Replace(data, []byte("4 0 obj\nnull\nendobj\n"), nil, 1)
+	data = bytes.Replace(data, []byte("3 0 obj\n"), []byte("6 0 obj\n"), 1)
+
+	pages, err := ExtractPDF(data)
+	if err != nil {
+		t.Fatalf("ExtractPDF() error = %v", err)
+	}
+
+	// \216 is é in the Mac Roman encoding of the compressed font.
+	if len(pages) != 1 || pages[0] != "Compressed text é" {
+		t.Errorf("ExtractPDF() = %q, want [%q]", pages, "Compressed text é")
+	}
+}
+
+func TestExtractPDFToUnicode(t *testing.T) {
+	cmap := "begincodespacerange <0000> <FFFF> endcodespacerange " +
+		"2 beginbfchar <0001> <0048> <0002> <0069> endbfchar " +
+		"1 beginbfrange <0003> <0004> <00E9> endbfrange"
+
+	data := buildPDF(
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /Noto /Encoding /Identity-H /ToUnicode 6 0 R >>",
+		streamObject("", []byte("BT /F1 12 Tf <000100020004> Tj ET")),
+		streamObject("", []byte(cmap)),
+	)
+
+	pages, err := ExtractPDF(data)
+	if err != nil {
+		t.Fatalf("ExtractPDF() error = %v", err)
+	}
+
+	if len(pages) != 1 || pages[0] != "Hiê" {
+		t.Errorf("ExtractPDF() = %q, want [%q]", pages, "Hiê")
+	}
+}
+
+func TestExtractPDFErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{
+			name: "encrypted",
+			data: append(buildPDF("<< /Type /Catalog /Pages 2 0 R >>"), "trailer << /Encrypt 2 0 R >>"...),
+			want: ErrEncrypted,
+		},
+		{
+			name: "no pages",
+			data: buildPDF("<< /Type /Catalog >>"),
+			want: ErrNoPages,
+		},
+		{
+			name: "not a PDF",
+			data: []byte("hello"),
+			want: ErrNoPages,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExtractPDF(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("ExtractPDF() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// malformedPDFs are damaged PDFs which crashed the parser.
+var malformedPDFs = []string{
+	"1 0 obj << /Type /ObjStm /N 1 /First 0 >> stream\n5 -3\nendstream",
+	"1 0 obj << /Type /ObjStm /N 1 /First -8 >> stream\n5 3\nendstream",
+	"1 0 obj << /Type /ObjStm /N 1 /First 0.5 >> stream\n5 0\nendstream",
+	"1 0 obj << /Type /ObjStm /N 1 /First 0 >> stream\n-5 0\nendstream",
+	"1 0 obj << /Type /ObjStm /N inf /First nan >> stream\n5 nan\nendstream",
+	"1 0 obj << /Type /ObjStm /N 1e30 /First 0 >> stream\n5 1e30\nendstream",
+	"1 0 obj << /Length 1e30 >> stream\nabc\nendstream",
+	"1 0 obj << /Length -1 >> stream\nabc",
+	"1 0 obj <48656C",
+	"trailer <<",
+}
+
+func TestExtractMalformedPDF(t *testing.T) {
+	for _, data := range malformedPDFs {
+		if _, err := Extract(KindPDF, []byte(data)); err == nil {
+			t.Errorf("Extract(%q) returned no error", data)
+		}
+	}
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(singlePagePDF("BT /F1 12 Tf 72 712 Td (Hello World) Tj ET"))
+	f.Add(singlePagePDF("BT /F1 12 Tf [(A) -300 (B)] TJ 0 -14 TD <4142> Tj T* (C) ' ET"))
+	f.Add(singlePagePDF("q BI /W 1 ID \x00 EI Q /Fm1 Do"))
+
+	for _, data := range malformedPDFs {
+		f.Add([]byte(data))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// The parser must not panic, whatever it returns.
+		_, _ = ExtractPDF(data)
+	})
+}
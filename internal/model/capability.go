@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"chatbot-gpt/internal/config"
+)
+
+// Capability describes what a model is able to do.
+type Capability struct {
+	// Provider is the name of the provider serving the model, or empty for the default provider.
+	Provider           string
+	ContextWindow      int
+	MaxOutputTokens    int
+	SupportsSystemRole bool
+	SupportsStreaming  bool
+	SupportsVision     bool
+	SupportsTools      bool
+	SupportsJSONMode   bool
+}
+
+var (
+	// ErrContextWindowExceeded is returned when the token limits do not fit in the context window.
+	ErrContextWindowExceeded = errors.New("token limits exceed the context window")
+
+	// ErrMaxOutputTokensExceeded is returned when the completion token limit exceeds the maximum output.
+	ErrMaxOutputTokensExceeded = errors.New("completion token limit exceeds the maximum output tokens")
+)
+
+// CheckLimits checks whether the given token limits fit the model.
+func (c Capability) CheckLimits(promptTokenLimit, completionTokenLimit int) error {
+	if promptTokenLimit+completionTokenLimit > c.ContextWindow {
+		return ErrContextWindowExceeded
+	}
+
+	if completionTokenLimit > c.MaxOutputTokens {
+		return ErrMaxOutputTokensExceeded
+	}
+
+	return nil
+}
+
+// Registry is a registry of model capabilities.
+type Registry struct {
+	capabilities map[string]Capability
+}
+
+// Lookup returns the capability of the given model.
+// Dated snapshots such as gpt-4-0613 fall back to the longest registered prefix.
+func (r *Registry) Lookup(modelID string) (Capability, bool) {
+	if capability, ok := r.capabilities[modelID]; ok {
+		return capability, true
+	}
+
+	longestPrefix := ""
+	for id := range r.capabilities {
+		if strings.HasPrefix(modelID, id+"-") && len(id) > len(longestPrefix) {
+			longestPrefix = id
+		}
+	}
+
+	if longestPrefix == "" {
+		return Capability{}, false
+	}
+
+	return r.capabilities[longestPrefix], true
+}
+
+// Update overrides the capability of a model with the non-blank fields of the configuration.
+func (r *Registry) Update(cfg config.Model) {
+	capability, _ := r.Lookup(cfg.ID)
+
+	if cfg.Provider != "" {
+		capability.Provider = cfg.Provider
+	}
+
+	if cfg.ContextWindow > 0 {
+		capability.ContextWindow = cfg.ContextWindow
+	}
+
+	if cfg.MaxOutputTokens > 0 {
+		capability.MaxOutputTokens = cfg.MaxOutputTokens
+	}
+
+	overrideBool(&capability.SupportsSystemRole, cfg.SupportsSystemRole)
+	overrideBool(&capability.SupportsStreaming, cfg.SupportsStreaming)
+	overrideBool(&capability.SupportsVision, cfg.SupportsVision)
+	overrideBool(&capability.SupportsTools, cfg.SupportsTools)
+	overrideBool(&capability.SupportsJSONMode, cfg.SupportsJSONMode)
+
+	r.capabilities[cfg.ID] = capability
+}
+
+// overrideBool overrides the value if the override is set.
+func overrideBool(value *bool, override *bool) {
+	if override != nil {
+		*value = *override
+	}
+}
+
+// NewRegistry creates a new registry with the built-in capabilities.
+func NewRegistry() *Registry {
+	capabilities := make(map[string]Capability, len(defaultCapabilities))
+	for id, capability := range defaultCapabilities {
+		capabilities[id] = capability
+	}
+
+	return &Registry{
+		capabilities: capabilities,
+	}
+}
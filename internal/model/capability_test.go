@@ -0,0 +1,84 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"chatbot-gpt/internal/config"
+)
+
+func TestLookup(t *testing.T) {
+	registry := NewRegistry()
+
+	tests := []struct {
+		modelID       string
+		found         bool
+		provider      string
+		contextWindow int
+	}{
+		{modelID: "gpt-4", found: true, contextWindow: 8192},
+		{modelID: "gpt-4-0613", found: true, contextWindow: 8192},
+		{modelID: "gpt-4-32k-0613", found: true, contextWindow: 32768},
+		{modelID: "gpt-4-turbo-2024-04-09", found: true, contextWindow: 128000},
+		{modelID: "claude-3-haiku-20240307", found: true, provider: "anthropic", contextWindow: 200000},
+		{modelID: "gemini-1.5-flash-001", found: true, provider: "gemini", contextWindow: 1048576},
+		{modelID: "gpt-4x", found: false},
+		{modelID: "llama-3", found: false},
+	}
+
+	for _, tt := range tests {
+		capability, ok := registry.Lookup(tt.modelID)
+		if ok != tt.found || capability.Provider != tt.provider || capability.ContextWindow != tt.contextWindow {
+			t.Errorf("Lookup(%q) = %+v, %v, want provider %q and context window %d",
+				tt.modelID, capability, ok, tt.provider, tt.contextWindow)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	registry := NewRegistry()
+	enabled, disabled := true, false
+
+	registry.Update(config.Model{ID: "gpt-4-0613", SupportsVision: &enabled, SupportsTools: &disabled})
+	registry.Update(config.Model{ID: "local-model", Provider: "local", ContextWindow: 4096, MaxOutputTokens: 1024})
+
+	snapshot, _ := registry.Lookup("gpt-4-0613")
+	if !snapshot.SupportsVision || snapshot.SupportsTools || snapshot.ContextWindow != 8192 || !snapshot.SupportsStreaming {
+		t.Errorf("updated snapshot = %+v, want the overrides on top of gpt-4", snapshot)
+	}
+
+	// The family is left as it was.
+	if family, _ := registry.Lookup("gpt-4"); family.SupportsVision || !family.SupportsTools {
+		t.Errorf("gpt-4 = %+v, want the built-in capability", family)
+	}
+
+	local, ok := registry.Lookup("local-model")
+	if !ok || local != (Capability{Provider: "local", ContextWindow: 4096, MaxOutputTokens: 1024}) {
+		t.Errorf("Lookup() of a new model = %+v, %v", local, ok)
+	}
+
+	// Registries do not share the built-in capabilities.
+	if fresh, _ := NewRegistry().Lookup("gpt-4-0613"); fresh.SupportsVision {
+		t.Error("an update leaked into a new registry")
+	}
+}
+
+func TestCheckLimits(t *testing.T) {
+	capability := Capability{ContextWindow: 8192, MaxOutputTokens: 4096}
+
+	tests := []struct {
+		promptTokenLimit, completionTokenLimit int
+		err                                    error
+	}{
+		{promptTokenLimit: 4096, completionTokenLimit: 4096},
+		{promptTokenLimit: 6000, completionTokenLimit: 2000},
+		{promptTokenLimit: 6000, completionTokenLimit: 2193, err: ErrContextWindowExceeded},
+		{promptTokenLimit: 1000, completionTokenLimit: 5000, err: ErrMaxOutputTokensExceeded},
+	}
+
+	for _, tt := range tests {
+		if err := capability.CheckLimits(tt.promptTokenLimit, tt.completionTokenLimit); !errors.Is(err, tt.err) {
+			t.Errorf("CheckLimits(%d, %d) = %v, want %v", tt.promptTokenLimit, tt.completionTokenLimit, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package model
+
+// defaultCapabilities are the built-in capabilities. The Claude and Gemini models are served by
+// the providers named after their APIs, and the OpenAI models by the default provider.
+var defaultCapabilities = map[string]Capability{
+	"gpt-3.5-turbo": {
+		ContextWindow:      16385,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+		SupportsJSONMode:   true,
+	},
+	"gpt-3.5-turbo-0301": {
+		ContextWindow:      4096,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gpt-3.5-turbo-0613": {
+		ContextWindow:      4096,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+	},
+	"gpt-3.5-turbo-16k": {
+		ContextWindow:      16385,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+	},
+	"gpt-4": {
+		ContextWindow:      8192,
+		MaxOutputTokens:    8192,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+	},
+	"gpt-4-0314": {
+		ContextWindow:      8192,
+		MaxOutputTokens:    8192,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gpt-4-32k": {
+		ContextWindow:      32768,
+		MaxOutputTokens:    32768,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+	},
+	"gpt-4-32k-0314": {
+		ContextWindow:      32768,
+		MaxOutputTokens:    32768,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gpt-4-turbo": {
+		ContextWindow:      128000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsVision:     true,
+		SupportsTools:      true,
+		SupportsJSONMode:   true,
+	},
+	"gpt-4-1106-preview": {
+		ContextWindow:      128000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+		SupportsJSONMode:   true,
+	},
+	"gpt-4-0125-preview": {
+		ContextWindow:      128000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsTools:      true,
+		SupportsJSONMode:   true,
+	},
+	"gpt-4-vision-preview": {
+		ContextWindow:      128000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsVision:     true,
+	},
+	"gpt-4o": {
+		ContextWindow:      128000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+		SupportsVision:     true,
+		SupportsTools:      true,
+		SupportsJSONMode:   true,
+	},
+	"claude-3-opus": {
+		Provider:           "anthropic",
+		ContextWindow:      200000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"claude-3-sonnet": {
+		Provider:           "anthropic",
+		ContextWindow:      200000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"claude-3-haiku": {
+		Provider:           "anthropic",
+		ContextWindow:      200000,
+		MaxOutputTokens:    4096,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gemini-1.0-pro": {
+		Provider:           "gemini",
+		ContextWindow:      30720,
+		MaxOutputTokens:    2048,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gemini-1.5-pro": {
+		Provider:           "gemini",
+		ContextWindow:      1048576,
+		MaxOutputTokens:    8192,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+	"gemini-1.5-flash": {
+		Provider:           "gemini",
+		ContextWindow:      1048576,
+		MaxOutputTokens:    8192,
+		SupportsSystemRole: true,
+		SupportsStreaming:  true,
+	},
+}
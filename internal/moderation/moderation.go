@@ -0,0 +1,216 @@
+// Package moderation scores messages by category and decides whether they are allowed.
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+	"chatbot-gpt/internal/provider"
+)
+
+// AnyCategory is the category of the thresholds applying to the categories without their own.
+const AnyCategory = "*"
+
+var (
+	// ErrInvalidRule is returned when a rule has no category or an invalid pattern.
+	ErrInvalidRule = errors.New("invalid moderation rule")
+
+	// ErrInvalidThreshold is returned when a threshold is out of range or its category is repeated.
+	ErrInvalidThreshold = errors.New("invalid moderation threshold")
+)
+
+// Action is the action taken on a message.
+type Action int
+
+const (
+	// Allow lets the message through.
+	Allow Action = iota
+
+	// Warn lets the message through with a warning.
+	Warn
+
+	// Block refuses the message.
+	Block
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case Warn:
+		return "warn"
+	case Block:
+		return "block"
+	}
+
+	return "allow"
+}
+
+// Scores are the scores of a message from 0 to 1 by category.
+type Scores map[string]float64
+
+// Categories returns the categories of the scores, from the highest score.
+func (s Scores) Categories() []string {
+	categories := make([]string, 0, len(s))
+	for category := range s {
+		categories = append(categories, category)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		if s[categories[i]] != s[categories[j]] {
+			return s[categories[i]] > s[categories[j]]
+		}
+
+		return categories[i] < categories[j]
+	})
+
+	return categories
+}
+
+// rule scores the messages matching its pattern 1 in its category.
+type rule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// Moderator scores messages with a moderation endpoint and local rules.
+type Moderator struct {
+	provider provider.Provider
+	modelID  string
+	rules    []rule
+}
+
+// New creates a moderator with the rules, using the moderation endpoint of the provider
+// unless it is nil or the model is empty.
+func New(cfg config.Moderation, p provider.Provider) (*Moderator, error) {
+	m := &Moderator{provider: p, modelID: cfg.ModelID}
+	if cfg.ModelID == "" {
+		m.provider = nil
+	}
+
+	for _, ruleCfg := range cfg.Rules {
+		if ruleCfg.Category == "" || ruleCfg.Pattern == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRule, ruleCfg.Pattern)
+		}
+
+		pattern, err := regexp.Compile(ruleCfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidRule, err)
+		}
+
+		m.rules = append(m.rules, rule{category: ruleCfg.Category, pattern: pattern})
+	}
+
+	return m, nil
+}
+
+// Score scores the text by the rules and by the moderation endpoint, keeping the highest score of each category.
+func (m *Moderator) Score(ctx context.Context, text string) (Scores, error) {
+	scores := make(Scores)
+
+	for _, r := range m.rules {
+		if r.pattern.MatchString(text) {
+			scores[r.category] = 1
+		}
+	}
+
+	if m.provider == nil || text == "" {
+		return scores, nil
+	}
+
+	resp, err := m.provider.CreateModeration(ctx, openai.ModerationRequest{Input: text, Model: m.modelID})
+	if err != nil {
+		return scores, err
+	}
+
+	for _, result := range resp.Results {
+		// The scores are fields of a struct named by their JSON categories.
+		data, err := json.Marshal(result.CategoryScores)
+		if err != nil {
+			return scores, err
+		}
+
+		var categoryScores map[string]float64
+		if err := json.Unmarshal(data, &categoryScores); err != nil {
+			return scores, err
+		}
+
+		for category, score := range categoryScores {
+			scores[category] = max(scores[category], score)
+		}
+	}
+
+	return scores, nil
+}
+
+// Policy decides the action on a message by the thresholds of the categories.
+type Policy struct {
+	thresholds map[string]config.ModerationThreshold
+}
+
+// NewPolicy creates a policy of the thresholds.
+func NewPolicy(cfgs []config.ModerationThreshold) (Policy, error) {
+	p := Policy{thresholds: make(map[string]config.ModerationThreshold)}
+
+	for _, cfg := range cfgs {
+		if _, ok := p.thresholds[cfg.Category]; ok || cfg.Category == "" {
+			return Policy{}, fmt.Errorf("%w: category %q", ErrInvalidThreshold, cfg.Category)
+		}
+
+		if cfg.Warn < 0 || cfg.Warn > 1 || cfg.Block < 0 || cfg.Block > 1 {
+			return Policy{}, fmt.Errorf("%w: %s", ErrInvalidThreshold, cfg.Category)
+		}
+
+		p.thresholds[cfg.Category] = cfg
+	}
+
+	return p, nil
+}
+
+// Blocks reports whether any threshold of the policy blocks messages.
+func (p Policy) Blocks() bool {
+	for _, threshold := range p.thresholds {
+		if threshold.Block > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Decide returns the strictest action on the scores and the categories which reached it.
+func (p Policy) Decide(scores Scores) (Action, []string) {
+	action := Allow
+	var categories []string
+
+	for _, category := range scores.Categories() {
+		threshold, ok := p.thresholds[category]
+		if !ok {
+			threshold = p.thresholds[AnyCategory]
+		}
+
+		categoryAction := Allow
+		switch score := scores[category]; {
+		case threshold.Block > 0 && score >= threshold.Block:
+			categoryAction = Block
+		case threshold.Warn > 0 && score >= threshold.Warn:
+			categoryAction = Warn
+		}
+
+		if categoryAction > action {
+			action, categories = categoryAction, nil
+		}
+
+		if categoryAction == action && action != Allow {
+			categories = append(categories, category)
+		}
+	}
+
+	return action, categories
+}
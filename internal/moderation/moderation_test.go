@@ -0,0 +1,116 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"chatbot-gpt/internal/config"
+)
+
+func TestPolicyBlocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []config.ModerationThreshold
+		want       bool
+	}{
+		{name: "block", thresholds: []config.ModerationThreshold{{Category: AnyCategory, Warn: 0.5, Block: 0.8}}, want: true},
+		{name: "warn only", thresholds: []config.ModerationThreshold{{Category: "violence", Warn: 0.5}}, want: false},
+		{name: "none", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.thresholds)
+			if err != nil {
+				t.Fatalf("NewPolicy() error = %v", err)
+			}
+
+			if got := policy.Blocks(); got != tt.want {
+				t.Errorf("Blocks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyDecide(t *testing.T) {
+	policy, err := NewPolicy([]config.ModerationThreshold{
+		{Category: AnyCategory, Warn: 0.5, Block: 0.8},
+		{Category: "violence", Warn: 0.3, Block: 0.6},
+		{Category: "spam", Warn: 0.2},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		scores     Scores
+		action     Action
+		categories []string
+	}{
+		{name: "no scores", scores: nil, action: Allow},
+		{name: "below", scores: Scores{"hate": 0.49, "violence": 0.29}, action: Allow},
+		{name: "warn at the threshold", scores: Scores{"hate": 0.5}, action: Warn, categories: []string{"hate"}},
+		{name: "own threshold", scores: Scores{"hate": 0.6, "violence": 0.6}, action: Block, categories: []string{"violence"}},
+		{name: "any category", scores: Scores{"hate": 0.9, "violence": 0.1}, action: Block, categories: []string{"hate"}},
+		{name: "warn only", scores: Scores{"spam": 1}, action: Warn, categories: []string{"spam"}},
+		{
+			name:       "strictest action",
+			scores:     Scores{"spam": 1, "hate": 0.85, "violence": 0.7},
+			action:     Block,
+			categories: []string{"hate", "violence"},
+		},
+		{
+			name:       "categories of the action",
+			scores:     Scores{"spam": 0.3, "hate": 0.55, "violence": 0.1},
+			action:     Warn,
+			categories: []string{"hate", "spam"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, categories := policy.Decide(tt.scores)
+			if action != tt.action || !slices.Equal(categories, tt.categories) {
+				t.Errorf("Decide() = %s, %v, want %s, %v", action, categories, tt.action, tt.categories)
+			}
+		})
+	}
+}
+
+func TestNewPolicyInvalid(t *testing.T) {
+	tests := map[string][]config.ModerationThreshold{
+		"repeated category": {{Category: "hate", Warn: 0.5}, {Category: "hate", Block: 0.8}},
+		"empty category":    {{Warn: 0.5}},
+		"above one":         {{Category: "hate", Block: 1.5}},
+		"negative":          {{Category: "hate", Warn: -0.1}},
+	}
+
+	for name, thresholds := range tests {
+		if _, err := NewPolicy(thresholds); !errors.Is(err, ErrInvalidThreshold) {
+			t.Errorf("NewPolicy() of %s error = %v, want %v", name, err, ErrInvalidThreshold)
+		}
+	}
+}
+
+func TestScoreRules(t *testing.T) {
+	moderator, err := New(config.Moderation{Rules: []config.ModerationRule{
+		{Category: "spam", Pattern: `(?i)free nitro`},
+		{Category: "links", Pattern: `https?://`},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	scores, err := moderator.Score(context.Background(), "FREE NITRO here")
+	if err != nil || len(scores) != 1 || scores["spam"] != 1 {
+		t.Errorf("Score() = %v, %v, want spam scored 1", scores, err)
+	}
+
+	invalid := config.Moderation{Rules: []config.ModerationRule{{Category: "spam", Pattern: "("}}}
+	if _, err := New(invalid, nil); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("New() with an invalid pattern error = %v, want %v", err, ErrInvalidRule)
+	}
+}
@@ -0,0 +1,118 @@
+package prompt
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// User is the user sending the message.
+type User struct {
+	ID          string
+	Name        string
+	DisplayName string
+}
+
+// Guild is the server of the channel.
+type Guild struct {
+	ID   string
+	Name string
+}
+
+// Channel is the channel of the message.
+type Channel struct {
+	ID    string
+	Name  string
+	Topic string
+}
+
+// Data is the data available to the templates of system prompts.
+type Data struct {
+	Date     string
+	Time     string
+	Weekday  string
+	Timezone string
+	User     User
+	Guild    Guild
+	Channel  Channel
+
+	// loadPinnedMessages loads the text of the pinned messages of the channel.
+	loadPinnedMessages func() ([]string, error)
+	pinnedMessages     []string
+	pinnedLoaded       bool
+}
+
+// PinnedMessages returns the text of the pinned messages of the channel.
+// They are loaded only when the template uses them.
+func (d *Data) PinnedMessages() ([]string, error) {
+	if !d.pinnedLoaded && d.loadPinnedMessages != nil {
+		messages, err := d.loadPinnedMessages()
+		if err != nil {
+			return nil, err
+		}
+
+		d.pinnedMessages = messages
+		d.pinnedLoaded = true
+	}
+
+	return d.pinnedMessages, nil
+}
+
+// NewData creates the data of a request at the given time.
+// loadPinnedMessages is called at most once, when the template uses the pinned messages.
+func NewData(
+	now time.Time, user User, guild Guild, channel Channel, loadPinnedMessages func() ([]string, error),
+) *Data {
+	return &Data{
+		Date:               now.Format(time.DateOnly),
+		Time:               now.Format("15:04"),
+		Weekday:            now.Weekday().String(),
+		Timezone:           now.Location().String(),
+		User:               user,
+		Guild:              guild,
+		Channel:            channel,
+		loadPinnedMessages: loadPinnedMessages,
+	}
+}
+
+// sampleData returns the data used to validate templates.
+func sampleData() *Data {
+	return NewData(
+		time.Date(2023, time.April, 1, 12, 0, 0, 0, time.UTC),
+		User{ID: "1", Name: "user", DisplayName: "User"},
+		Guild{ID: "1", Name: "Server"},
+		Channel{ID: "1", Name: "chat", Topic: "Topic"},
+		func() ([]string, error) { return []string{"Pinned message"}, nil },
+	)
+}
+
+// Template is a template of a system prompt.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Execute renders the template with the data.
+func (t *Template) Execute(data *Data) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// Parse parses the template of a system prompt,
+// and renders it once with sample data to catch unknown variables.
+func Parse(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{tmpl: tmpl}
+	if _, err := t.Execute(sampleData()); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{name: "plain", text: "You are a helpful assistant."},
+		{name: "variables", text: "Today is {{.Weekday}} {{.Date}}. You talk to {{.User.DisplayName}} in #{{.Channel.Name}}."},
+		{name: "pinned messages", text: "{{range .PinnedMessages}}- {{.}}\n{{end}}"},
+		{name: "unknown field", text: "Hello {{.User.Nickname}}", wantErr: true},
+		{name: "unknown variable", text: "It is {{.Weather}}", wantErr: true},
+		{name: "syntax error", text: "Hello {{.User.Name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := Parse("persona", tt.text)
+			if (err != nil) != tt.wantErr || (tmpl == nil) != tt.wantErr {
+				t.Errorf("Parse(%q) = %v, %v, want error %v", tt.text, tmpl, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tmpl, err := Parse("persona", "{{.Weekday}} {{.Date}} {{.Time}} {{.Timezone}}\n"+
+		"{{.User.DisplayName}} ({{.User.Name}}) in {{.Guild.Name}} #{{.Channel.Name}}: {{.Channel.Topic}}\n"+
+		"{{range .PinnedMessages}}- {{.}}\n{{end}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	location := time.FixedZone("JST", 9*60*60)
+	loads := 0
+	data := NewData(
+		time.Date(2024, time.May, 3, 21, 5, 0, 0, location),
+		User{ID: "2", Name: "alice", DisplayName: "Alice"},
+		Guild{ID: "3", Name: "Guild"},
+		Channel{ID: "4", Name: "general", Topic: "Chat"},
+		func() ([]string, error) {
+			loads++
+			return []string{"Be kind", "No spoilers"}, nil
+		},
+	)
+
+	want := "Friday 2024-05-03 21:05 JST\nAlice (alice) in Guild #general: Chat\n- Be kind\n- No spoilers\n"
+	for i := 0; i < 2; i++ {
+		got, err := tmpl.Execute(data)
+		if err != nil || got != want {
+			t.Errorf("Execute() = %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if loads != 1 {
+		t.Errorf("pinned messages loaded %d times, want once", loads)
+	}
+}
+
+func TestExecuteLazyPinnedMessages(t *testing.T) {
+	tmpl, err := Parse("persona", "Hello {{.User.Name}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	errPins := errors.New("missing permissions")
+	data := NewData(time.Now(), User{Name: "bob"}, Guild{}, Channel{}, func() ([]string, error) {
+		return nil, errPins
+	})
+
+	// The pinned messages are not loaded by the templates which do not use them.
+	if got, err := tmpl.Execute(data); err != nil || got != "Hello bob" {
+		t.Errorf("Execute() = %q, %v, want %q", got, err, "Hello bob")
+	}
+
+	pinned, err := Parse("persona", "{{range .PinnedMessages}}{{.}}{{end}}")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if _, err := pinned.Execute(data); !errors.Is(err, errPins) {
+		t.Errorf("Execute() error = %v, want %v", err, errPins)
+	}
+}
@@ -0,0 +1,351 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+const (
+	anthropicDefaultBaseURL    = "https://api.anthropic.com"
+	anthropicDefaultAPIVersion = "2023-06-01"
+	anthropicDefaultMaxTokens  = 1024
+)
+
+// anthropicMessage is a message of the Anthropic Messages API.
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicRequest is a request of the Anthropic Messages API.
+type anthropicRequest struct {
+	Model         string             `json:"model"`
+	System        string             `json:"system,omitempty"`
+	Messages      []anthropicMessage `json:"messages"`
+	MaxTokens     int                `json:"max_tokens"`
+	Temperature   *float32           `json:"temperature,omitempty"`
+	TopP          *float32           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream,omitempty"`
+	Metadata      *anthropicMetadata `json:"metadata,omitempty"`
+}
+
+// anthropicMetadata is the metadata of an Anthropic request.
+type anthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// anthropicUsage is the token usage reported by the Anthropic Messages API.
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// anthropicResponse is a response of the Anthropic Messages API.
+type anthropicResponse struct {
+	ID         string `json:"id"`
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage anthropicUsage `json:"usage"`
+}
+
+// anthropicStreamEvent is an event of an Anthropic Messages stream.
+type anthropicStreamEvent struct {
+	Type    string            `json:"type"`
+	Message anthropicResponse `json:"message"`
+	Delta   struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Anthropic is a provider backed by the Anthropic Messages API.
+type Anthropic struct {
+	client     *http.Client
+	baseURL    string
+	apiVersion string
+	token      string
+}
+
+// CreateChatStream creates a chat completion stream.
+func (a *Anthropic) CreateChatStream(ctx context.Context, request openai.ChatCompletionRequest) (ChatStream, error) {
+	system, turns := toTurns(request.Messages)
+
+	body := anthropicRequest{
+		Model:         request.Model,
+		System:        system,
+		MaxTokens:     request.MaxTokens,
+		StopSequences: request.Stop,
+		Stream:        request.Stream,
+	}
+
+	if body.MaxTokens == 0 {
+		body.MaxTokens = anthropicDefaultMaxTokens
+	}
+
+	if request.Temperature != 0 {
+		body.Temperature = &request.Temperature
+	}
+
+	if request.TopP != 0 {
+		body.TopP = &request.TopP
+	}
+
+	if request.User != "" {
+		body.Metadata = &anthropicMetadata{UserID: request.User}
+	}
+
+	for _, t := range turns {
+		body.Messages = append(body.Messages, anthropicMessage{Role: t.Role, Content: t.Text})
+	}
+
+	if !request.Stream {
+		var resp anthropicResponse
+		if err := decodeJSON(ctx, a.client, http.MethodPost, a.baseURL+"/v1/messages", a.headers(), body, &resp); err != nil {
+			return nil, err
+		}
+
+		var text strings.Builder
+		for _, content := range resp.Content {
+			if content.Type == "text" {
+				text.WriteString(content.Text)
+			}
+		}
+
+		return &completionStream{
+			response: &openai.ChatCompletionResponse{
+				ID:    resp.ID,
+				Model: resp.Model,
+				Choices: []openai.ChatCompletionChoice{
+					{
+						Message: openai.ChatCompletionMessage{
+							Role:    openai.ChatMessageRoleAssistant,
+							Content: text.String(),
+						},
+						FinishReason: anthropicFinishReason(resp.StopReason),
+					},
+				},
+				Usage: openai.Usage{
+					PromptTokens:     resp.Usage.InputTokens,
+					CompletionTokens: resp.Usage.OutputTokens,
+					TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+				},
+			},
+		}, nil
+	}
+
+	resp, err := sendJSON(ctx, a.client, http.MethodPost, a.baseURL+"/v1/messages", a.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &anthropicStream{
+		body:   resp.Body,
+		reader: newSSEReader(resp.Body),
+	}, nil
+}
+
+// ListModels lists the models available to the provider.
+func (a *Anthropic) ListModels(ctx context.Context) ([]openai.Model, error) {
+	var resp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+
+	if err := decodeJSON(ctx, a.client, http.MethodGet, a.baseURL+"/v1/models?limit=1000", a.headers(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]openai.Model, 0, len(resp.Data))
+	for _, model := range resp.Data {
+		models = append(models, openai.Model{ID: model.ID, OwnedBy: "anthropic"})
+	}
+
+	return models, nil
+}
+
+// CreateEmbeddings is not supported by the Anthropic API.
+func (a *Anthropic) CreateEmbeddings(context.Context, openai.EmbeddingRequest) (openai.EmbeddingResponse, error) {
+	return openai.EmbeddingResponse{}, ErrNotSupported
+}
+
+// CreateTranscription is not supported by the Anthropic API.
+func (a *Anthropic) CreateTranscription(context.Context, openai.AudioRequest) (openai.AudioResponse, error) {
+	return openai.AudioResponse{}, ErrNotSupported
+}
+
+// CreateSpeech is not supported by the Anthropic API.
+func (a *Anthropic) CreateSpeech(context.Context, openai.CreateSpeechRequest) (io.ReadCloser, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateImage is not supported by the Anthropic API.
+func (a *Anthropic) CreateImage(context.Context, openai.ImageRequest) (openai.ImageResponse, error) {
+	return openai.ImageResponse{}, ErrNotSupported
+}
+
+// CreateModeration is not supported by the Anthropic API.
+func (a *Anthropic) CreateModeration(context.Context, openai.ModerationRequest) (openai.ModerationResponse, error) {
+	return openai.ModerationResponse{}, ErrNotSupported
+}
+
+// CheckSampling checks whether the sampling parameters are supported by the Anthropic Messages API.
+func (a *Anthropic) CheckSampling(sampling config.Sampling) error {
+	return anthropicSamplingRanges.check(sampling)
+}
+
+// headers returns the headers of the requests.
+func (a *Anthropic) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         a.token,
+		"anthropic-version": a.apiVersion,
+	}
+}
+
+// anthropicStream is a chat stream of the Anthropic Messages API.
+type anthropicStream struct {
+	body   io.ReadCloser
+	reader *sseReader
+	id     string
+	model  string
+	usage  openai.Usage
+	done   bool
+
+	// reported reports whether the final usage was received, which message_delta has.
+	reported bool
+}
+
+// Recv returns the next delta of the stream.
+func (a *anthropicStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	for {
+		if a.done {
+			return openai.ChatCompletionStreamResponse{}, io.EOF
+		}
+
+		event, err := a.reader.Next()
+		if err == io.EOF {
+			// The stream ends with message_stop, so it was cut off if the body ends before.
+			return openai.ChatCompletionStreamResponse{}, io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+
+		var data anthropicStreamEvent
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return openai.ChatCompletionStreamResponse{}, err
+		}
+
+		switch data.Type {
+		case "message_start":
+			a.id = data.Message.ID
+			a.model = data.Message.Model
+			a.usage.PromptTokens = data.Message.Usage.InputTokens
+		case "content_block_delta":
+			if data.Delta.Type == "text_delta" {
+				return a.response(openai.ChatCompletionStreamChoiceDelta{Content: data.Delta.Text}, ""), nil
+			}
+		case "message_delta":
+			a.usage.CompletionTokens = data.Usage.OutputTokens
+			a.usage.TotalTokens = a.usage.PromptTokens + a.usage.CompletionTokens
+			a.reported = true
+
+			if data.Delta.StopReason != "" {
+				return a.response(
+					openai.ChatCompletionStreamChoiceDelta{},
+					anthropicFinishReason(data.Delta.StopReason),
+				), nil
+			}
+		case "message_stop":
+			a.done = true
+		case "error":
+			return openai.ChatCompletionStreamResponse{}, &openai.APIError{
+				Type:    data.Error.Type,
+				Message: data.Error.Message,
+			}
+		}
+	}
+}
+
+// Close closes the stream.
+func (a *anthropicStream) Close() {
+	_ = a.body.Close()
+}
+
+// Usage returns the usage reported by the stream, or nil if the stream ended before its final usage.
+func (a *anthropicStream) Usage() *openai.Usage {
+	if !a.reported {
+		return nil
+	}
+
+	return &a.usage
+}
+
+// response builds a stream response of the given delta.
+func (a *anthropicStream) response(
+	delta openai.ChatCompletionStreamChoiceDelta, finishReason openai.FinishReason,
+) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		ID:    a.id,
+		Model: a.model,
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta:        delta,
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+// anthropicFinishReason converts an Anthropic stop reason to a finish reason.
+func anthropicFinishReason(stopReason string) openai.FinishReason {
+	switch stopReason {
+	case "end_turn", "stop_sequence":
+		return openai.FinishReasonStop
+	case "max_tokens":
+		return openai.FinishReasonLength
+	case "tool_use":
+		return openai.FinishReasonToolCalls
+	}
+
+	return openai.FinishReason(stopReason)
+}
+
+// newAnthropic creates a new Anthropic provider.
+func newAnthropic(cfg config.Provider) *Anthropic {
+	anthropic := &Anthropic{
+		client:     newHTTPClient(cfg),
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiVersion: cfg.APIVersion,
+		token:      cfg.Token,
+	}
+
+	if anthropic.baseURL == "" {
+		anthropic.baseURL = anthropicDefaultBaseURL
+	}
+
+	if anthropic.apiVersion == "" {
+		anthropic.apiVersion = anthropicDefaultAPIVersion
+	}
+
+	return anthropic
+}
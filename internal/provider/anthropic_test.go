@@ -0,0 +1,245 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+// recordingServer starts a server which records the last request body into v and responds with the body.
+func recordingServer(t *testing.T, v any, contentType, body string) (*httptest.Server, *http.Request) {
+	t.Helper()
+
+	var received http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = *r.Clone(context.Background())
+
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+			t.Errorf("failed to decode the request: %v", err)
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &received
+}
+
+// receiveAll receives the stream until it ends, returning the content and the finish reason.
+func receiveAll(stream ChatStream) (string, openai.FinishReason, error) {
+	defer stream.Close()
+
+	var content strings.Builder
+	var finishReason openai.FinishReason
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+
+			return content.String(), finishReason, err
+		}
+
+		for _, choice := range resp.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+		}
+	}
+}
+
+// testMessages are messages with a system prompt, an image, a tool call and its result.
+// The image and the tool call are left out of the turns, and the tool result is sent as text of the user.
+var testMessages = []openai.ChatCompletionMessage{
+	{Role: openai.ChatMessageRoleSystem, Content: "Be brief."},
+	{Role: openai.ChatMessageRoleAssistant, Content: "Skipped before the first user turn."},
+	{
+		Role: openai.ChatMessageRoleUser,
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "What is this?"},
+			{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{URL: "data:image/png;base64,AA"}},
+		},
+	},
+	{
+		Role:      openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{ID: "call_1", Type: openai.ToolTypeFunction}},
+	},
+	{Role: openai.ChatMessageRoleTool, Content: "A cat.", ToolCallID: "call_1"},
+	{Role: openai.ChatMessageRoleAssistant, Content: "A cat."},
+	{Role: openai.ChatMessageRoleUser, Content: "Thanks"},
+}
+
+func TestAnthropicRequest(t *testing.T) {
+	var body anthropicRequest
+	server, received := recordingServer(t, &body, "application/json", `{
+		"id": "msg_1", "model": "claude-3-haiku-20240307", "stop_reason": "max_tokens",
+		"content": [{"type": "text", "text": "Hello"}, {"type": "tool_use"}, {"type": "text", "text": " there"}],
+		"usage": {"input_tokens": 12, "output_tokens": 3}
+	}`)
+
+	anthropic := newAnthropic(config.Provider{Token: "key", BaseURL: server.URL + "/"})
+
+	stream, err := anthropic.CreateChatStream(context.Background(), openai.ChatCompletionRequest{
+		Model:       "claude-3-haiku-20240307",
+		Messages:    testMessages,
+		Temperature: 0.5,
+		Stop:        []string{"END"},
+		User:        "user",
+	})
+	if err != nil {
+		t.Fatalf("CreateChatStream() error = %v", err)
+	}
+
+	content, finishReason, err := receiveAll(stream)
+	if err != nil || content != "Hello there" || finishReason != openai.FinishReasonLength {
+		t.Errorf("received %q, %q, %v, want %q, %q", content, finishReason, err, "Hello there", openai.FinishReasonLength)
+	}
+
+	if usage := stream.Usage(); usage.PromptTokens != 12 || usage.CompletionTokens != 3 || usage.TotalTokens != 15 {
+		t.Errorf("Usage() = %+v, want 12 prompt and 3 completion tokens", usage)
+	}
+
+	if received.URL.Path != "/v1/messages" || received.Header.Get("x-api-key") != "key" ||
+		received.Header.Get("anthropic-version") != anthropicDefaultAPIVersion {
+		t.Errorf("request sent to %s with headers %v", received.URL.Path, received.Header)
+	}
+
+	want := []anthropicMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "What is this?\n\nA cat."},
+		{Role: openai.ChatMessageRoleAssistant, Content: "A cat."},
+		{Role: openai.ChatMessageRoleUser, Content: "Thanks"},
+	}
+
+	if body.System != "Be brief." || body.MaxTokens != anthropicDefaultMaxTokens || body.Stream ||
+		*body.Temperature != 0.5 || body.TopP != nil || body.StopSequences[0] != "END" || body.Metadata.UserID != "user" {
+		t.Errorf("request = %+v", body)
+	}
+
+	if len(body.Messages) != len(want) {
+		t.Fatalf("request messages = %+v, want %+v", body.Messages, want)
+	}
+
+	for i := range want {
+		if body.Messages[i] != want[i] {
+			t.Errorf("request message %d = %+v, want %+v", i, body.Messages[i], want[i])
+		}
+	}
+}
+
+func TestAnthropicStream(t *testing.T) {
+	tests := []struct {
+		name         string
+		events       string
+		content      string
+		finishReason openai.FinishReason
+		usage        *openai.Usage
+		err          error
+	}{
+		{
+			name: "complete",
+			events: "event: message_start\n" +
+				`data: {"type":"message_start","message":{"id":"msg_1","model":"claude","usage":{"input_tokens":10}}}` + "\n\n" +
+				": keep-alive\n\n" +
+				"event: content_block_start\n" +
+				`data: {"type":"content_block_start","index":0}` + "\n\n" +
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hel"}}` + "\n\n" +
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"lo"}}` + "\n\n" +
+				`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}` + "\n\n" +
+				`data: {"type":"message_stop"}` + "\n\n",
+			content:      "Hello",
+			finishReason: openai.FinishReasonStop,
+			usage:        &openai.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		},
+		{
+			name: "cut off",
+			events: `data: {"type":"message_start","message":{"usage":{"input_tokens":10}}}` + "\n\n" +
+				`data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hel"}}` + "\n\n",
+			content: "Hel",
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name:   "cut off before the start",
+			events: "event: ping\n" + `data: {"type":"ping"}` + "\n\n",
+			err:    io.ErrUnexpectedEOF,
+		},
+		{
+			name: "error",
+			events: `data: {"type":"content_block_delta","delta":{"type":"text_delta","text":"Hel"}}` + "\n\n" +
+				`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n",
+			content: "Hel",
+			err:     &openai.APIError{Type: "overloaded_error", Message: "Overloaded"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body anthropicRequest
+			server, _ := recordingServer(t, &body, "text/event-stream", tt.events)
+
+			anthropic := newAnthropic(config.Provider{BaseURL: server.URL})
+
+			stream, err := anthropic.CreateChatStream(context.Background(), openai.ChatCompletionRequest{
+				Model:    "claude",
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}},
+				Stream:   true,
+			})
+			if err != nil {
+				t.Fatalf("CreateChatStream() error = %v", err)
+			}
+
+			if !body.Stream {
+				t.Error("the request is not streamed")
+			}
+
+			content, finishReason, err := receiveAll(stream)
+			if content != tt.content || finishReason != tt.finishReason {
+				t.Errorf("received %q, %q, want %q, %q", content, finishReason, tt.content, tt.finishReason)
+			}
+
+			var apiErr, wantAPIErr *openai.APIError
+			if errors.As(tt.err, &wantAPIErr) {
+				if !errors.As(err, &apiErr) || *apiErr != *wantAPIErr {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+			} else if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+
+			// The usage of a stream cut off before its final usage is left to be predicted.
+			if usage := stream.Usage(); (usage == nil) != (tt.usage == nil) || usage != nil && *usage != *tt.usage {
+				t.Errorf("Usage() = %+v, want %+v", usage, tt.usage)
+			}
+		})
+	}
+}
+
+func TestAnthropicError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"type":"error","error":{"type":"invalid_request_error","message":"Bad model"}}`)
+	}))
+	defer server.Close()
+
+	_, err := newAnthropic(config.Provider{BaseURL: server.URL}).CreateChatStream(
+		context.Background(), openai.ChatCompletionRequest{Model: "unknown", Stream: true},
+	)
+
+	var apiErr *openai.APIError
+	if !errors.As(err, &apiErr) || apiErr.HTTPStatusCode != http.StatusBadRequest ||
+		apiErr.Type != "invalid_request_error" || apiErr.Message != "Bad model" {
+		t.Errorf("CreateChatStream() error = %v, want the invalid request error", err)
+	}
+}
@@ -0,0 +1,46 @@
+package provider
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// transientErrorTypes are the error types reported by providers when they are overloaded or rate limited.
+var transientErrorTypes = map[string]bool{
+	"rate_limit_error":   true,
+	"overloaded_error":   true,
+	"api_error":          true,
+	"server_error":       true,
+	"RESOURCE_EXHAUSTED": true,
+	"UNAVAILABLE":        true,
+	"INTERNAL":           true,
+}
+
+// isTransientStatusCode reports whether the HTTP status code indicates a transient failure.
+func isTransientStatusCode(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// IsTransient reports whether the error is caused by a server error, an overloaded provider,
+// a rate limit or the network, so that the request may succeed later or with another model.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return isTransientStatusCode(apiErr.HTTPStatusCode) || transientErrorTypes[apiErr.Type]
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return isTransientStatusCode(requestErr.HTTPStatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,427 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+const (
+	geminiDefaultBaseURL    = "https://generativelanguage.googleapis.com"
+	geminiDefaultAPIVersion = "v1beta"
+)
+
+// geminiPart is a part of a Gemini content.
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent is a content of the Gemini API.
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiGenerationConfig is the generation config of a Gemini request.
+type geminiGenerationConfig struct {
+	MaxOutputTokens  int      `json:"maxOutputTokens,omitempty"`
+	Temperature      *float32 `json:"temperature,omitempty"`
+	TopP             *float32 `json:"topP,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
+	PresencePenalty  float32  `json:"presencePenalty,omitempty"`
+	FrequencyPenalty float32  `json:"frequencyPenalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+}
+
+// geminiRequest is a request of the Gemini generateContent API.
+type geminiRequest struct {
+	Contents          []geminiContent        `json:"contents"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiResponse is a response, or a chunk of a streamed response, of the Gemini generateContent API.
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// usage returns the usage of the response.
+func (g *geminiResponse) usage() openai.Usage {
+	return openai.Usage{
+		PromptTokens:     g.UsageMetadata.PromptTokenCount,
+		CompletionTokens: g.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      g.UsageMetadata.TotalTokenCount,
+	}
+}
+
+// choice returns the text and the finish reason of the first candidate.
+func (g *geminiResponse) choice() (string, openai.FinishReason) {
+	if len(g.Candidates) == 0 {
+		return "", ""
+	}
+
+	var text strings.Builder
+	for _, part := range g.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+
+	return text.String(), geminiFinishReason(g.Candidates[0].FinishReason)
+}
+
+// Gemini is a provider backed by the Google Gemini API.
+type Gemini struct {
+	client     *http.Client
+	baseURL    string
+	apiVersion string
+	token      string
+}
+
+// CreateChatStream creates a chat completion stream.
+func (g *Gemini) CreateChatStream(ctx context.Context, request openai.ChatCompletionRequest) (ChatStream, error) {
+	system, turns := toTurns(request.Messages)
+
+	body := geminiRequest{
+		GenerationConfig: geminiGenerationConfig{
+			MaxOutputTokens:  request.MaxTokens,
+			StopSequences:    request.Stop,
+			PresencePenalty:  request.PresencePenalty,
+			FrequencyPenalty: request.FrequencyPenalty,
+			Seed:             request.Seed,
+		},
+	}
+
+	if system != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+
+	if request.Temperature != 0 {
+		body.GenerationConfig.Temperature = &request.Temperature
+	}
+
+	if request.TopP != 0 {
+		body.GenerationConfig.TopP = &request.TopP
+	}
+
+	for _, t := range turns {
+		role := "user"
+		if t.Role == openai.ChatMessageRoleAssistant {
+			role = "model"
+		}
+
+		body.Contents = append(body.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: t.Text}}})
+	}
+
+	if !request.Stream {
+		var resp geminiResponse
+		if err := decodeJSON(
+			ctx, g.client, http.MethodPost, g.modelURL(request.Model, "generateContent"), g.headers(), body, &resp,
+		); err != nil {
+			return nil, err
+		}
+
+		text, finishReason := resp.choice()
+
+		return &completionStream{
+			response: &openai.ChatCompletionResponse{
+				Model: request.Model,
+				Choices: []openai.ChatCompletionChoice{
+					{
+						Message: openai.ChatCompletionMessage{
+							Role:    openai.ChatMessageRoleAssistant,
+							Content: text,
+						},
+						FinishReason: finishReason,
+					},
+				},
+				Usage: resp.usage(),
+			},
+		}, nil
+	}
+
+	resp, err := sendJSON(
+		ctx, g.client, http.MethodPost, g.modelURL(request.Model, "streamGenerateContent")+"?alt=sse", g.headers(), body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &geminiStream{
+		body:   resp.Body,
+		reader: newSSEReader(resp.Body),
+		model:  request.Model,
+	}, nil
+}
+
+// ListModels lists the models available to the provider.
+func (g *Gemini) ListModels(ctx context.Context) ([]openai.Model, error) {
+	var models []openai.Model
+	pageToken := ""
+
+	for {
+		var resp struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+
+		listURL := fmt.Sprintf("%s/%s/models?pageSize=1000", g.baseURL, g.apiVersion)
+		if pageToken != "" {
+			listURL += "&pageToken=" + url.QueryEscape(pageToken)
+		}
+
+		if err := decodeJSON(ctx, g.client, http.MethodGet, listURL, g.headers(), nil, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, model := range resp.Models {
+			models = append(models, openai.Model{ID: strings.TrimPrefix(model.Name, "models/"), OwnedBy: "google"})
+		}
+
+		if resp.NextPageToken == "" {
+			return models, nil
+		}
+
+		pageToken = resp.NextPageToken
+	}
+}
+
+// CreateEmbeddings creates embeddings for the input.
+func (g *Gemini) CreateEmbeddings(
+	ctx context.Context,
+	request openai.EmbeddingRequest,
+) (openai.EmbeddingResponse, error) {
+	var inputs []string
+	switch input := request.Input.(type) {
+	case string:
+		inputs = []string{input}
+	case []string:
+		inputs = input
+	default:
+		return openai.EmbeddingResponse{}, ErrNotSupported
+	}
+
+	type embedRequest struct {
+		Model   string        `json:"model"`
+		Content geminiContent `json:"content"`
+	}
+
+	body := struct {
+		Requests []embedRequest `json:"requests"`
+	}{}
+
+	for _, input := range inputs {
+		body.Requests = append(body.Requests, embedRequest{
+			Model:   "models/" + string(request.Model),
+			Content: geminiContent{Parts: []geminiPart{{Text: input}}},
+		})
+	}
+
+	var resp struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+	}
+
+	if err := decodeJSON(
+		ctx, g.client, http.MethodPost, g.modelURL(string(request.Model), "batchEmbedContents"), g.headers(), body, &resp,
+	); err != nil {
+		return openai.EmbeddingResponse{}, err
+	}
+
+	result := openai.EmbeddingResponse{Object: "list", Model: request.Model}
+	for i, embedding := range resp.Embeddings {
+		result.Data = append(result.Data, openai.Embedding{
+			Object:    "embedding",
+			Embedding: embedding.Values,
+			Index:     i,
+		})
+	}
+
+	return result, nil
+}
+
+// CreateTranscription is not supported by the Gemini API.
+func (g *Gemini) CreateTranscription(context.Context, openai.AudioRequest) (openai.AudioResponse, error) {
+	return openai.AudioResponse{}, ErrNotSupported
+}
+
+// CreateSpeech is not supported by the Gemini API.
+func (g *Gemini) CreateSpeech(context.Context, openai.CreateSpeechRequest) (io.ReadCloser, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateImage is not supported by the Gemini API.
+func (g *Gemini) CreateImage(context.Context, openai.ImageRequest) (openai.ImageResponse, error) {
+	return openai.ImageResponse{}, ErrNotSupported
+}
+
+// CreateModeration is not supported by the Gemini API.
+func (g *Gemini) CreateModeration(context.Context, openai.ModerationRequest) (openai.ModerationResponse, error) {
+	return openai.ModerationResponse{}, ErrNotSupported
+}
+
+// CheckSampling checks whether the sampling parameters are supported by the Gemini API.
+func (g *Gemini) CheckSampling(sampling config.Sampling) error {
+	return geminiSamplingRanges.check(sampling)
+}
+
+// modelURL returns the URL of a method of the model.
+func (g *Gemini) modelURL(model, method string) string {
+	return fmt.Sprintf("%s/%s/models/%s:%s", g.baseURL, g.apiVersion, url.PathEscape(model), method)
+}
+
+// headers returns the headers of the requests.
+func (g *Gemini) headers() map[string]string {
+	return map[string]string{
+		"x-goog-api-key": g.token,
+	}
+}
+
+// geminiStream is a chat stream of the Gemini API.
+type geminiStream struct {
+	body   io.ReadCloser
+	reader *sseReader
+	model  string
+	usage  openai.Usage
+
+	// finished reports whether a finish reason was received, which the last chunk of the stream has.
+	finished bool
+
+	// reported reports whether the usage of the last chunk was received.
+	reported bool
+
+	// pendingFinishReason is the finish reason received along with the last content,
+	// which is sent in a separate delta like the OpenAI API does.
+	pendingFinishReason openai.FinishReason
+}
+
+// Recv returns the next delta of the stream.
+func (g *geminiStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if g.pendingFinishReason != "" {
+		finishReason := g.pendingFinishReason
+		g.pendingFinishReason = ""
+
+		return g.response("", finishReason), nil
+	}
+
+	event, err := g.reader.Next()
+	if err == io.EOF && !g.finished {
+		return openai.ChatCompletionStreamResponse{}, io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return openai.ChatCompletionStreamResponse{}, err
+	}
+
+	// Errors in the middle of the stream are sent as an event of their own.
+	var errResp errorResponse
+	if json.Unmarshal(event.Data, &errResp) == nil && errResp.Error.Message != "" {
+		return openai.ChatCompletionStreamResponse{}, &openai.APIError{
+			Type:    errResp.Error.Status,
+			Message: errResp.Error.Message,
+		}
+	}
+
+	var chunk geminiResponse
+	if err := json.Unmarshal(event.Data, &chunk); err != nil {
+		return openai.ChatCompletionStreamResponse{}, err
+	}
+
+	text, finishReason := chunk.choice()
+	if finishReason != "" {
+		g.finished = true
+	}
+
+	// The usage of the chunks before the last one only counts the tokens generated so far.
+	if chunk.UsageMetadata.TotalTokenCount > 0 {
+		g.usage = chunk.usage()
+		g.reported = g.finished
+	}
+
+	if text != "" && finishReason != "" {
+		g.pendingFinishReason = finishReason
+		finishReason = ""
+	}
+
+	return g.response(text, finishReason), nil
+}
+
+// response builds a stream response of the given delta.
+func (g *geminiStream) response(text string, finishReason openai.FinishReason) openai.ChatCompletionStreamResponse {
+	return openai.ChatCompletionStreamResponse{
+		Model: g.model,
+		Choices: []openai.ChatCompletionStreamChoice{
+			{
+				Delta:        openai.ChatCompletionStreamChoiceDelta{Content: text},
+				FinishReason: finishReason,
+			},
+		},
+	}
+}
+
+// Close closes the stream.
+func (g *geminiStream) Close() {
+	_ = g.body.Close()
+}
+
+// Usage returns the usage reported by the stream, or nil if the stream ended before its last chunk.
+func (g *geminiStream) Usage() *openai.Usage {
+	if !g.reported {
+		return nil
+	}
+
+	return &g.usage
+}
+
+// geminiFinishReason converts a Gemini finish reason to a finish reason.
+func geminiFinishReason(reason string) openai.FinishReason {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		return openai.FinishReasonStop
+	case "MAX_TOKENS":
+		return openai.FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return openai.FinishReasonContentFilter
+	}
+
+	return openai.FinishReason(strings.ToLower(reason))
+}
+
+// newGemini creates a new Gemini provider.
+func newGemini(cfg config.Provider) *Gemini {
+	gemini := &Gemini{
+		client:     newHTTPClient(cfg),
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiVersion: cfg.APIVersion,
+		token:      cfg.Token,
+	}
+
+	if gemini.baseURL == "" {
+		gemini.baseURL = geminiDefaultBaseURL
+	}
+
+	if gemini.apiVersion == "" {
+		gemini.apiVersion = geminiDefaultAPIVersion
+	}
+
+	return gemini
+}
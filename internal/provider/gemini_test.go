@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+func TestGeminiRequest(t *testing.T) {
+	var body geminiRequest
+	server, received := recordingServer(t, &body, "application/json", `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello"}, {"text": " there"}]}, "finishReason": "SAFETY"}],
+		"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 3, "totalTokenCount": 15}
+	}`)
+
+	gemini := newGemini(config.Provider{Token: "key", BaseURL: server.URL})
+
+	seed := 42
+	stream, err := gemini.CreateChatStream(context.Background(), openai.ChatCompletionRequest{
+		Model:     "gemini-1.5-flash",
+		Messages:  testMessages,
+		MaxTokens: 100,
+		TopP:      0.9,
+		Seed:      &seed,
+	})
+	if err != nil {
+		t.Fatalf("CreateChatStream() error = %v", err)
+	}
+
+	content, finishReason, err := receiveAll(stream)
+	if err != nil || content != "Hello there" || finishReason != openai.FinishReasonContentFilter {
+		t.Errorf("received %q, %q, %v, want %q, %q", content, finishReason, err, "Hello there", openai.FinishReasonContentFilter)
+	}
+
+	if usage := stream.Usage(); *usage != (openai.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}) {
+		t.Errorf("Usage() = %+v, want 12 prompt and 3 completion tokens", *usage)
+	}
+
+	if received.URL.Path != "/v1beta/models/gemini-1.5-flash:generateContent" ||
+		received.Header.Get("x-goog-api-key") != "key" {
+		t.Errorf("request sent to %s with headers %v", received.URL.Path, received.Header)
+	}
+
+	if body.SystemInstruction == nil || body.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("system instruction = %+v, want %q", body.SystemInstruction, "Be brief.")
+	}
+
+	config := body.GenerationConfig
+	if config.MaxOutputTokens != 100 || config.Temperature != nil || *config.TopP != 0.9 || *config.Seed != seed {
+		t.Errorf("generation config = %+v", config)
+	}
+
+	want := []geminiContent{
+		{Role: "user", Parts: []geminiPart{{Text: "What is this?\n\nA cat."}}},
+		{Role: "model", Parts: []geminiPart{{Text: "A cat."}}},
+		{Role: "user", Parts: []geminiPart{{Text: "Thanks"}}},
+	}
+
+	if len(body.Contents) != len(want) {
+		t.Fatalf("request contents = %+v, want %+v", body.Contents, want)
+	}
+
+	for i := range want {
+		if got := body.Contents[i]; got.Role != want[i].Role || len(got.Parts) != 1 || got.Parts[0] != want[i].Parts[0] {
+			t.Errorf("request content %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestGeminiStream(t *testing.T) {
+	tests := []struct {
+		name         string
+		events       string
+		content      string
+		finishReason openai.FinishReason
+		usage        *openai.Usage
+		err          error
+	}{
+		{
+			name: "complete",
+			events: `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n" +
+				`data: {"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"MAX_TOKENS"}],` +
+				`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":2,"totalTokenCount":12}}` + "\n\n",
+			content:      "Hello",
+			finishReason: openai.FinishReasonLength,
+			usage:        &openai.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		},
+		{
+			name:    "cut off",
+			events:  `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n",
+			content: "Hel",
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name: "cut off with partial usage",
+			events: `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}],` +
+				`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":1,"totalTokenCount":11}}` + "\n\n",
+			content: "Hel",
+			err:     io.ErrUnexpectedEOF,
+		},
+		{
+			name: "error",
+			events: `data: {"candidates":[{"content":{"parts":[{"text":"Hel"}]}}]}` + "\n\n" +
+				`data: {"error":{"code":503,"status":"UNAVAILABLE","message":"Overloaded"}}` + "\n\n",
+			content: "Hel",
+			err:     &openai.APIError{Type: "UNAVAILABLE", Message: "Overloaded"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body geminiRequest
+			server, received := recordingServer(t, &body, "text/event-stream", tt.events)
+
+			stream, err := newGemini(config.Provider{BaseURL: server.URL}).CreateChatStream(
+				context.Background(), openai.ChatCompletionRequest{
+					Model:    "gemini-1.5-flash",
+					Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}},
+					Stream:   true,
+				},
+			)
+			if err != nil {
+				t.Fatalf("CreateChatStream() error = %v", err)
+			}
+
+			if received.URL.Path != "/v1beta/models/gemini-1.5-flash:streamGenerateContent" ||
+				received.URL.Query().Get("alt") != "sse" {
+				t.Errorf("request sent to %s", received.URL)
+			}
+
+			content, finishReason, err := receiveAll(stream)
+			if content != tt.content || finishReason != tt.finishReason {
+				t.Errorf("received %q, %q, want %q, %q", content, finishReason, tt.content, tt.finishReason)
+			}
+
+			var apiErr, wantAPIErr *openai.APIError
+			if errors.As(tt.err, &wantAPIErr) {
+				if !errors.As(err, &apiErr) || *apiErr != *wantAPIErr {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+			} else if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+
+			// The usage of a stream cut off before its final usage is left to be predicted.
+			if usage := stream.Usage(); (usage == nil) != (tt.usage == nil) || usage != nil && *usage != *tt.usage {
+				t.Errorf("Usage() = %+v, want %+v", usage, tt.usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// errorResponse is the error body shared by the Anthropic and Gemini APIs.
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// sendJSON sends a request with a JSON body and returns the response if it succeeded.
+// Failed responses are converted to *openai.APIError so that they are handled like OpenAI errors.
+func sendJSON(
+	ctx context.Context, client *http.Client, method, url string, headers map[string]string, body any,
+) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+
+	apiErr := &openai.APIError{HTTPStatusCode: resp.StatusCode}
+
+	data, _ := io.ReadAll(resp.Body)
+	var errResp errorResponse
+	if json.Unmarshal(data, &errResp) == nil && errResp.Error.Message != "" {
+		apiErr.Message = errResp.Error.Message
+		apiErr.Type = errResp.Error.Type
+		if apiErr.Type == "" {
+			apiErr.Type = errResp.Error.Status
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+
+	return nil, apiErr
+}
+
+// decodeJSON sends a request with a JSON body and decodes the JSON response into v.
+func decodeJSON(
+	ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, v any,
+) error {
+	resp, err := sendJSON(ctx, client, method, url, headers, body)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
+
+// turn is a message of a conversation that strictly alternates between the user and the assistant.
+type turn struct {
+	Role string
+	Text string
+}
+
+// messageText returns the text content of a message.
+func messageText(message openai.ChatCompletionMessage) string {
+	if len(message.MultiContent) == 0 {
+		return message.Content
+	}
+
+	var texts []string
+	for _, part := range message.MultiContent {
+		if part.Type == openai.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+
+	return strings.Join(texts, "\n\n")
+}
+
+// toTurns splits the messages into the system prompt and turns that start with the user
+// and alternate between the user and the assistant, as required by the Anthropic and Gemini APIs.
+// Consecutive messages of the same role are merged, and empty messages are skipped.
+func toTurns(messages []openai.ChatCompletionMessage) (string, []turn) {
+	var systemPrompts []string
+	var turns []turn
+
+	for _, message := range messages {
+		text := messageText(message)
+		if text == "" {
+			continue
+		}
+
+		role := openai.ChatMessageRoleUser
+		switch message.Role {
+		case openai.ChatMessageRoleSystem:
+			systemPrompts = append(systemPrompts, text)
+			continue
+		case openai.ChatMessageRoleAssistant:
+			role = openai.ChatMessageRoleAssistant
+		}
+
+		if len(turns) == 0 && role != openai.ChatMessageRoleUser {
+			continue
+		}
+
+		if len(turns) > 0 && turns[len(turns)-1].Role == role {
+			turns[len(turns)-1].Text += "\n\n" + text
+			continue
+		}
+
+		turns = append(turns, turn{Role: role, Text: text})
+	}
+
+	return strings.Join(systemPrompts, "\n\n"), turns
+}
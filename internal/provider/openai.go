@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+// OpenAI is a provider backed by the OpenAI API or a compatible one.
+type OpenAI struct {
+	client *openai.Client
+
+	// includeUsage requests the usage at the end of the streams, which only the official API is known to support.
+	includeUsage bool
+}
+
+// CreateChatStream creates a chat completion stream.
+func (o *OpenAI) CreateChatStream(ctx context.Context, request openai.ChatCompletionRequest) (ChatStream, error) {
+	if !request.Stream {
+		response, err := o.client.CreateChatCompletion(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+
+		return &completionStream{response: &response}, nil
+	}
+
+	if o.includeUsage {
+		request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	stream, err := o.client.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &openAIStream{stream: stream}, nil
+}
+
+// ListModels lists the models available to the provider.
+func (o *OpenAI) ListModels(ctx context.Context) ([]openai.Model, error) {
+	result, err := o.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Models, nil
+}
+
+// CreateEmbeddings creates embeddings for the input.
+func (o *OpenAI) CreateEmbeddings(
+	ctx context.Context,
+	request openai.EmbeddingRequest,
+) (openai.EmbeddingResponse, error) {
+	return o.client.CreateEmbeddings(ctx, request)
+}
+
+// CreateTranscription transcribes the audio of the request into text.
+func (o *OpenAI) CreateTranscription(ctx context.Context, request openai.AudioRequest) (openai.AudioResponse, error) {
+	return o.client.CreateTranscription(ctx, request)
+}
+
+// CreateSpeech synthesizes the speech of the request, returning the audio file.
+func (o *OpenAI) CreateSpeech(ctx context.Context, request openai.CreateSpeechRequest) (io.ReadCloser, error) {
+	return o.client.CreateSpeech(ctx, request)
+}
+
+// CreateImage generates the images of the request.
+func (o *OpenAI) CreateImage(ctx context.Context, request openai.ImageRequest) (openai.ImageResponse, error) {
+	return o.client.CreateImage(ctx, request)
+}
+
+// CreateModeration scores the input of the request by moderation category.
+func (o *OpenAI) CreateModeration(
+	ctx context.Context, request openai.ModerationRequest,
+) (openai.ModerationResponse, error) {
+	return o.client.Moderations(ctx, request)
+}
+
+// CheckSampling checks whether the sampling parameters are within the ranges of the OpenAI API.
+func (o *OpenAI) CheckSampling(sampling config.Sampling) error {
+	return openAISamplingRanges.check(sampling)
+}
+
+// newOpenAI creates a new OpenAI provider.
+func newOpenAI(cfg config.Provider, clientConfig openai.ClientConfig) *OpenAI {
+	if cfg.BaseURL != "" && clientConfig.APIType == openai.APITypeOpenAI {
+		clientConfig.BaseURL = cfg.BaseURL
+	}
+
+	if cfg.APIVersion != "" {
+		clientConfig.APIVersion = cfg.APIVersion
+	}
+
+	clientConfig.OrgID = cfg.OrgID
+	clientConfig.HTTPClient = newHTTPClient(cfg)
+
+	return &OpenAI{
+		client:       openai.NewClientWithConfig(clientConfig),
+		includeUsage: cfg.Type == "" || cfg.Type == TypeOpenAI,
+	}
+}
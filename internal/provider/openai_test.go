@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+func TestOpenAIStreamUsage(t *testing.T) {
+	events := `data: {"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}` + "\n\n" +
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}` + "\n\n" +
+		`data: {"id":"1","choices":[],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}` + "\n\n" +
+		"data: [DONE]\n\n"
+
+	tests := []struct {
+		name         string
+		providerType string
+		includeUsage bool
+	}{
+		{name: "official", providerType: TypeOpenAI, includeUsage: true},
+		{name: "compatible", providerType: TypeOpenAICompatible, includeUsage: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body openai.ChatCompletionRequest
+			server, _ := recordingServer(t, &body, "text/event-stream", events)
+
+			p, err := New(config.Provider{Type: tt.providerType, Token: "key", BaseURL: server.URL + "/v1"})
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			stream, err := p.CreateChatStream(context.Background(), openai.ChatCompletionRequest{
+				Model:    "gpt-4o",
+				Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: "Hi"}},
+				Stream:   true,
+			})
+			if err != nil {
+				t.Fatalf("CreateChatStream() error = %v", err)
+			}
+
+			content, finishReason, err := receiveAll(stream)
+			if err != nil || content != "Hello" || finishReason != openai.FinishReasonStop {
+				t.Errorf("received %q, %q, %v, want %q, %q", content, finishReason, err, "Hello", openai.FinishReasonStop)
+			}
+
+			if requested := body.StreamOptions != nil && body.StreamOptions.IncludeUsage; requested != tt.includeUsage {
+				t.Errorf("usage requested = %v, want %v", requested, tt.includeUsage)
+			}
+
+			// The usage is reported by the server anyway, and kept whether it was requested or not.
+			if usage := stream.Usage(); usage == nil || *usage != (openai.Usage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11}) {
+				t.Errorf("Usage() = %+v, want 9 prompt and 2 completion tokens", usage)
+			}
+		})
+	}
+}
+
+func TestOpenAIStreamWithoutUsage(t *testing.T) {
+	var body openai.ChatCompletionRequest
+	server, _ := recordingServer(t, &body, "text/event-stream",
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":"stop"}]}`+"\n\n"+
+			"data: [DONE]\n\n",
+	)
+
+	stream, err := newOpenAI(config.Provider{BaseURL: server.URL}, openai.DefaultConfig("key")).CreateChatStream(
+		context.Background(), openai.ChatCompletionRequest{Model: "gpt-4o", Stream: true},
+	)
+	if err != nil {
+		t.Fatalf("CreateChatStream() error = %v", err)
+	}
+
+	if _, _, err := receiveAll(stream); err != nil {
+		t.Fatalf("received error = %v", err)
+	}
+
+	// The callers predict the tokens of the streams without usage.
+	if usage := stream.Usage(); usage != nil {
+		t.Errorf("Usage() = %+v, want nil", usage)
+	}
+}
@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"chatbot-gpt/internal/config"
+)
+
+const (
+	// TypeOpenAI is the type of the official OpenAI API.
+	TypeOpenAI = "openai"
+
+	// TypeAzure is the type of the Azure OpenAI Service.
+	TypeAzure = "azure"
+
+	// TypeOpenAICompatible is the type of servers that implement the OpenAI API, such as Ollama or llama.cpp.
+	TypeOpenAICompatible = "openai-compatible"
+
+	// TypeAnthropic is the type of the Anthropic Messages API.
+	TypeAnthropic = "anthropic"
+
+	// TypeGemini is the type of the Google Gemini API.
+	TypeGemini = "gemini"
+)
+
+var (
+	// ErrUnknownType is returned when the provider type is unknown.
+	ErrUnknownType = errors.New("unknown provider type")
+
+	// ErrNotSupported is returned when the provider does not support the operation.
+	ErrNotSupported = errors.New("operation is not supported by this provider")
+
+	// ErrMissingBaseURL is returned when the provider type requires a base URL but none is configured.
+	ErrMissingBaseURL = errors.New("base url is required for this provider type")
+
+	// ErrOutOfRange is returned when a sampling parameter is out of the range accepted by the provider.
+	ErrOutOfRange = errors.New("sampling parameter is out of range")
+)
+
+// ChatStream is a stream of chat completion deltas.
+type ChatStream interface {
+	Recv() (openai.ChatCompletionStreamResponse, error)
+	Close()
+
+	// Usage returns the token usage reported by the provider, or nil if it is not reported.
+	// It is only meaningful after the stream has been fully received.
+	Usage() *openai.Usage
+}
+
+// Provider is a provider of chat models.
+type Provider interface {
+	// CreateChatStream creates a chat completion stream.
+	// If request.Stream is false, the completion is requested at once and replayed as a stream.
+	CreateChatStream(ctx context.Context, request openai.ChatCompletionRequest) (ChatStream, error)
+	ListModels(ctx context.Context) ([]openai.Model, error)
+	CreateEmbeddings(ctx context.Context, request openai.EmbeddingRequest) (openai.EmbeddingResponse, error)
+
+	// CreateTranscription transcribes the audio of the request into text.
+	CreateTranscription(ctx context.Context, request openai.AudioRequest) (openai.AudioResponse, error)
+
+	// CreateSpeech synthesizes the speech of the request, returning the audio file.
+	CreateSpeech(ctx context.Context, request openai.CreateSpeechRequest) (io.ReadCloser, error)
+
+	// CreateImage generates the images of the request.
+	CreateImage(ctx context.Context, request openai.ImageRequest) (openai.ImageResponse, error)
+
+	// CreateModeration scores the input of the request by moderation category.
+	CreateModeration(ctx context.Context, request openai.ModerationRequest) (openai.ModerationResponse, error)
+
+	// CheckSampling checks whether the sampling parameters are supported and within the ranges of the provider.
+	CheckSampling(sampling config.Sampling) error
+}
+
+// New creates a new provider from the configuration.
+func New(cfg config.Provider) (Provider, error) {
+	switch cfg.Type {
+	case "", TypeOpenAI:
+		return newOpenAI(cfg, openai.DefaultConfig(cfg.Token)), nil
+	case TypeOpenAICompatible:
+		if cfg.BaseURL == "" {
+			return nil, ErrMissingBaseURL
+		}
+
+		return newOpenAI(cfg, openai.DefaultConfig(cfg.Token)), nil
+	case TypeAzure:
+		if cfg.BaseURL == "" {
+			return nil, ErrMissingBaseURL
+		}
+
+		clientConfig := openai.DefaultAzureConfig(cfg.Token, cfg.BaseURL)
+		defaultMapper := clientConfig.AzureModelMapperFunc
+		clientConfig.AzureModelMapperFunc = func(model string) string {
+			if deployment, ok := cfg.Deployments[model]; ok {
+				return deployment
+			}
+
+			return defaultMapper(model)
+		}
+
+		return newOpenAI(cfg, clientConfig), nil
+	case TypeAnthropic:
+		return newAnthropic(cfg), nil
+	case TypeGemini:
+		return newGemini(cfg), nil
+	}
+
+	return nil, ErrUnknownType
+}
@@ -0,0 +1,275 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"chatbot-gpt/internal/config"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 500 * time.Millisecond
+	defaultMaxDelay    = 30 * time.Second
+
+	// bytesPerToken is the rough number of bytes per token used to estimate the tokens of a request.
+	bytesPerToken = 4
+)
+
+// rateLimitBudget is the remaining rate limit budget reported by the x-ratelimit-* headers.
+type rateLimitBudget struct {
+	mu sync.Mutex
+
+	known             bool
+	remainingRequests int
+	remainingTokens   int
+	requestsResetAt   time.Time
+	tokensResetAt     time.Time
+}
+
+// update updates the budget from the headers of a response.
+func (b *rateLimitBudget) update(header http.Header, now time.Time) {
+	remainingRequests, requestsErr := strconv.Atoi(header.Get("x-ratelimit-remaining-requests"))
+	remainingTokens, tokensErr := strconv.Atoi(header.Get("x-ratelimit-remaining-tokens"))
+	if requestsErr != nil && tokensErr != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.known = true
+	b.remainingRequests = -1
+	b.remainingTokens = -1
+
+	if requestsErr == nil {
+		b.remainingRequests = remainingRequests
+		b.requestsResetAt = parseReset(header.Get("x-ratelimit-reset-requests"), now)
+	}
+
+	if tokensErr == nil {
+		b.remainingTokens = remainingTokens
+		b.tokensResetAt = parseReset(header.Get("x-ratelimit-reset-tokens"), now)
+	}
+}
+
+// reserve reserves a request of the given number of tokens from the budget.
+// It returns how long to wait before the budget allows the request.
+func (b *rateLimitBudget) reserve(numTokens int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.known {
+		return 0
+	}
+
+	var wait time.Duration
+
+	if b.remainingRequests == 0 && b.requestsResetAt.After(now) {
+		wait = max(wait, b.requestsResetAt.Sub(now))
+	}
+
+	if b.remainingTokens >= 0 && b.remainingTokens < numTokens && b.tokensResetAt.After(now) {
+		wait = max(wait, b.tokensResetAt.Sub(now))
+	}
+
+	if wait > 0 {
+		// The budget is refilled after the wait, and the next response will report the actual one.
+		b.known = false
+		return wait
+	}
+
+	if b.remainingRequests > 0 {
+		b.remainingRequests--
+	}
+
+	if b.remainingTokens > 0 {
+		b.remainingTokens = max(b.remainingTokens-numTokens, 0)
+	}
+
+	return 0
+}
+
+// parseReset parses a reset header, which is either a duration such as "6m0s" or a timestamp.
+func parseReset(value string, now time.Time) time.Time {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d)
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
+
+// parseRetryAfter parses the Retry-After headers of a response.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.Atoi(header.Get("retry-after-ms")); err == nil {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
+// retryTransport is a transport that retries transient failures with jittered exponential backoff,
+// and delays requests when the rate limit budget reported by the provider is exhausted.
+type retryTransport struct {
+	base        http.RoundTripper
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	budget      rateLimitBudget
+}
+
+// RoundTrip sends the request, retrying it on transient failures.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	numTokens := len(body) / bytesPerToken
+
+	for attempt := 1; ; attempt++ {
+		if err := sleep(req.Context(), t.budget.reserve(numTokens, time.Now())); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if resp != nil {
+			t.budget.update(resp.Header, time.Now())
+		}
+
+		lastAttempt := attempt >= t.maxAttempts
+		delay := t.backoff(attempt)
+
+		switch {
+		case err != nil:
+			if lastAttempt || req.Context().Err() != nil {
+				return nil, err
+			}
+		case isTransientStatusCode(resp.StatusCode):
+			if lastAttempt || isQuotaExceeded(resp) {
+				return resp, nil
+			}
+
+			if retryAfter, ok := parseRetryAfter(resp.Header, time.Now()); ok {
+				if retryAfter > t.maxDelay {
+					return resp, nil
+				}
+
+				delay = retryAfter
+			}
+
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff returns the jittered exponential backoff delay of the given attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := t.baseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.maxDelay {
+		delay = t.maxDelay
+	}
+
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isQuotaExceeded reports whether a 429 response is caused by an exhausted quota,
+// which does not recover by retrying. The body is kept readable for the caller.
+func isQuotaExceeded(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	return bytes.Contains(data, []byte("insufficient_quota"))
+}
+
+// readBody reads the body of the request so that it can be sent again.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	defer req.Body.Close()
+
+	return io.ReadAll(req.Body)
+}
+
+// sleep waits for the duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// newRetryTransport creates a new retry transport from the configuration.
+func newRetryTransport(cfg config.Retry, base http.RoundTripper) *retryTransport {
+	t := &retryTransport{
+		base:        base,
+		maxAttempts: cfg.MaxAttempts,
+		baseDelay:   time.Duration(cfg.BaseDelay) * time.Millisecond,
+		maxDelay:    time.Duration(cfg.MaxDelay) * time.Millisecond,
+	}
+
+	if t.maxAttempts <= 0 {
+		t.maxAttempts = defaultMaxAttempts
+	}
+
+	if t.baseDelay <= 0 {
+		t.baseDelay = defaultBaseDelay
+	}
+
+	if t.maxDelay <= 0 {
+		t.maxDelay = defaultMaxDelay
+	}
+
+	return t
+}
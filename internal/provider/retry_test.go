@@ -0,0 +1,288 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chatbot-gpt/internal/config"
+)
+
+// scriptedResponse is a response of a scripted server.
+type scriptedResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// scriptedServer starts a server responding with the responses in turn, then with 200 OK.
+// It returns the bodies of the requests received.
+func scriptedServer(t *testing.T, responses ...scriptedResponse) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		attempt := len(bodies)
+		bodies = append(bodies, string(data))
+		mu.Unlock()
+
+		resp := scriptedResponse{status: http.StatusOK, body: "ok"}
+		if attempt < len(responses) {
+			resp = responses[attempt]
+		}
+
+		for key, value := range resp.header {
+			w.Header().Set(key, value)
+		}
+
+		w.WriteHeader(resp.status)
+		_, _ = io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string(nil), bodies...)
+	}
+}
+
+// testRetry is a retry config of short delays for the tests.
+var testRetry = config.Retry{MaxAttempts: 3, BaseDelay: 1, MaxDelay: 200}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []scriptedResponse
+		attempts  int
+		status    int
+		body      string
+	}{
+		{
+			name:      "success",
+			responses: nil,
+			attempts:  1,
+			status:    http.StatusOK,
+			body:      "ok",
+		},
+		{
+			name:      "rate limited",
+			responses: []scriptedResponse{{status: http.StatusTooManyRequests, body: "slow down"}},
+			attempts:  2,
+			status:    http.StatusOK,
+			body:      "ok",
+		},
+		{
+			name: "server errors",
+			responses: []scriptedResponse{
+				{status: http.StatusServiceUnavailable},
+				{status: http.StatusBadGateway},
+				{status: http.StatusInternalServerError, body: "failed"},
+			},
+			attempts: 3,
+			status:   http.StatusInternalServerError,
+			body:     "failed",
+		},
+		{
+			name:      "client error",
+			responses: []scriptedResponse{{status: http.StatusBadRequest, body: "bad request"}},
+			attempts:  1,
+			status:    http.StatusBadRequest,
+			body:      "bad request",
+		},
+		{
+			name: "quota exceeded",
+			responses: []scriptedResponse{{
+				status: http.StatusTooManyRequests,
+				body:   `{"error":{"type":"insufficient_quota","message":"You exceeded your current quota"}}`,
+			}},
+			attempts: 1,
+			status:   http.StatusTooManyRequests,
+			body:     `{"error":{"type":"insufficient_quota","message":"You exceeded your current quota"}}`,
+		},
+		{
+			name: "retry after beyond the maximum delay",
+			responses: []scriptedResponse{{
+				status: http.StatusTooManyRequests,
+				header: map[string]string{"Retry-After": "60"},
+				body:   "later",
+			}},
+			attempts: 1,
+			status:   http.StatusTooManyRequests,
+			body:     "later",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, bodies := scriptedServer(t, tt.responses...)
+			client := &http.Client{Transport: newRetryTransport(testRetry, http.DefaultTransport)}
+
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"model":"gpt-4"}`))
+			if err != nil {
+				t.Fatalf("Post() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			data, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status || string(data) != tt.body {
+				t.Errorf("response = %d %q, want %d %q", resp.StatusCode, data, tt.status, tt.body)
+			}
+
+			received := bodies()
+			if len(received) != tt.attempts {
+				t.Errorf("attempts = %d, want %d", len(received), tt.attempts)
+			}
+
+			// The body is sent again on every attempt.
+			for i, body := range received {
+				if body != `{"model":"gpt-4"}` {
+					t.Errorf("body of attempt %d = %q", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransportHonoursRetryAfter(t *testing.T) {
+	server, bodies := scriptedServer(t, scriptedResponse{
+		status: http.StatusTooManyRequests,
+		header: map[string]string{"retry-after-ms": "50", "Retry-After": "60"},
+	})
+	client := &http.Client{Transport: newRetryTransport(testRetry, http.DefaultTransport)}
+
+	start := time.Now()
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	// retry-after-ms takes precedence over Retry-After, which is beyond the maximum delay.
+	if elapsed := time.Since(start); resp.StatusCode != http.StatusOK || elapsed < 50*time.Millisecond {
+		t.Errorf("response = %d after %v, want 200 after 50ms", resp.StatusCode, elapsed)
+	}
+
+	if len(bodies()) != 2 {
+		t.Errorf("attempts = %d, want 2", len(bodies()))
+	}
+}
+
+func TestRetryTransportWaitsForBudget(t *testing.T) {
+	server, _ := scriptedServer(t, scriptedResponse{
+		status: http.StatusOK,
+		header: map[string]string{
+			"x-ratelimit-remaining-requests": "0",
+			"x-ratelimit-reset-requests":     "80ms",
+		},
+	})
+	client := &http.Client{Transport: newRetryTransport(testRetry, http.DefaultTransport)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	start := time.Now()
+
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	// The first response used up the requests, so the second one waits for their reset.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("second request sent after %v, want after the reset of 80ms", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   time.Duration
+		ok     bool
+	}{
+		{name: "milliseconds", header: map[string]string{"retry-after-ms": "1500"}, want: 1500 * time.Millisecond, ok: true},
+		{name: "seconds", header: map[string]string{"Retry-After": "2"}, want: 2 * time.Second, ok: true},
+		{
+			name:   "date",
+			header: map[string]string{"Retry-After": now.Add(3 * time.Second).Format(http.TimeFormat)},
+			want:   3 * time.Second,
+			ok:     true,
+		},
+		{
+			name:   "past date",
+			header: map[string]string{"Retry-After": now.Add(-time.Minute).Format(http.TimeFormat)},
+			want:   0,
+			ok:     true,
+		},
+		{name: "invalid", header: map[string]string{"Retry-After": "soon"}},
+		{name: "missing"},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		for key, value := range tt.header {
+			header.Set(key, value)
+		}
+
+		if got, ok := parseRetryAfter(header, now); got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter() of %s = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRateLimitBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var budget rateLimitBudget
+	if wait := budget.reserve(1000, now); wait != 0 {
+		t.Errorf("reserve() of an unknown budget = %v, want 0", wait)
+	}
+
+	budget.update(http.Header{
+		"X-Ratelimit-Remaining-Requests": {"2"},
+		"X-Ratelimit-Remaining-Tokens":   {"1500"},
+		"X-Ratelimit-Reset-Requests":     {"1s"},
+		"X-Ratelimit-Reset-Tokens":       {"6m0s"},
+	}, now)
+
+	if wait := budget.reserve(1000, now); wait != 0 {
+		t.Errorf("reserve() within the budget = %v, want 0", wait)
+	}
+
+	// 500 tokens are left, which is not enough for 1000 more.
+	if wait := budget.reserve(1000, now); wait != 6*time.Minute {
+		t.Errorf("reserve() beyond the tokens = %v, want 6m", wait)
+	}
+
+	// The budget is unknown again until the next response.
+	if wait := budget.reserve(1000, now); wait != 0 {
+		t.Errorf("reserve() after waiting = %v, want 0", wait)
+	}
+
+	budget.update(http.Header{
+		"X-Ratelimit-Remaining-Requests": {"0"},
+		"X-Ratelimit-Reset-Requests":     {now.Add(time.Second).Format(time.RFC3339)},
+	}, now)
+
+	if wait := budget.reserve(1, now); wait != time.Second {
+		t.Errorf("reserve() beyond the requests = %v, want 1s", wait)
+	}
+}
@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"fmt"
+
+	"chatbot-gpt/internal/config"
+)
+
+// samplingRanges are the ranges of the sampling parameters accepted by a provider.
+// A nil range means the parameter is not supported.
+type samplingRanges struct {
+	temperature *[2]float32
+	topP        *[2]float32
+	penalty     *[2]float32
+	logitBias   *[2]int
+	seed        bool
+
+	// maxStop is the maximum number of stop sequences, or zero if unlimited.
+	maxStop int
+}
+
+// check checks the sampling parameters against the ranges.
+func (r samplingRanges) check(sampling config.Sampling) error {
+	if err := checkRange("temperature", sampling.Temperature, r.temperature); err != nil {
+		return err
+	}
+
+	if err := checkRange("top_p", sampling.TopP, r.topP); err != nil {
+		return err
+	}
+
+	if err := checkRange("presence_penalty", sampling.PresencePenalty, r.penalty); err != nil {
+		return err
+	}
+
+	if err := checkRange("frequency_penalty", sampling.FrequencyPenalty, r.penalty); err != nil {
+		return err
+	}
+
+	if sampling.Seed != nil && !r.seed {
+		return fmt.Errorf("%w: seed", ErrNotSupported)
+	}
+
+	if r.maxStop > 0 && len(sampling.Stop) > r.maxStop {
+		return fmt.Errorf("%w: stop accepts at most %d sequences", ErrOutOfRange, r.maxStop)
+	}
+
+	for token, bias := range sampling.LogitBias {
+		if r.logitBias == nil {
+			return fmt.Errorf("%w: logit_bias", ErrNotSupported)
+		}
+
+		if bias < r.logitBias[0] || bias > r.logitBias[1] {
+			return fmt.Errorf("%w: logit_bias of %s must be in [%d, %d]", ErrOutOfRange, token, r.logitBias[0], r.logitBias[1])
+		}
+	}
+
+	return nil
+}
+
+// checkRange checks whether the value is within the range.
+func checkRange(name string, value *float32, bounds *[2]float32) error {
+	if value == nil {
+		return nil
+	}
+
+	if bounds == nil {
+		return fmt.Errorf("%w: %s", ErrNotSupported, name)
+	}
+
+	if *value < bounds[0] || *value > bounds[1] {
+		return fmt.Errorf("%w: %s must be in [%v, %v]", ErrOutOfRange, name, bounds[0], bounds[1])
+	}
+
+	return nil
+}
+
+var (
+	// openAISamplingRanges are the sampling ranges of the OpenAI API.
+	openAISamplingRanges = samplingRanges{
+		temperature: &[2]float32{0, 2},
+		topP:        &[2]float32{0, 1},
+		penalty:     &[2]float32{-2, 2},
+		logitBias:   &[2]int{-100, 100},
+		seed:        true,
+		maxStop:     4,
+	}
+
+	// anthropicSamplingRanges are the sampling ranges of the Anthropic Messages API.
+	anthropicSamplingRanges = samplingRanges{
+		temperature: &[2]float32{0, 1},
+		topP:        &[2]float32{0, 1},
+	}
+
+	// geminiSamplingRanges are the sampling ranges of the Gemini API.
+	geminiSamplingRanges = samplingRanges{
+		temperature: &[2]float32{0, 2},
+		topP:        &[2]float32{0, 1},
+		penalty:     &[2]float32{-2, 2},
+		seed:        true,
+		maxStop:     5,
+	}
+)
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// sseEvent is an event of a server-sent event stream.
+type sseEvent struct {
+	Name string
+	Data []byte
+}
+
+// sseReader reads events from a server-sent event stream.
+type sseReader struct {
+	reader *bufio.Reader
+}
+
+// Next returns the next event of the stream, or io.EOF when the stream ends.
+func (r *sseReader) Next() (*sseEvent, error) {
+	event := &sseEvent{}
+	hasData := false
+
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && !(err == io.EOF && len(line) > 0) {
+			if err == io.EOF && hasData {
+				return event, nil
+			}
+
+			return nil, err
+		}
+
+		line = bytes.TrimRight(line, "\r\n")
+
+		switch {
+		case len(line) == 0:
+			if hasData {
+				return event, nil
+			}
+		case bytes.HasPrefix(line, []byte(":")):
+			// Comments are used as keep-alive messages.
+		case bytes.HasPrefix(line, []byte("event:")):
+			event.Name = string(bytes.TrimSpace(line[len("event:"):]))
+		case bytes.HasPrefix(line, []byte("data:")):
+			if hasData {
+				event.Data = append(event.Data, '\n')
+			}
+
+			event.Data = append(event.Data, bytes.TrimPrefix(line[len("data:"):], []byte(" "))...)
+			hasData = true
+		}
+	}
+}
+
+// newSSEReader creates a new server-sent event reader.
+func newSSEReader(reader io.Reader) *sseReader {
+	return &sseReader{
+		reader: bufio.NewReader(reader),
+	}
+}
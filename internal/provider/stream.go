@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// openAIStream is a chat stream of the OpenAI API.
+type openAIStream struct {
+	stream *openai.ChatCompletionStream
+	usage  *openai.Usage
+}
+
+// Recv returns the next delta of the stream, keeping the usage reported by the last chunk.
+func (o *openAIStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	resp, err := o.stream.Recv()
+	if err == nil && resp.Usage != nil {
+		o.usage = resp.Usage
+	}
+
+	return resp, err
+}
+
+// Close closes the stream.
+func (o *openAIStream) Close() {
+	_ = o.stream.Close()
+}
+
+// Usage returns the usage reported by the stream, or nil if it was not requested or the server ignored the request,
+// in which case the callers predict the tokens themselves.
+func (o *openAIStream) Usage() *openai.Usage {
+	return o.usage
+}
+
+// completionStream replays a non-streaming chat completion as stream deltas.
+type completionStream struct {
+	response *openai.ChatCompletionResponse
+	sent     int
+}
+
+// Recv returns the content of the completion first, then its finish reason, then io.EOF.
+func (c *completionStream) Recv() (openai.ChatCompletionStreamResponse, error) {
+	if c.sent >= 2 {
+		return openai.ChatCompletionStreamResponse{}, io.EOF
+	}
+
+	resp := openai.ChatCompletionStreamResponse{
+		ID:      c.response.ID,
+		Object:  c.response.Object,
+		Created: c.response.Created,
+		Model:   c.response.Model,
+	}
+
+	for _, choice := range c.response.Choices {
+		streamChoice := openai.ChatCompletionStreamChoice{Index: choice.Index}
+
+		if c.sent == 0 {
+			streamChoice.Delta = openai.ChatCompletionStreamChoiceDelta{
+				Content:   choice.Message.Content,
+				Role:      choice.Message.Role,
+				ToolCalls: choice.Message.ToolCalls,
+			}
+		} else {
+			streamChoice.FinishReason = choice.FinishReason
+		}
+
+		resp.Choices = append(resp.Choices, streamChoice)
+	}
+
+	c.sent++
+
+	return resp, nil
+}
+
+// Close does nothing since the completion has been fully received.
+func (c *completionStream) Close() {}
+
+// Usage returns the usage of the completion.
+func (c *completionStream) Usage() *openai.Usage {
+	return &c.response.Usage
+}
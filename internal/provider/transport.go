@@ -0,0 +1,36 @@
+package provider
+
+import (
+	"net/http"
+
+	"chatbot-gpt/internal/config"
+)
+
+// headerTransport is a transport that adds extra headers to every request.
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+// RoundTrip adds the headers to the request and sends it.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) > 0 {
+		req = req.Clone(req.Context())
+		for key, value := range t.headers {
+			req.Header.Set(key, value)
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// newHTTPClient creates the HTTP client of a provider.
+// Requests are retried on transient failures and delayed by the rate limit budget of the provider.
+func newHTTPClient(cfg config.Provider) *http.Client {
+	return &http.Client{
+		Transport: newRetryTransport(cfg.Retry, &headerTransport{
+			headers: cfg.Headers,
+			base:    http.DefaultTransport,
+		}),
+	}
+}
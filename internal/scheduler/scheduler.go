@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"chatbot-gpt/internal/config"
+)
+
+// ticket is a request waiting for a slot.
+type ticket struct {
+	serverID string
+	userID   string
+
+	// ready is closed when the ticket is granted a slot.
+	ready chan struct{}
+
+	// positions receives the latest position of the ticket in the queue.
+	positions chan int
+	position  int
+}
+
+// notify sends the position to the waiting request, replacing the one not received yet.
+func (t *ticket) notify(position int) {
+	if t.position == position {
+		return
+	}
+
+	t.position = position
+
+	select {
+	case <-t.positions:
+	default:
+	}
+
+	t.positions <- position
+}
+
+// Scheduler limits the number of concurrent requests globally, per server and per user.
+// Waiting requests are served in round-robin across servers, in order of arrival within a server.
+type Scheduler struct {
+	mu sync.Mutex
+
+	maxConcurrent int
+	maxPerServer  int
+	maxPerUser    int
+
+	running         int
+	runningByServer map[string]int
+	runningByUser   map[string]int
+
+	// queues are the waiting tickets by server, and servers is the round-robin order of the servers.
+	queues  map[string][]*ticket
+	servers []string
+	cursor  int
+}
+
+// Acquire waits until a slot is available for the user of the server.
+// onPosition is called with the position in the queue whenever it changes while waiting.
+// The returned function releases the slot and must be called once the request is done.
+func (s *Scheduler) Acquire(
+	ctx context.Context, serverID, userID string, onPosition func(position int),
+) (func(), error) {
+	t := &ticket{
+		serverID:  serverID,
+		userID:    userID,
+		ready:     make(chan struct{}),
+		positions: make(chan int, 1),
+	}
+
+	s.mu.Lock()
+	if _, ok := s.queues[serverID]; !ok {
+		s.servers = append(s.servers, serverID)
+	}
+
+	s.queues[serverID] = append(s.queues[serverID], t)
+	s.dispatch()
+	s.mu.Unlock()
+
+	release := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.running--
+		decrement(s.runningByServer, serverID)
+		decrement(s.runningByUser, userID)
+		s.dispatch()
+	}
+
+	for {
+		select {
+		case <-t.ready:
+			return release, nil
+		case position := <-t.positions:
+			select {
+			case <-t.ready:
+				return release, nil
+			default:
+			}
+
+			if onPosition != nil {
+				onPosition(position)
+			}
+		case <-ctx.Done():
+			s.mu.Lock()
+			select {
+			case <-t.ready:
+				s.mu.Unlock()
+				release()
+			default:
+				s.remove(t)
+				s.dispatch()
+				s.mu.Unlock()
+			}
+
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dispatch grants slots to the waiting tickets allowed by the limits, and updates the positions of the others.
+// It must be called with the lock held.
+func (s *Scheduler) dispatch() {
+	for !s.full(s.running, s.maxConcurrent) {
+		t := s.next()
+		if t == nil {
+			break
+		}
+
+		s.remove(t)
+		s.running++
+		s.runningByServer[t.serverID]++
+		s.runningByUser[t.userID]++
+		close(t.ready)
+	}
+
+	s.updatePositions()
+}
+
+// next returns the next ticket allowed by the limits, starting from the server at the cursor.
+// The cursor is moved past the server of the ticket, so that the next ticket comes from another server.
+func (s *Scheduler) next() *ticket {
+	for i := range s.servers {
+		index := (s.cursor + i) % len(s.servers)
+		serverID := s.servers[index]
+
+		if s.full(s.runningByServer[serverID], s.maxPerServer) {
+			continue
+		}
+
+		for _, t := range s.queues[serverID] {
+			if !s.full(s.runningByUser[t.userID], s.maxPerUser) {
+				s.cursor = index + 1
+				return t
+			}
+		}
+	}
+
+	return nil
+}
+
+// remove removes the ticket from its queue, and the server from the round-robin order if its queue is empty.
+func (s *Scheduler) remove(t *ticket) {
+	queue := slices.DeleteFunc(s.queues[t.serverID], func(queued *ticket) bool { return queued == t })
+	if len(queue) > 0 {
+		s.queues[t.serverID] = queue
+		return
+	}
+
+	delete(s.queues, t.serverID)
+
+	index := slices.Index(s.servers, t.serverID)
+	s.servers = slices.Delete(s.servers, index, index+1)
+
+	if index < s.cursor {
+		s.cursor--
+	}
+
+	if s.cursor >= len(s.servers) {
+		s.cursor = 0
+	}
+}
+
+// updatePositions notifies the waiting tickets of their positions in the round-robin order.
+func (s *Scheduler) updatePositions() {
+	position := 1
+
+	for depth := 0; ; depth++ {
+		found := false
+
+		for i := range s.servers {
+			queue := s.queues[s.servers[(s.cursor+i)%len(s.servers)]]
+			if depth < len(queue) {
+				queue[depth].notify(position)
+				position++
+				found = true
+			}
+		}
+
+		if !found {
+			return
+		}
+	}
+}
+
+// decrement decrements the count of the key, which is deleted once it is zero so that idle servers and users are forgotten.
+func decrement(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+
+	counts[key]--
+}
+
+// full reports whether the count has reached the limit. A limit of zero or less is unlimited.
+func (s *Scheduler) full(count, limit int) bool {
+	return limit > 0 && count >= limit
+}
+
+// New creates a new scheduler with the limits of the configuration.
+func New(cfg config.Scheduler) *Scheduler {
+	return &Scheduler{
+		maxConcurrent:   cfg.MaxConcurrent,
+		maxPerServer:    cfg.MaxPerServer,
+		maxPerUser:      cfg.MaxPerUser,
+		runningByServer: make(map[string]int),
+		runningByUser:   make(map[string]int),
+		queues:          make(map[string][]*ticket),
+	}
+}
@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"chatbot-gpt/internal/config"
+)
+
+// acquisition is the outcome of an Acquire call.
+type acquisition struct {
+	release func()
+	err     error
+}
+
+// request is a request of a user of a server.
+type request struct {
+	serverID, userID string
+}
+
+// seen returns the number of the requests running or waiting.
+func (s *Scheduler) seen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := s.running
+	for _, queue := range s.queues {
+		n += len(queue)
+	}
+
+	return n
+}
+
+// waitUntil waits until the condition holds, failing the test after a second.
+func waitUntil(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the scheduler")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// start acquires a slot in the background, and returns once the request is running or queued,
+// so that the requests are queued in the order they are started.
+func start(t *testing.T, ctx context.Context, s *Scheduler, r request) <-chan acquisition {
+	t.Helper()
+
+	before := s.seen()
+	done := make(chan acquisition, 1)
+
+	go func() {
+		release, err := s.Acquire(ctx, r.serverID, r.userID, nil)
+		done <- acquisition{release, err}
+	}()
+
+	waitUntil(t, func() bool { return s.seen() > before })
+
+	return done
+}
+
+// granted returns the acquisition of the request, failing the test if it is not granted.
+func granted(t *testing.T, done <-chan acquisition) acquisition {
+	t.Helper()
+
+	select {
+	case a := <-done:
+		if a.err != nil {
+			t.Fatalf("Acquire() error = %v", a.err)
+		}
+
+		return a
+	case <-time.After(time.Second):
+		t.Fatal("the request was not granted a slot")
+		return acquisition{}
+	}
+}
+
+// pending reports whether the request is still waiting.
+func pending(done <-chan acquisition) bool {
+	select {
+	case <-done:
+		return false
+	case <-time.After(10 * time.Millisecond):
+		return true
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Scheduler
+		requests []request
+		granted  []bool
+	}{
+		{
+			name:     "global",
+			cfg:      config.Scheduler{MaxConcurrent: 2},
+			requests: []request{{"a", "1"}, {"b", "2"}, {"c", "3"}},
+			granted:  []bool{true, true, false},
+		},
+		{
+			name:     "per server",
+			cfg:      config.Scheduler{MaxPerServer: 1},
+			requests: []request{{"a", "1"}, {"a", "2"}, {"b", "3"}},
+			granted:  []bool{true, false, true},
+		},
+		{
+			name:     "per user",
+			cfg:      config.Scheduler{MaxPerUser: 1},
+			requests: []request{{"a", "1"}, {"a", "1"}, {"a", "2"}, {"b", "1"}},
+			granted:  []bool{true, false, true, false},
+		},
+		{
+			name:     "unlimited",
+			requests: []request{{"a", "1"}, {"a", "1"}, {"a", "1"}},
+			granted:  []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.cfg)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			var acquisitions []<-chan acquisition
+			for _, r := range tt.requests {
+				acquisitions = append(acquisitions, start(t, ctx, s, r))
+			}
+
+			var releases []func()
+			for i, done := range acquisitions {
+				if !tt.granted[i] {
+					if !pending(done) {
+						t.Errorf("request %d was granted a slot over the limits", i)
+					}
+
+					continue
+				}
+
+				releases = append(releases, granted(t, done).release)
+			}
+
+			// The waiting requests give up before the slots are released.
+			cancel()
+			for i, done := range acquisitions {
+				if !tt.granted[i] {
+					if a := <-done; !errors.Is(a.err, context.Canceled) {
+						t.Errorf("Acquire() of request %d error = %v, want %v", i, a.err, context.Canceled)
+					}
+				}
+			}
+
+			for _, release := range releases {
+				release()
+			}
+
+			if s.running != 0 || len(s.runningByServer) != 0 || len(s.runningByUser) != 0 || len(s.queues) != 0 {
+				t.Errorf("idle scheduler keeps %d running, %v by server, %v by user and %d queues",
+					s.running, s.runningByServer, s.runningByUser, len(s.queues))
+			}
+		})
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s := New(config.Scheduler{MaxConcurrent: 1})
+	ctx := context.Background()
+
+	first := granted(t, start(t, ctx, s, request{"a", "0"}))
+
+	// The waiting requests are served one server at a time, in order of arrival within a server.
+	acquisitions := map[string]<-chan acquisition{}
+	for _, r := range []request{{"a", "1"}, {"a", "2"}, {"b", "3"}, {"c", "4"}} {
+		acquisitions[r.userID] = start(t, ctx, s, r)
+	}
+
+	release := first.release
+	for _, userID := range []string{"1", "3", "4", "2"} {
+		release()
+
+		release = granted(t, acquisitions[userID]).release
+		for other, done := range acquisitions {
+			if other != userID && len(done) > 0 {
+				t.Fatalf("request of user %s was granted a slot before user %s", other, userID)
+			}
+		}
+
+		delete(acquisitions, userID)
+	}
+
+	release()
+}
+
+func TestCancelWhileQueued(t *testing.T) {
+	s := New(config.Scheduler{MaxConcurrent: 1})
+
+	first := granted(t, start(t, context.Background(), s, request{"a", "1"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	canceled := start(t, ctx, s, request{"b", "2"})
+	next := start(t, context.Background(), s, request{"c", "3"})
+
+	cancel()
+	if a := <-canceled; !errors.Is(a.err, context.Canceled) || a.release != nil {
+		t.Fatalf("Acquire() = %v, want %v", a.err, context.Canceled)
+	}
+
+	if _, ok := s.queues["b"]; ok {
+		t.Error("the canceled request is still queued")
+	}
+
+	// The slot the canceled request waited for goes to the next one.
+	first.release()
+	granted(t, next).release()
+
+	if s.running != 0 || len(s.servers) != 0 {
+		t.Errorf("idle scheduler keeps %d running and the servers %v", s.running, s.servers)
+	}
+}
+
+func TestRemoveAdjustsCursor(t *testing.T) {
+	tests := []struct {
+		name        string
+		servers     []string
+		cursor      int
+		removed     string
+		wantServers int
+		wantCursor  int
+	}{
+		{name: "before the cursor", servers: []string{"a", "b", "c"}, cursor: 2, removed: "a", wantServers: 2, wantCursor: 1},
+		{name: "at the cursor", servers: []string{"a", "b", "c"}, cursor: 1, removed: "b", wantServers: 2, wantCursor: 1},
+		{name: "after the cursor", servers: []string{"a", "b", "c"}, cursor: 0, removed: "c", wantServers: 2, wantCursor: 0},
+		{name: "last at the cursor", servers: []string{"a", "b", "c"}, cursor: 2, removed: "c", wantServers: 2, wantCursor: 0},
+		{name: "only", servers: []string{"a"}, cursor: 0, removed: "a", wantServers: 0, wantCursor: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(config.Scheduler{})
+			tickets := map[string]*ticket{}
+			for _, serverID := range tt.servers {
+				tickets[serverID] = &ticket{serverID: serverID}
+				s.queues[serverID] = []*ticket{tickets[serverID]}
+			}
+
+			s.servers = append([]string(nil), tt.servers...)
+			s.cursor = tt.cursor
+
+			// The server at the cursor is served next, unless it is the one removed.
+			var next string
+			if tt.servers[tt.cursor] != tt.removed {
+				next = tt.servers[tt.cursor]
+			}
+
+			s.remove(tickets[tt.removed])
+
+			if len(s.servers) != tt.wantServers || s.cursor != tt.wantCursor {
+				t.Errorf("remove() left the servers %v at %d, want %d servers at %d",
+					s.servers, s.cursor, tt.wantServers, tt.wantCursor)
+			}
+
+			if next != "" && s.servers[s.cursor] != next {
+				t.Errorf("the cursor moved from %s to %s", next, s.servers[s.cursor])
+			}
+		})
+	}
+}
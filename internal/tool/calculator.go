@@ -0,0 +1,345 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxExponent is the maximum absolute exponent accepted by the calculator.
+	maxExponent = 1024
+
+	// maxResultBits is the maximum size of the numerators and denominators of powers.
+	maxResultBits = 1 << 16
+
+	// calculatorPrecision is the number of decimal places of results which are not finite decimals.
+	calculatorPrecision = 20
+)
+
+var (
+	// errDivisionByZero is returned when the expression divides by zero.
+	errDivisionByZero = errors.New("division by zero")
+
+	// errSyntax is returned when the expression is malformed.
+	errSyntax = errors.New("syntax error")
+)
+
+// calculator evaluates arithmetic expressions exactly with rational numbers.
+type calculator struct {
+	input string
+	pos   int
+}
+
+// skipSpaces skips the spaces at the position.
+func (c *calculator) skipSpaces() {
+	for c.pos < len(c.input) && unicode.IsSpace(rune(c.input[c.pos])) {
+		c.pos++
+	}
+}
+
+// consume consumes the token at the position if it is one of the tokens.
+func (c *calculator) consume(tokens ...string) (string, bool) {
+	c.skipSpaces()
+
+	for _, token := range tokens {
+		if strings.HasPrefix(c.input[c.pos:], token) {
+			c.pos += len(token)
+			return token, true
+		}
+	}
+
+	return "", false
+}
+
+// expression parses additions and subtractions.
+func (c *calculator) expression() (*big.Rat, error) {
+	result, err := c.term()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := c.consume("+", "-")
+		if !ok {
+			return result, nil
+		}
+
+		operand, err := c.term()
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "+" {
+			result.Add(result, operand)
+		} else {
+			result.Sub(result, operand)
+		}
+	}
+}
+
+// term parses multiplications, divisions and remainders.
+func (c *calculator) term() (*big.Rat, error) {
+	result, err := c.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		// "**" is the power operator, which is parsed by power.
+		if strings.HasPrefix(strings.TrimLeftFunc(c.input[c.pos:], unicode.IsSpace), "**") {
+			return result, nil
+		}
+
+		op, ok := c.consume("*", "×", "/", "÷", "%")
+		if !ok {
+			return result, nil
+		}
+
+		operand, err := c.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "*", "×":
+			result.Mul(result, operand)
+		case "/", "÷":
+			if operand.Sign() == 0 {
+				return nil, errDivisionByZero
+			}
+
+			result.Quo(result, operand)
+		case "%":
+			if !result.IsInt() || !operand.IsInt() {
+				return nil, fmt.Errorf("%w: %% requires integers", errSyntax)
+			}
+
+			if operand.Sign() == 0 {
+				return nil, errDivisionByZero
+			}
+
+			result.SetInt(new(big.Int).Rem(result.Num(), operand.Num()))
+		}
+	}
+}
+
+// unary parses signs.
+func (c *calculator) unary() (*big.Rat, error) {
+	if op, ok := c.consume("-", "+"); ok {
+		operand, err := c.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "-" {
+			operand.Neg(operand)
+		}
+
+		return operand, nil
+	}
+
+	return c.power()
+}
+
+// power parses powers, which are right associative.
+func (c *calculator) power() (*big.Rat, error) {
+	base, err := c.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := c.consume("^", "**"); !ok {
+		return base, nil
+	}
+
+	exponent, err := c.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	if !exponent.IsInt() || !exponent.Num().IsInt64() || abs(exponent.Num().Int64()) > maxExponent {
+		return nil, fmt.Errorf("%w: exponents must be integers within ±%d", errSyntax, maxExponent)
+	}
+
+	n := exponent.Num().Int64()
+	if n < 0 {
+		if base.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+
+		base.Inv(base)
+		n = -n
+	}
+
+	if int64(max(base.Num().BitLen(), base.Denom().BitLen()))*n > maxResultBits {
+		return nil, fmt.Errorf("%w: the result is too large", errSyntax)
+	}
+
+	num := new(big.Int).Exp(base.Num(), big.NewInt(n), nil)
+	denom := new(big.Int).Exp(base.Denom(), big.NewInt(n), nil)
+
+	return new(big.Rat).SetFrac(num, denom), nil
+}
+
+// primary parses numbers and parentheses.
+func (c *calculator) primary() (*big.Rat, error) {
+	if _, ok := c.consume("("); ok {
+		result, err := c.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := c.consume(")"); !ok {
+			return nil, fmt.Errorf("%w: missing ) at %d", errSyntax, c.pos)
+		}
+
+		return result, nil
+	}
+
+	c.skipSpaces()
+	start := c.pos
+	c.pos = c.scanNumber()
+
+	if start == c.pos {
+		return nil, fmt.Errorf("%w: number expected at %d", errSyntax, c.pos)
+	}
+
+	number, ok := new(big.Rat).SetString(strings.ReplaceAll(c.input[start:c.pos], "_", ""))
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid number %q", errSyntax, c.input[start:c.pos])
+	}
+
+	return number, nil
+}
+
+// scanNumber returns the end of the number at the position, such as 1_000, 0.5 or 1.5e-3.
+func (c *calculator) scanNumber() int {
+	end := c.pos
+
+	for end < len(c.input) {
+		ch := c.input[end]
+
+		switch {
+		case isDigit(ch), ch == '.', ch == '_':
+			end++
+		case (ch == 'e' || ch == 'E') && end > c.pos:
+			// Accept the exponent only if digits follow.
+			next := end + 1
+			if next < len(c.input) && (c.input[next] == '+' || c.input[next] == '-') {
+				next++
+			}
+
+			if next >= len(c.input) || !isDigit(c.input[next]) {
+				return end
+			}
+
+			end = next
+		default:
+			return end
+		}
+	}
+
+	return end
+}
+
+// isDigit reports whether the byte is a decimal digit.
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// abs returns the absolute value of n.
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// isFiniteDecimal reports whether the rational number has a finite decimal representation.
+func isFiniteDecimal(r *big.Rat) bool {
+	denom := new(big.Int).Set(r.Denom())
+
+	for _, factor := range []int64{2, 5} {
+		f := big.NewInt(factor)
+		m := new(big.Int)
+
+		for {
+			q, rem := new(big.Int).QuoRem(denom, f, m)
+			if rem.Sign() != 0 {
+				break
+			}
+
+			denom = q
+		}
+	}
+
+	return denom.Cmp(big.NewInt(1)) == 0
+}
+
+// formatRat formats the rational number as an exact decimal if possible.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+
+	if isFiniteDecimal(r) {
+		// The number of decimal places is at most the exponent of 2 or 5 in the denominator.
+		formatted := r.FloatString(r.Denom().BitLen())
+		return strings.TrimRight(strings.TrimRight(formatted, "0"), ".")
+	}
+
+	return fmt.Sprintf("%s… (= %s)", r.FloatString(calculatorPrecision), r.String())
+}
+
+// calculate evaluates the arithmetic expression exactly.
+func calculate(expression string) (string, error) {
+	c := &calculator{input: expression}
+
+	result, err := c.expression()
+	if err != nil {
+		return "", err
+	}
+
+	c.skipSpaces()
+	if c.pos < len(c.input) {
+		return "", fmt.Errorf("%w: unexpected %q at %d", errSyntax, c.input[c.pos:], c.pos)
+	}
+
+	return formatRat(result), nil
+}
+
+// NewCalculator creates the tool evaluating arithmetic expressions exactly.
+func NewCalculator() Tool {
+	return Tool{
+		Name: "calculate",
+		Description: "Evaluate an arithmetic expression exactly with decimal numbers. " +
+			"Supports + - * / % ^ and parentheses.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"expression": {
+					"type": "string",
+					"description": "Arithmetic expression, e.g. (1.1 + 2.2) * 3 ^ 2"
+				}
+			},
+			"required": ["expression"]
+		}`),
+		Handler: func(_ context.Context, arguments string) (string, error) {
+			var args struct {
+				Expression string `json:"expression"`
+			}
+
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			return calculate(args.Expression)
+		},
+	}
+}
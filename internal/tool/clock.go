@@ -0,0 +1,69 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// decodeArguments decodes the JSON arguments given by the model.
+func decodeArguments(arguments string, v any) error {
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	if err := json.Unmarshal([]byte(arguments), v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	return nil
+}
+
+// NewClock creates the tool telling the current time in a time zone.
+// defaultLocation returns the time zone of a server, used when the model gives none.
+func NewClock(defaultLocation func(guildID string) *time.Location) Tool {
+	return Tool{
+		Name:        "get_time",
+		Description: "Get the current date and time in a time zone.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"timezone": {
+					"type": "string",
+					"description": "IANA time zone name, e.g. Asia/Tokyo. Defaults to the time zone of the server."
+				}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				Timezone string `json:"timezone"`
+			}
+
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			location := time.UTC
+			if args.Timezone != "" {
+				loaded, err := time.LoadLocation(args.Timezone)
+				if err != nil {
+					return "", err
+				}
+
+				location = loaded
+			} else if scope, ok := ScopeFrom(ctx); ok && defaultLocation != nil {
+				if serverLocation := defaultLocation(scope.GuildID); serverLocation != nil {
+					location = serverLocation
+				}
+			}
+
+			now := time.Now().In(location)
+
+			return fmt.Sprintf(
+				"%s %s (%s, UTC%s)",
+				now.Format("2006-01-02 15:04:05"), now.Weekday(), location, now.Format("-07:00"),
+			), nil
+		},
+	}
+}
@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// errUnknownUnit is returned when a unit is unknown.
+	errUnknownUnit = errors.New("unknown unit")
+
+	// errIncompatibleUnits is returned when the units measure different quantities.
+	errIncompatibleUnits = errors.New("incompatible units")
+)
+
+// unit is a unit of a quantity, converted linearly to the base unit of the quantity.
+type unit struct {
+	quantity string
+	factor   float64
+	offset   float64
+}
+
+// units are the units known to the converter by their lowercase names.
+var units = map[string]unit{}
+
+// addUnits adds the units of the quantity, each with its aliases.
+func addUnits(quantity string, factors map[string]float64) {
+	for names, factor := range factors {
+		for _, name := range strings.Split(names, ",") {
+			units[name] = unit{quantity: quantity, factor: factor}
+		}
+	}
+}
+
+func init() {
+	addUnits("length", map[string]float64{
+		"m,meter,meters,metre,metres":          1,
+		"km,kilometer,kilometers,kilometre":    1000,
+		"cm,centimeter,centimeters,centimetre": 0.01,
+		"mm,millimeter,millimeters,millimetre": 0.001,
+		"mi,mile,miles":                        1609.344,
+		"yd,yard,yards":                        0.9144,
+		"ft,foot,feet":                         0.3048,
+		"in,inch,inches":                       0.0254,
+		"nmi,nautical mile,nautical miles":     1852,
+	})
+	addUnits("mass", map[string]float64{
+		"kg,kilogram,kilograms":           1,
+		"g,gram,grams":                    0.001,
+		"mg,milligram,milligrams":         1e-6,
+		"t,tonne,tonnes,metric ton":       1000,
+		"lb,lbs,pound,pounds":             0.45359237,
+		"oz,ounce,ounces":                 0.028349523125,
+		"st,stone,stones":                 6.35029318,
+		"jin,斤":                           0.5,
+		"kan,貫":                           3.75,
+		"ct,carat,carats":                 0.0002,
+		"gr,grain,grains":                 0.00006479891,
+		"ton,short ton,short tons,tn":     907.18474,
+		"long ton,long tons,imperial ton": 1016.0469088,
+	})
+	addUnits("volume", map[string]float64{
+		"l,liter,liters,litre,litres":                1,
+		"ml,milliliter,milliliters,millilitre":       0.001,
+		"m3,cubic meter,cubic meters":                1000,
+		"gal,gallon,gallons":                         3.785411784,
+		"qt,quart,quarts":                            0.946352946,
+		"pt,pint,pints":                              0.473176473,
+		"cup,cups":                                   0.2365882365,
+		"floz,fl oz,fluid ounce,fluid ounces":        0.0295735295625,
+		"tbsp,tablespoon,tablespoons":                0.01478676478125,
+		"tsp,teaspoon,teaspoons":                     0.00492892159375,
+		"imperial gallon,imperial gallons,uk gallon": 4.54609,
+	})
+	addUnits("area", map[string]float64{
+		"m2,square meter,square meters":          1,
+		"km2,square kilometer,square kilometers": 1e6,
+		"ha,hectare,hectares":                    1e4,
+		"acre,acres":                             4046.8564224,
+		"ft2,square foot,square feet":            0.09290304,
+		"mi2,square mile,square miles":           2589988.110336,
+		"tsubo,坪":                                400.0 / 121,
+	})
+	addUnits("time", map[string]float64{
+		"s,sec,second,seconds":        1,
+		"ms,millisecond,milliseconds": 0.001,
+		"min,minute,minutes":          60,
+		"h,hr,hour,hours":             3600,
+		"d,day,days":                  86400,
+		"week,weeks":                  604800,
+		"year,years":                  31557600,
+	})
+	addUnits("speed", map[string]float64{
+		"m/s,mps":       1,
+		"km/h,kph,kmh":  1000.0 / 3600,
+		"mph":           1609.344 / 3600,
+		"kn,knot,knots": 1852.0 / 3600,
+		"ft/s,fps":      0.3048,
+	})
+	addUnits("data", map[string]float64{
+		"b,byte,bytes": 1,
+		"kb,kilobyte":  1e3,
+		"mb,megabyte":  1e6,
+		"gb,gigabyte":  1e9,
+		"tb,terabyte":  1e12,
+		"kib,kibibyte": 1 << 10,
+		"mib,mebibyte": 1 << 20,
+		"gib,gibibyte": 1 << 30,
+		"tib,tebibyte": 1 << 40,
+		"bit,bits":     0.125,
+	})
+	addUnits("energy", map[string]float64{
+		"j,joule,joules":                   1,
+		"kj,kilojoule,kilojoules":          1000,
+		"cal,calorie,calories":             4.184,
+		"kcal,kilocalorie,kilocalories":    4184,
+		"wh,watt hour,watt hours":          3600,
+		"kwh,kilowatt hour,kilowatt hours": 3.6e6,
+	})
+
+	// Temperatures are converted to kelvins with an offset.
+	for names, u := range map[string]unit{
+		"k,kelvin,kelvins": {factor: 1},
+		"c,°c,celsius":     {factor: 1, offset: 273.15},
+		"f,°f,fahrenheit":  {factor: 5.0 / 9, offset: 459.67 * 5 / 9},
+	} {
+		for _, name := range strings.Split(names, ",") {
+			units[name] = unit{quantity: "temperature", factor: u.factor, offset: u.offset}
+		}
+	}
+}
+
+// converter converts values between units and currencies.
+type converter struct {
+	// rates are the exchange rates of currencies, in units of the currency per US dollar.
+	rates map[string]float64
+}
+
+// lookup returns the unit of the name, including the currencies.
+func (c *converter) lookup(name string) (unit, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if u, ok := units[name]; ok {
+		return u, true
+	}
+
+	code := strings.ToUpper(name)
+	if code == "USD" {
+		return unit{quantity: "currency", factor: 1}, true
+	}
+
+	if rate, ok := c.rates[code]; ok && rate > 0 {
+		return unit{quantity: "currency", factor: 1 / rate}, true
+	}
+
+	return unit{}, false
+}
+
+// convert converts the value between the units.
+func (c *converter) convert(value float64, from, to string) (float64, error) {
+	fromUnit, ok := c.lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUnknownUnit, from)
+	}
+
+	toUnit, ok := c.lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", errUnknownUnit, to)
+	}
+
+	if fromUnit.quantity != toUnit.quantity {
+		return 0, fmt.Errorf("%w: %s is %s but %s is %s", errIncompatibleUnits, from, fromUnit.quantity, to, toUnit.quantity)
+	}
+
+	base := value*fromUnit.factor + fromUnit.offset
+
+	return (base - toUnit.offset) / toUnit.factor, nil
+}
+
+// NewConverter creates the tool converting values between units and currencies.
+// rates are the exchange rates of currencies, in units of the currency per US dollar.
+func NewConverter(rates map[string]float64) Tool {
+	c := &converter{rates: make(map[string]float64)}
+	for code, rate := range rates {
+		c.rates[strings.ToUpper(code)] = rate
+	}
+
+	return Tool{
+		Name: "convert_units",
+		Description: "Convert a value between units of length, mass, volume, area, time, speed, data, energy " +
+			"and temperature, or between currencies by ISO 4217 codes with the configured exchange rates.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"value": {"type": "number", "description": "Value to convert"},
+				"from": {"type": "string", "description": "Unit or currency code of the value, e.g. km or USD"},
+				"to": {"type": "string", "description": "Unit or currency code to convert to, e.g. mi or JPY"}
+			},
+			"required": ["value", "from", "to"]
+		}`),
+		Handler: func(_ context.Context, arguments string) (string, error) {
+			var args struct {
+				Value float64 `json:"value"`
+				From  string  `json:"from"`
+				To    string  `json:"to"`
+			}
+
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			result, err := c.convert(args.Value, args.From, args.To)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf(
+				"%s %s = %s %s",
+				strconv.FormatFloat(args.Value, 'g', -1, 64), args.From,
+				strconv.FormatFloat(result, 'g', 10, 64), args.To,
+			), nil
+		},
+	}
+}
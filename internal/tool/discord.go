@@ -0,0 +1,219 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var (
+	// errNoGuild is returned when a Discord tool is called outside a server.
+	errNoGuild = errors.New("not in a server")
+
+	// errMemberNotFound is returned when no member of the server matches.
+	errMemberNotFound = errors.New("member not found")
+)
+
+// guildScope returns the scope of the call, which must be in a server.
+func guildScope(ctx context.Context) (Scope, error) {
+	scope, ok := ScopeFrom(ctx)
+	if !ok || scope.GuildID == "" {
+		return Scope{}, errNoGuild
+	}
+
+	return scope, nil
+}
+
+// isPublicChannel reports whether the channel is visible to everyone in the server.
+func isPublicChannel(guildID string, channel *discordgo.Channel) bool {
+	for _, overwrite := range channel.PermissionOverwrites {
+		// The ID of the @everyone role is the ID of the server.
+		if overwrite.ID == guildID && overwrite.Deny&discordgo.PermissionViewChannel != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findMember returns the member of the server by a mention, an ID or a name.
+func findMember(ctx context.Context, s *discordgo.Session, guildID, user string) (*discordgo.Member, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(user, "<@"), "!"), ">")
+	if member, err := s.GuildMember(guildID, id, discordgo.WithContext(ctx)); err == nil {
+		return member, nil
+	}
+
+	members, err := s.GuildMembersSearch(guildID, strings.TrimPrefix(user, "@"), 1, discordgo.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: %s", errMemberNotFound, user)
+	}
+
+	return members[0], nil
+}
+
+// newServerInfo creates the tool telling the information of the server.
+func newServerInfo(s *discordgo.Session) Tool {
+	return Tool{
+		Name:        "get_server_info",
+		Description: "Get the name, member count and creation date of the current Discord server.",
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {}}`),
+		Handler: func(ctx context.Context, _ string) (string, error) {
+			scope, err := guildScope(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			guild, err := s.GuildWithCounts(scope.GuildID, discordgo.WithContext(ctx))
+			if err != nil {
+				return "", err
+			}
+
+			created, err := discordgo.SnowflakeTimestamp(guild.ID)
+			if err != nil {
+				return "", err
+			}
+
+			return fmt.Sprintf(
+				"name: %s\nmembers: %d\nonline: %d\ncreated: %s",
+				guild.Name, guild.ApproximateMemberCount, guild.ApproximatePresenceCount, created.Format("2006-01-02"),
+			), nil
+		},
+	}
+}
+
+// newChannelList creates the tool listing the public channels of the server.
+func newChannelList(s *discordgo.Session) Tool {
+	return Tool{
+		Name:        "list_channels",
+		Description: "List the public channels of the current Discord server by category.",
+		Parameters:  json.RawMessage(`{"type": "object", "properties": {}}`),
+		Handler: func(ctx context.Context, _ string) (string, error) {
+			scope, err := guildScope(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			channels, err := s.GuildChannels(scope.GuildID, discordgo.WithContext(ctx))
+			if err != nil {
+				return "", err
+			}
+
+			slices.SortStableFunc(channels, func(a, b *discordgo.Channel) int {
+				return a.Position - b.Position
+			})
+
+			categories := make(map[string]string)
+			for _, channel := range channels {
+				if channel.Type == discordgo.ChannelTypeGuildCategory && isPublicChannel(scope.GuildID, channel) {
+					categories[channel.ID] = channel.Name
+				}
+			}
+
+			var lines []string
+			for _, channel := range channels {
+				if channel.Type == discordgo.ChannelTypeGuildCategory || !isPublicChannel(scope.GuildID, channel) {
+					continue
+				}
+
+				category, ok := categories[channel.ParentID]
+				if channel.ParentID != "" && !ok {
+					continue
+				}
+
+				line := fmt.Sprintf("#%s (<#%s>)", channel.Name, channel.ID)
+				if category != "" {
+					line = category + " / " + line
+				}
+
+				if channel.Topic != "" {
+					line += ": " + channel.Topic
+				}
+
+				lines = append(lines, line)
+			}
+
+			return strings.Join(lines, "\n"), nil
+		},
+	}
+}
+
+// newMemberRoles creates the tool telling the roles of a member of the server.
+func newMemberRoles(s *discordgo.Session) Tool {
+	return Tool{
+		Name:        "get_member_roles",
+		Description: "Get the roles of a member of the current Discord server.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"user": {
+					"type": "string",
+					"description": "Mention, ID or name of the member. Defaults to the user talking to you."
+				}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments string) (string, error) {
+			var args struct {
+				User string `json:"user"`
+			}
+
+			if err := decodeArguments(arguments, &args); err != nil {
+				return "", err
+			}
+
+			scope, err := guildScope(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			if args.User == "" {
+				args.User = scope.UserID
+			}
+
+			member, err := findMember(ctx, s, scope.GuildID, args.User)
+			if err != nil {
+				return "", err
+			}
+
+			roles, err := s.GuildRoles(scope.GuildID, discordgo.WithContext(ctx))
+			if err != nil {
+				return "", err
+			}
+
+			slices.SortFunc(roles, func(a, b *discordgo.Role) int {
+				return b.Position - a.Position
+			})
+
+			var names []string
+			for _, role := range roles {
+				if slices.Contains(member.Roles, role.ID) {
+					names = append(names, role.Name)
+				}
+			}
+
+			name := member.Nick
+			if name == "" {
+				name = member.User.Username
+			}
+
+			if len(names) == 0 {
+				return name + " has no roles", nil
+			}
+
+			return fmt.Sprintf("%s: %s", name, strings.Join(names, ", ")), nil
+		},
+	}
+}
+
+// NewDiscordTools creates the tools looking up the current Discord server, read-only.
+func NewDiscordTools(s *discordgo.Session) []Tool {
+	return []Tool{newServerInfo(s), newChannelList(s), newMemberRoles(s)}
+}
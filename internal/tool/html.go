@@ -0,0 +1,132 @@
+package tool
+
+import (
+	"html"
+	"strings"
+)
+
+// skippedElements are the elements whose content is not readable text.
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true, "svg": true,
+	"iframe": true, "form": true, "nav": true, "header": true, "footer": true, "aside": true,
+}
+
+// blockElements are the elements which break lines.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "section": true, "article": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true, "table": true, "ul": true, "ol": true, "dd": true, "dt": true,
+}
+
+// tagName returns the lowercase name of the tag, without the slash of closing tags, and whether it closes.
+func tagName(tag string) (string, bool) {
+	closing := strings.HasPrefix(tag, "/")
+	tag = strings.TrimPrefix(tag, "/")
+
+	end := strings.IndexAny(tag, " \t\r\n/>")
+	if end >= 0 {
+		tag = tag[:end]
+	}
+
+	return strings.ToLower(tag), closing
+}
+
+// mainContent returns the content of the main or article element of the page if any.
+func mainContent(page string) string {
+	lower := strings.ToLower(page)
+
+	for _, element := range []string{"main", "article"} {
+		start := strings.Index(lower, "<"+element)
+		end := strings.LastIndex(lower, "</"+element+">")
+
+		if start >= 0 && end > start {
+			return page[start:end]
+		}
+	}
+
+	return page
+}
+
+// extractText extracts the title and the readable text of the HTML page.
+func extractText(page string) (string, string) {
+	var title string
+
+	lower := strings.ToLower(page)
+	if start := strings.Index(lower, "<title"); start >= 0 {
+		if open := strings.Index(lower[start:], ">"); open >= 0 {
+			if end := strings.Index(lower[start+open:], "</title>"); end >= 0 {
+				title = strings.TrimSpace(html.UnescapeString(page[start+open+1 : start+open+end]))
+			}
+		}
+	}
+
+	var text strings.Builder
+	skipping := ""
+
+	content := mainContent(page)
+	for len(content) > 0 {
+		open := strings.IndexByte(content, '<')
+		if open < 0 {
+			if skipping == "" {
+				text.WriteString(content)
+			}
+
+			break
+		}
+
+		if skipping == "" {
+			text.WriteString(content[:open])
+		}
+
+		content = content[open+1:]
+
+		// Comments may contain tags.
+		if strings.HasPrefix(content, "!--") {
+			end := strings.Index(content, "-->")
+			if end < 0 {
+				break
+			}
+
+			content = content[end+3:]
+
+			continue
+		}
+
+		end := strings.IndexByte(content, '>')
+		if end < 0 {
+			break
+		}
+
+		tag := content[:end]
+		name, closing := tagName(tag)
+		content = content[end+1:]
+
+		switch {
+		case skipping != "":
+			if closing && name == skipping {
+				skipping = ""
+			}
+		case skippedElements[name] && !closing && !strings.HasSuffix(tag, "/"):
+			skipping = name
+		case blockElements[name]:
+			text.WriteString("\n")
+		case name == "td" || name == "th":
+			text.WriteString(" ")
+		}
+	}
+
+	return title, collapseSpaces(html.UnescapeString(text.String()))
+}
+
+// collapseSpaces collapses the spaces within lines and removes the blank lines.
+func collapseSpaces(text string) string {
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
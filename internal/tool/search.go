@@ -0,0 +1,262 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"chatbot-gpt/internal/config"
+)
+
+// maxPageSize is the maximum number of bytes read of a fetched page.
+const maxPageSize = 1 << 20
+
+var (
+	// errPrivateAddress is returned when a result is on a loopback or private network.
+	errPrivateAddress = errors.New("private address")
+
+	// errUnreadable is returned when a result is not a text page.
+	errUnreadable = errors.New("unreadable content")
+)
+
+// searchResult is a result of the search API.
+type searchResult struct {
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// searcher searches the web and fetches the results.
+type searcher struct {
+	cfg config.Search
+
+	// client queries the search API.
+	client *http.Client
+
+	// fetcher fetches the results, refusing private addresses unless they are allowed.
+	fetcher *http.Client
+}
+
+// refusePrivateAddress refuses to connect to loopback, private and link-local addresses.
+func refusePrivateAddress(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// search queries the search API.
+func (s *searcher) search(ctx context.Context, query string) ([]searchResult, error) {
+	endpoint, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	params := endpoint.Query()
+	params.Set("q", query)
+	params.Set("format", "json")
+	endpoint.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", s.cfg.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search failed: %s", resp.Status)
+	}
+
+	var body struct {
+		Results []searchResult `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	if len(body.Results) > s.cfg.MaxResults {
+		body.Results = body.Results[:s.cfg.MaxResults]
+	}
+
+	return body.Results, nil
+}
+
+// fetch fetches the page of the result, and returns its title and readable text.
+func (s *searcher) fetch(ctx context.Context, pageURL string) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	req.Header.Set("User-Agent", s.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html, text/plain")
+
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("fetch failed: %s", resp.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "text/plain" {
+		return "", "", fmt.Errorf("%w: %s", errUnreadable, mediaType)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", "", err
+	}
+
+	if mediaType == "text/plain" {
+		return "", collapseSpaces(string(page)), nil
+	}
+
+	title, text := extractText(string(page))
+
+	return title, text, nil
+}
+
+// truncate truncates the text to the maximum number of characters.
+func truncate(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+
+	return string(runes[:maxLength]) + "…"
+}
+
+// handle searches the query, fetches the top results and numbers them as sources.
+func (s *searcher) handle(ctx context.Context, arguments string) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+
+	if err := decodeArguments(arguments, &args); err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("empty query")
+	}
+
+	results, err := s.search(ctx, args.Query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(results) == 0 {
+		return "no results", nil
+	}
+
+	// The top results are fetched in parallel, falling back to their snippets.
+	var wg sync.WaitGroup
+	for i := range results[:min(s.cfg.FetchResults, len(results))] {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			title, text, err := s.fetch(ctx, results[i].URL)
+			if err != nil || text == "" {
+				return
+			}
+
+			if results[i].Title == "" {
+				results[i].Title = title
+			}
+
+			results[i].Content = text
+		}()
+	}
+
+	wg.Wait()
+
+	sources, _ := SourcesFrom(ctx)
+
+	var sb strings.Builder
+	sb.WriteString("Cite the sources you use with their numbers in brackets, e.g. [1].\n")
+
+	for i, result := range results {
+		number := i + 1
+		if sources != nil {
+			number = sources.Add(Source{Title: result.Title, URL: result.URL})
+		}
+
+		fmt.Fprintf(
+			&sb, "\n[%d] %s\nURL: %s\n%s\n",
+			number, result.Title, result.URL, truncate(result.Content, s.cfg.MaxContentLength),
+		)
+	}
+
+	return sb.String(), nil
+}
+
+// newSearcher creates a searcher of the configuration.
+func newSearcher(cfg config.Search) *searcher {
+	cfg.MaxResults = max(cfg.MaxResults, 1)
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateAddresses {
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: refusePrivateAddress,
+		}).DialContext
+	}
+
+	return &searcher{
+		cfg:     cfg,
+		client:  &http.Client{},
+		fetcher: &http.Client{Transport: transport},
+	}
+}
+
+// NewSearch creates the tool searching the web with the search API of the configuration.
+func NewSearch(cfg config.Search) Tool {
+	s := newSearcher(cfg)
+
+	return Tool{
+		Name:        "web_search",
+		Description: "Search the web for current information, and read the top results.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "Search query"}
+			},
+			"required": ["query"]
+		}`),
+		Handler: s.handle,
+	}
+}
@@ -0,0 +1,198 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chatbot-gpt/internal/config"
+)
+
+// searchServers starts a page server and a search API returning the results of its pages.
+func searchServers(t *testing.T) (*httptest.Server, *httptest.Server) {
+	t.Helper()
+
+	pages := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(w, `<html><head><title>Article title</title><script>var x = 1;</script></head>`+
+				`<body><nav>Menu</nav><main><p>The article   text.</p></main></body></html>`)
+		case "/notes.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = io.WriteString(w, "Plain\n\nnotes")
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(pages.Close)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "json" || r.Header.Get("User-Agent") != "test-agent" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Query().Get("q") == "nothing" {
+			_, _ = io.WriteString(w, `{"results": []}`)
+			return
+		}
+
+		results := []searchResult{
+			{URL: pages.URL + "/article", Content: "Article snippet"},
+			{URL: pages.URL + "/notes.txt", Title: "Notes", Content: "Notes snippet"},
+			{URL: pages.URL + "/image.png", Title: "Image", Content: "Image snippet"},
+			{URL: pages.URL + "/missing", Title: "Beyond the maximum", Content: "Dropped"},
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}))
+	t.Cleanup(api.Close)
+
+	return api, pages
+}
+
+func TestSearch(t *testing.T) {
+	api, pages := searchServers(t)
+
+	search := NewSearch(config.Search{
+		Endpoint:              api.URL + "/search?language=en",
+		MaxResults:            3,
+		FetchResults:          3,
+		MaxContentLength:      20,
+		UserAgent:             "test-agent",
+		AllowPrivateAddresses: true,
+	})
+
+	sources := &Sources{}
+	sources.Add(Source{Title: "Earlier", URL: "https://example.com/"})
+
+	result, err := search.Handler(WithSources(context.Background(), sources), `{"query": "article"}`)
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	// The sources are numbered after the ones found earlier, and the unreadable page keeps its snippet.
+	for _, want := range []string{
+		fmt.Sprintf("[2] Article title\nURL: %s/article\nThe article text.\n", pages.URL),
+		fmt.Sprintf("[3] Notes\nURL: %s/notes.txt\nPlain\nnotes\n", pages.URL),
+		fmt.Sprintf("[4] Image\nURL: %s/image.png\nImage snippet\n", pages.URL),
+	} {
+		if !strings.Contains(result, want) {
+			t.Errorf("Handler() = %q, want it to contain %q", result, want)
+		}
+	}
+
+	if strings.Contains(result, "Beyond the maximum") {
+		t.Errorf("Handler() = %q, want at most 3 results", result)
+	}
+
+	if got := len(sources.List()); got != 4 {
+		t.Errorf("sources = %d, want 4", got)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	api, _ := searchServers(t)
+
+	tests := []struct {
+		name      string
+		endpoint  string
+		userAgent string
+		arguments string
+		want      string
+		err       bool
+	}{
+		{name: "no results", endpoint: api.URL, userAgent: "test-agent", arguments: `{"query": "nothing"}`, want: "no results"},
+		{name: "empty query", endpoint: api.URL, userAgent: "test-agent", arguments: `{"query": " "}`, err: true},
+		{name: "invalid arguments", endpoint: api.URL, userAgent: "test-agent", arguments: `{"query": 1}`, err: true},
+		{name: "failed search", endpoint: api.URL, userAgent: "other", arguments: `{"query": "article"}`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := NewSearch(config.Search{Endpoint: tt.endpoint, MaxResults: 5, UserAgent: tt.userAgent})
+
+			result, err := search.Handler(context.Background(), tt.arguments)
+			if (err != nil) != tt.err || result != tt.want {
+				t.Errorf("Handler() = %q, %v, want %q with error %v", result, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestSearchRefusesPrivateAddresses(t *testing.T) {
+	api, pages := searchServers(t)
+
+	search := NewSearch(config.Search{
+		Endpoint:         api.URL,
+		MaxResults:       1,
+		FetchResults:     1,
+		MaxContentLength: 100,
+		UserAgent:        "test-agent",
+	})
+
+	// The search API is configured by the operator, but its results on loopback are not fetched.
+	result, err := search.Handler(context.Background(), `{"query": "article"}`)
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+
+	if want := fmt.Sprintf("[1] \nURL: %s/article\nArticle snippet\n", pages.URL); !strings.Contains(result, want) {
+		t.Errorf("Handler() = %q, want the snippet %q", result, want)
+	}
+
+	if _, _, err := newSearcher(config.Search{}).fetch(context.Background(), pages.URL+"/article"); !errors.Is(
+		err, errPrivateAddress,
+	) {
+		t.Errorf("fetch() error = %v, want %v", err, errPrivateAddress)
+	}
+
+	allowed := newSearcher(config.Search{AllowPrivateAddresses: true})
+	if title, text, err := allowed.fetch(context.Background(), pages.URL+"/article"); err != nil || title != "Article title" {
+		t.Errorf("fetch() allowing private addresses = %q, %q, %v", title, text, err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("こんにちは世界", 5); got != "こんにちは…" {
+		t.Errorf("truncate() = %q, want %q", got, "こんにちは…")
+	}
+
+	if got := truncate("short", 5); got != "short" {
+		t.Errorf("truncate() = %q, want %q", got, "short")
+	}
+}
+
+func TestRefusePrivateAddress(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:80":         false,
+		"[::1]:443":            false,
+		"10.1.2.3:80":          false,
+		"172.16.0.1:80":        false,
+		"192.168.1.1:80":       false,
+		"169.254.169.254:80":   false,
+		"[fe80::1]:80":         false,
+		"0.0.0.0:80":           false,
+		"[fd00::1]:80":         false,
+		"localhost:80":         false,
+		"93.184.216.34:443":    true,
+		"[2606:4700::1111]:80": true,
+	}
+
+	for address, allowed := range tests {
+		err := refusePrivateAddress("tcp", address, nil)
+		if allowed && err != nil || !allowed && !errors.Is(err, errPrivateAddress) {
+			t.Errorf("refusePrivateAddress(%q) = %v, want allowed %v", address, err, allowed)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package tool
+
+import (
+	"context"
+	"sync"
+)
+
+// Source is a web page which the model can cite.
+type Source struct {
+	Title string
+	URL   string
+}
+
+// Sources collects the sources found by the tools of a response, numbered in the order they are found.
+type Sources struct {
+	mu      sync.Mutex
+	sources []Source
+}
+
+// Add adds the source and returns its number, starting at 1.
+// A source already added keeps its number.
+func (s *Sources) Add(source Source) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, added := range s.sources {
+		if added.URL == source.URL {
+			return i + 1
+		}
+	}
+
+	s.sources = append(s.sources, source)
+
+	return len(s.sources)
+}
+
+// List returns the sources in the order of their numbers.
+func (s *Sources) List() []Source {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Source(nil), s.sources...)
+}
+
+// sourcesKey is the context key of the sources.
+type sourcesKey struct{}
+
+// WithSources returns a context carrying the sources.
+func WithSources(ctx context.Context, sources *Sources) context.Context {
+	return context.WithValue(ctx, sourcesKey{}, sources)
+}
+
+// SourcesFrom returns the sources carried by the context.
+func SourcesFrom(ctx context.Context) (*Sources, bool) {
+	sources, ok := ctx.Value(sourcesKey{}).(*Sources)
+	return sources, ok
+}
@@ -0,0 +1,197 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var (
+	// ErrDuplicate is returned when a tool with the same name is already registered.
+	ErrDuplicate = errors.New("duplicate tool name")
+
+	// ErrUnknown is returned when a tool is not registered.
+	ErrUnknown = errors.New("unknown tool")
+
+	// ErrDisabled is returned when a tool is not enabled where it is called.
+	ErrDisabled = errors.New("tool not enabled")
+)
+
+// Handler handles a call of a tool with the JSON arguments given by the model, and returns the result for the model.
+type Handler func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a tool which the model can call.
+type Tool struct {
+	Name        string
+	Description string
+
+	// Parameters is the JSON schema of the arguments.
+	Parameters json.RawMessage
+
+	Handler Handler
+}
+
+// Scope is the Discord context in which a tool is called.
+// Tools must only access the data of the scope.
+type Scope struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+}
+
+// scopeKey is the context key of the scope.
+type scopeKey struct{}
+
+// WithScope returns a context carrying the scope.
+func WithScope(ctx context.Context, scope Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFrom returns the scope carried by the context.
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(Scope)
+	return scope, ok
+}
+
+// Registry is a registry of tools.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// Register registers the tool.
+func (r *Registry) Register(tool Tool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicate, tool.Name)
+	}
+
+	r.tools[tool.Name] = tool
+
+	return nil
+}
+
+// Lookup returns the tool of the name.
+func (r *Registry) Lookup(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tool, ok := r.tools[name]
+
+	return tool, ok
+}
+
+// Definitions returns the definitions of the tools of the names to send to the model.
+func (r *Registry) Definitions(names []string) []openai.Tool {
+	var definitions []openai.Tool
+
+	for _, name := range names {
+		tool, ok := r.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		definitions = append(definitions, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	return definitions
+}
+
+// Call calls the tool of the tool call within the timeout, and returns the tool message of the result.
+// Only the enabled tools are called, whatever the model asks for.
+// Failures are reported to the model in the result instead of being returned.
+func (r *Registry) Call(
+	ctx context.Context, call openai.ToolCall, enabled []string, timeout time.Duration,
+) openai.ChatCompletionMessage {
+	message := openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Name:       call.Function.Name,
+		ToolCallID: call.ID,
+	}
+
+	result, err := r.call(ctx, call, enabled, timeout)
+	if err != nil {
+		message.Content = "error: " + err.Error()
+	} else {
+		message.Content = result
+	}
+
+	return message
+}
+
+// call calls the tool of the tool call within the timeout, unless it is not enabled.
+func (r *Registry) call(ctx context.Context, call openai.ToolCall, enabled []string, timeout time.Duration) (string, error) {
+	if !slices.Contains(enabled, call.Function.Name) {
+		return "", fmt.Errorf("%w: %s", ErrDisabled, call.Function.Name)
+	}
+
+	tool, ok := r.Lookup(call.Function.Name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknown, call.Function.Name)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		result string
+		err    error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := tool.Handler(ctx, call.Function.Arguments)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case o := <-done:
+		return o.result, o.err
+	}
+}
+
+// CallAll calls the enabled tools of the tool calls in parallel, and returns the tool messages in the order of the calls.
+func (r *Registry) CallAll(
+	ctx context.Context, calls []openai.ToolCall, enabled []string, timeout time.Duration,
+) []openai.ChatCompletionMessage {
+	messages := make([]openai.ChatCompletionMessage, len(calls))
+
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			messages[i] = r.Call(ctx, call, enabled, timeout)
+		}()
+	}
+
+	wg.Wait()
+
+	return messages
+}
+
+// NewRegistry creates a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
@@ -0,0 +1,55 @@
+package tool
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestCallAllEnabledTools(t *testing.T) {
+	registry := NewRegistry()
+
+	var called []string
+	for _, name := range []string{"echo", "web_search"} {
+		if err := registry.Register(Tool{
+			Name: name,
+			Handler: func(_ context.Context, arguments string) (string, error) {
+				called = append(called, name)
+				return arguments, nil
+			},
+		}); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	calls := []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{Name: "web_search", Arguments: "secrets"}},
+		{ID: "2", Function: openai.FunctionCall{Name: "missing"}},
+	}
+
+	messages := registry.CallAll(context.Background(), calls, []string{"echo"}, time.Second)
+
+	if len(called) != 0 {
+		t.Errorf("called %v, want no handler called", called)
+	}
+
+	if len(messages) != 2 || messages[0].ToolCallID != "1" || messages[1].ToolCallID != "2" {
+		t.Fatalf("CallAll() = %+v, want a result per call in order", messages)
+	}
+
+	for _, message := range messages {
+		if message.Role != openai.ChatMessageRoleTool || !strings.Contains(message.Content, ErrDisabled.Error()) {
+			t.Errorf("result of %s = %q, want the %q error", message.Name, message.Content, ErrDisabled)
+		}
+	}
+
+	message := registry.Call(context.Background(), openai.ToolCall{
+		ID: "3", Function: openai.FunctionCall{Name: "echo", Arguments: "hello"},
+	}, []string{"echo"}, time.Second)
+	if message.Content != "hello" || len(called) != 1 {
+		t.Errorf("Call() of an enabled tool = %q, called %v", message.Content, called)
+	}
+}